@@ -2,17 +2,21 @@ package JZ_Redis
 
 import (
 	"JZ_Redis/config"
-	"JZ_Redis/datastruct/dict"
+	"JZ_Redis/interface/redis"
 	"JZ_Redis/lib/logger"
+	"JZ_Redis/rdb"
 	"JZ_Redis/redis/parser"
 	"JZ_Redis/redis/reply"
-	"godis/datastruct/lock"
-
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"os"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,194 +30,532 @@ func makeExpireCmd(key string, expireAt time.Time) *reply.MultiBulkReply {
 	return reply.MakeMultiBulkReply(args)
 }
 
+// makeAofCmd prepends command name to its args so that it can be persisted
+func makeAofCmd(cmd string, args [][]byte) *reply.MultiBulkReply {
+	params := make([][]byte, len(args)+1)
+	copy(params[1:], args)
+	params[0] = []byte(cmd)
+	return reply.MakeMultiBulkReply(params)
+}
+
+// appendfsync policies
+const (
+	// fsync after every write, the command replies after its aof is on disk
+	aofFsyncAlways = "always"
+	// fsync once per second in background
+	aofFsyncEverySec = "everysec"
+	// leave fsync to the operating system
+	aofFsyncNo = "no"
+)
+
+func parseAppendFsync(policy string) string {
+	switch strings.ToLower(policy) {
+	case aofFsyncAlways:
+		return aofFsyncAlways
+	case aofFsyncNo:
+		return aofFsyncNo
+	case aofFsyncEverySec, "":
+		return aofFsyncEverySec
+	}
+	logger.Warn("unknown appendfsync policy " + policy + ", use everysec")
+	return aofFsyncEverySec
+}
+
+// aofPayload is sent to aof goroutine through aofChan
+type aofPayload struct {
+	cmdLine *reply.MultiBulkReply
+	// closed after cmdLine is written and fsynced, only used by appendfsync always
+	done chan struct{}
+}
+
 // AddAof send command to aof goroutine through channel
 func (db *DB) AddAof(args *reply.MultiBulkReply) {
+	// the first argument of aof record is a key, except commands like flushdb which are not allowed in MULTI
+	if len(args.Args) > 1 {
+		if effects := db.getTxEffects(string(args.Args[1])); effects != nil {
+			effects.aof = append(effects.aof, args)
+			return
+		}
+	}
+	db.writeAof(args)
+}
+
+// writeAof sends command to aof goroutine immediately
+func (db *DB) writeAof(args *reply.MultiBulkReply) {
+	// every persisted command is a change since last save
+	atomic.AddInt64(&db.dirty, 1)
 	// aofChan == nil when loadAof
 	if config.Properties.AppendOnly && db.aofChan != nil {
-		db.aofChan <- args
+		if db.aofFsync != aofFsyncAlways {
+			db.aofChan <- &aofPayload{cmdLine: args}
+			return
+		}
+		// wait for fsync before replying
+		done := make(chan struct{})
+		db.aofChan <- &aofPayload{cmdLine: args, done: done}
+		<-done
 	}
 }
 
 // handleAof listen aof channel and write into file
 func (db *DB) handleAof() {
-	for cmd := range db.aofChan {
+	for p := range db.aofChan {
+		if p.cmdLine == nil {
+			// barrier of waitAofWritten, commands before it have been written
+			close(p.done)
+			continue
+		}
+		cmd := p.cmdLine
 		// todo: use switch and channels instead of mutex
 		// 异步协程在持久化之前会尝试获取锁,若其他协程持有锁则会暂停持久化操作
 		// 锁也保证了每次写入完整的一条指令不会格式错误
 		db.pausingAof.RLock() // prevent other goroutines from pausing aof
-		if db.aofRewriteBuffer != nil {
-			// replica during rewrite 数据写入重写缓冲区
-			// 在重写过程中，持久化协程进行双写 -> 正常写是不受影响的
-			db.aofRewriteBuffer <- cmd
-		}
-		_, err := db.aofFile.Write(cmd.ToBytes())
+		n, err := db.aofFile.Write(cmd.ToBytes())
 		if err != nil {
 			logger.Warn(err)
 		}
+		atomic.AddInt64(&db.aofUnsyncedBytes, int64(n))
+		atomic.AddInt64(&db.aofCurrentSize, int64(n))
+		if db.aofFsync == aofFsyncAlways {
+			db.syncAof()
+		}
 		db.pausingAof.RUnlock()
+		if p.done != nil {
+			close(p.done)
+		}
+		db.checkAutoRewrite()
 	}
+	// flush everything to disk before shutdown
+	db.pausingAof.RLock()
+	db.syncAof()
+	db.pausingAof.RUnlock()
 	db.aofFinished <- struct{}{}
 }
 
-// loadAof read aof file
-func (db *DB) loadAof(maxBytes int) {
+// syncAof fsyncs aof file if there are unsynced writes, the invoker should hold pausingAof
+func (db *DB) syncAof() {
+	unsynced := atomic.SwapInt64(&db.aofUnsyncedBytes, 0)
+	if unsynced == 0 {
+		return
+	}
+	if err := db.aofFile.Sync(); err != nil {
+		logger.Warn("aof fsync failed: " + err.Error())
+		atomic.AddInt64(&db.aofUnsyncedBytes, unsynced)
+		return
+	}
+	atomic.StoreInt64(&db.aofLastFsync, time.Now().UnixNano())
+}
+
+// fsyncEverySec fsyncs aof file every second until db is closed
+func (db *DB) fsyncEverySec() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.pausingAof.RLock()
+			db.syncAof()
+			db.pausingAof.RUnlock()
+		case <-db.closing:
+			return
+		}
+	}
+}
+
+// aofFsyncLag returns the time since last fsync if there are unsynced writes, otherwise 0
+func (db *DB) aofFsyncLag() time.Duration {
+	if atomic.LoadInt64(&db.aofUnsyncedBytes) == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, atomic.LoadInt64(&db.aofLastFsync)))
+}
+
+// loadAof reads files listed in aof manifest in order.
+// A truncated last command of the last file is removed if aof-load-truncated is enabled,
+// otherwise loadAof returns error, so does corruption anywhere else
+func (db *DB) loadAof() error {
 	// delete aofChan to prevent write again
 	aofChan := db.aofChan
 	db.aofChan = nil
 	// 最后做一个替换
-	defer func(aofChan chan *reply.MultiBulkReply) {
+	defer func(aofChan chan *aofPayload) {
 		db.aofChan = aofChan
 	}(aofChan)
 
-	// 打开文件开始写入
-	file, err := os.Open(db.aofFilename)
-	if err != nil {
-		if _, ok := err.(*os.PathError); ok {
-			return
+	files := db.aofManifest.files()
+	for i, info := range files {
+		if err := db.loadAofFile(db.aofFilePath(info), i == len(files)-1); err != nil {
+			return err
 		}
-		logger.Warn(err)
-		return
+	}
+	return nil
+}
+
+// loadAofFile reads commands in the given file, the file may start with a rdb snapshot
+func (db *DB) loadAofFile(filename string, isLast bool) error {
+	// 打开文件开始读取
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
 	}
 	defer file.Close()
 
-	var reader io.Reader
-	if maxBytes > 0 {
-		reader = io.LimitReader(file, int64(maxBytes))
-	} else {
-		reader = file
-	}
-	ch := parser.ParseStream(reader)
-	for p := range ch {
-		if p.Err != nil {
-			if p.Err == io.EOF {
-				break
-			}
-			logger.Error("parse error: " + p.Err.Error())
-			continue
+	// base file written with aof-use-rdb-preamble is a rdb snapshot
+	// rdb 解码器与命令解析共用同一个 bufio.Reader, 避免 rdb 之后的数据被缓冲区吞掉
+	reader := bufio.NewReader(file)
+	var rdbSize int64
+	if header, _ := reader.Peek(len(rdb.Magic)); string(header) == rdb.Magic {
+		decoder := rdb.NewDecoder(reader)
+		if err := db.loadRdbObjects(decoder); err != nil {
+			return fmt.Errorf("bad rdb preamble of aof %s: %v", filename, err)
 		}
-		if p.Data == nil {
-			logger.Error("empty payload")
-			continue
+		rdbSize = decoder.Offset()
+	}
+
+	cmdReader := parser.NewCommandReader(reader)
+	for {
+		// offset where the command begins
+		offset := rdbSize + cmdReader.Offset()
+		cmdLine, err := cmdReader.ReadCommand()
+		if err == io.EOF {
+			return nil
 		}
-		r, ok := p.Data.(*reply.MultiBulkReply)
-		if !ok {
-			logger.Error("require multi bulk reply")
-			continue
+		if err == parser.ErrTruncated && isLast {
+			return handleTruncatedAof(filename, offset)
+		}
+		if err == nil {
+			err = CheckCmdLine(cmdLine)
+		}
+		if err == nil {
+			err = db.loadAofCommand(cmdLine)
+		}
+		if err != nil {
+			return fmt.Errorf("bad aof format of %s at offset %d: %v", filename, offset, err)
 		}
-		cmd := strings.ToLower(string(r.Args[0]))
-		command, ok := cmdTable[cmd]
-		if ok {
-			handler := command.executor
-			handler(db, r.Args[1:])
+	}
+}
+
+// loadAofCommand executes command read from aof, panic of executor is returned as error
+func (db *DB) loadAofCommand(cmdLine CmdLine) (err error) {
+	defer func() {
+		if e := recover(); e != nil {
+			err = fmt.Errorf("%v", e)
 		}
+	}()
+	cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+	cmd.executor(db, cmdLine[1:])
+	return nil
+}
+
+// handleTruncatedAof cuts the incomplete command at tail of aof file
+func handleTruncatedAof(filename string, offset int64) error {
+	if !config.Properties.AofLoadTruncated {
+		return fmt.Errorf("aof %s is truncated at offset %d, set aof-load-truncated yes to recover it", filename, offset)
 	}
+	// 文件尾部的命令不完整, 通常是写入过程中宕机导致的, 截断到最后一条完整命令
+	logger.Warn(fmt.Sprintf("aof %s is truncated at offset %d, truncate file to the last valid command", filename, offset))
+	return os.Truncate(filename, offset)
+}
+
+// openIncrAof opens the last incr file for appending, a new one is created if there is none
+func (db *DB) openIncrAof() error {
+	info := db.aofManifest.lastIncr()
+	if info == nil {
+		m := db.aofManifest.clone()
+		m.currIncrSeq++
+		info = db.makeIncrAofInfo(m.currIncrSeq)
+		m.incrs = append(m.incrs, info)
+		// create file before it's listed in manifest
+		file, err := os.OpenFile(db.aofFilePath(info), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+		if err != nil {
+			return err
+		}
+		_ = file.Close()
+		if err := db.persistAofManifest(m); err != nil {
+			return err
+		}
+		db.aofManifest = m
+	}
+	aofFile, err := os.OpenFile(db.aofFilePath(info), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	db.aofFile = aofFile
+	return nil
 }
 
 /*-- aof rewrite --*/
-func (db *DB) aofRewrite() {
-	file, fileSize, err := db.startRewrite()
+
+var errRewriteInProgress = errors.New("ERR Background append only file rewriting already in progress")
+
+var errRewriteOnClosing = errors.New("ERR server is shutting down")
+
+// beginRewrite marks rewrite in progress, rewrite is not allowed after db started closing since aofChan will be closed
+func (db *DB) beginRewrite() error {
+	if !db.aofRewriting.begin() {
+		return errRewriteInProgress
+	}
+	// checked after begin, so that Close either sees the rewrite in progress or rewrite sees db closing
+	select {
+	case <-db.closing:
+		db.aofRewriting.end(errRewriteOnClosing)
+		return errRewriteOnClosing
+	default:
+	}
+	return nil
+}
+
+// aofRewrite rewrites aof file and blocks until finished
+func (db *DB) aofRewrite() error {
+	if err := db.beginRewrite(); err != nil {
+		return err
+	}
+	err := db.aofRewrite0()
+	db.aofRewriting.end(err)
+	return err
+}
+
+// bgRewriteAof starts aof rewrite in background, returns error if there is another rewrite in progress
+func (db *DB) bgRewriteAof() error {
+	if err := db.beginRewrite(); err != nil {
+		return err
+	}
+	go func() {
+		err := db.aofRewrite0()
+		db.aofRewriting.end(err)
+	}()
+	return nil
+}
+
+// execBgRewriteAof rewrites aof file in background
+func execBgRewriteAof(db *DB, args [][]byte) redis.Reply {
+	if db.aofFile == nil {
+		return reply.MakeErrReply("ERR append only file is disabled")
+	}
+	if err := db.bgRewriteAof(); err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	return reply.MakeStatusReply("Background append only file rewriting started")
+}
+
+// checkAutoRewrite starts rewrite if aof has grown by auto-aof-rewrite-percentage since last rewrite
+func (db *DB) checkAutoRewrite() {
+	percentage := config.Properties.AutoAofRewritePercentage
+	if percentage <= 0 {
+		return
+	}
+	select {
+	case <-db.closing:
+		return
+	default:
+	}
+	current := atomic.LoadInt64(&db.aofCurrentSize)
+	if current < int64(config.Properties.AutoAofRewriteMinSize) {
+		return
+	}
+	base := atomic.LoadInt64(&db.aofBaseSize)
+	if base == 0 {
+		base = 1
+	}
+	growth := (current - base) * 100 / base
+	if growth < int64(percentage) {
+		return
+	}
+	if db.bgRewriteAof() == nil {
+		logger.Info(fmt.Sprintf("starting automatic rewriting of AOF on %d%% growth", growth))
+	}
+}
+
+/*
+ * aofRewrite0 rewrites aof from the live db:
+ *   1. startRewrite blocks all writers by read-locking every slot of db.locker,
+ *      waits until commands in aofChan have been written, then switches to a new incr file
+ *      and serializes every key with its ttl into memory,
+ *      in rdb format if aof-use-rdb-preamble is enabled, otherwise as commands
+ *   2. the serialized data is written into a new base file
+ *   3. finishRewrite replaces manifest with the new base file and incr files created since the switch,
+ *      then removes the old files
+ *
+ * This is a stop-the-world pause, not copy-on-write: writers are blocked while the whole db is serialized,
+ * and the serialized data is held in memory until written, so peak memory is about twice the dataset.
+ * Iterating shard by shard with only that shard locked is not an option with the incr file handoff:
+ * a write between the switch and serializing its shard would be in both the base and the new incr file,
+ * and replaying non-idempotent commands like INCR or RPUSH twice corrupts data.
+ * Commands touching several shards, such as RENAME, can't be split at any per-shard point either.
+ * Without fork there is no cheap way to copy the db, so the pause is accepted in exchange for a consistent base.
+ * 序列化期间写命令会被阻塞, 但写文件时不再阻塞. 切换之后的命令都写入新的 incr 文件, 所以不需要重写缓冲区
+ */
+func (db *DB) aofRewrite0() error {
+	snapshot, incrSeq, err := db.startRewrite()
 	if err != nil {
 		logger.Warn(err)
-		return
+		return err
 	}
 
-	// load aof file
-	tmpDB := &DB{
-		data:   dict.MakeSimple(),
-		ttlMap: dict.MakeSimple(),
-		locker: lock.Make(lockerSize),
+	base := db.makeBaseAofInfo(db.aofManifest.currBaseSeq+1, config.Properties.AofUseRdbPreamble)
+	// 先写入临时文件, 避免留下不完整的 base 文件
+	file, err := ioutil.TempFile(db.aofDirname, base.filename+".tmp-*")
+	if err == nil {
+		_, err = file.Write(snapshot)
+		if err == nil {
+			err = file.Sync()
+		}
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+		if err == nil {
+			err = os.Rename(file.Name(), db.aofFilePath(base))
+		}
+		if err != nil {
+			_ = os.Remove(file.Name())
+		}
+	}
+	if err != nil {
+		logger.Warn(err)
+		return err
+	}
+	return db.finishRewrite(base, incrSeq)
+}
+
+// startRewrite serializes db while blocking all writers and switches to a new incr file for commands executed after it,
+// returns the serialized data and sequence of the new incr file
+func (db *DB) startRewrite() ([]byte, int64, error) {
+	// writers hold write lock of its keys until its commands are sent to aofChan
+	db.locker.RLockAll()
+	defer db.locker.RUnLockAll()
 
-		aofFilename: db.aofFilename,
+	// commands executed before snapshot must be written into the old incr file
+	db.waitAofWritten()
+	db.pausingAof.Lock()
+	err := db.rotateIncrAof()
+	db.pausingAof.Unlock()
+	if err != nil {
+		return nil, 0, err
 	}
-	// 只读取开始重写前aof文件的内容
-	tmpDB.loadAof(int(fileSize))
+	incrSeq := db.aofManifest.currIncrSeq
 
-	// rewrite aof file
-	tmpDB.data.ForEach(func(key string, raw interface{}) bool {
+	buf := &bytes.Buffer{}
+	if config.Properties.AofUseRdbPreamble {
+		if err := db.writeRdbSnapshot(buf); err != nil {
+			return nil, 0, err
+		}
+		return buf.Bytes(), incrSeq, nil
+	}
+	now := time.Now()
+	db.data.ForEach(func(key string, raw interface{}) bool {
+		var expireTime time.Time
+		rawExpireTime, hasTTL := db.ttlMap.Get(key)
+		if hasTTL {
+			expireTime, _ = rawExpireTime.(time.Time)
+			if expireTime.Before(now) {
+				return true
+			}
+		}
 		entity, _ := raw.(*DataEntity)
 		cmd := EntityToCmd(key, entity)
-		if cmd != nil {
-			_, _ = file.Write(cmd.ToBytes())
+		if cmd == nil {
+			return true
 		}
-		return true
-	})
-	tmpDB.ttlMap.ForEach(func(key string, raw interface{}) bool {
-		expireTime, _ := raw.(time.Time)
-		cmd := makeExpireCmd(key, expireTime)
-		if cmd != nil {
-			_, _ = file.Write(cmd.ToBytes())
+		buf.Write(cmd.ToBytes())
+		if hasTTL {
+			buf.Write(toTTLCmd(db, key).ToBytes())
 		}
 		return true
 	})
-
-	db.finishRewrite(file)
+	return buf.Bytes(), incrSeq, nil
 }
 
-func (db *DB) startRewrite() (*os.File, int64, error) {
-	// 暂停AOF写入， 数据会在 db.aofChan 中暂时堆积
-	db.pausingAof.Lock() // pausing aof
-	defer db.pausingAof.Unlock()
+// waitAofWritten blocks until all commands sent to aofChan before have been written
+func (db *DB) waitAofWritten() {
+	done := make(chan struct{})
+	db.aofChan <- &aofPayload{done: done}
+	<-done
+}
 
-	err := db.aofFile.Sync() // ?
+// rotateIncrAof creates a new incr file and writes aof into it, the invoker should hold pausingAof
+func (db *DB) rotateIncrAof() error {
+	m := db.aofManifest.clone()
+	m.currIncrSeq++
+	info := db.makeIncrAofInfo(m.currIncrSeq)
+	aofFile, err := os.OpenFile(db.aofFilePath(info), os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
 	if err != nil {
-		logger.Warn("fsync failed")
-		return nil, 0, err
+		return err
 	}
+	m.incrs = append(m.incrs, info)
+	if err := db.persistAofManifest(m); err != nil {
+		_ = aofFile.Close()
+		_ = os.Remove(aofFile.Name())
+		return err
+	}
+	db.aofManifest = m
 
-	// create rewrite channel
-	// 创建重写缓冲区
-	db.aofRewriteBuffer = make(chan *reply.MultiBulkReply, aofQueueSize)
-
-	// get current aof file size
-	// 读取当前 aof 文件大小, 不读取重写过程中新写入的内容
-	fileInfo, _ := os.Stat(db.aofFilename)
-	filesize := fileInfo.Size()
+	// the old incr file is still in use until rewrite finished, so it must be on disk
+	db.syncAof()
+	_ = db.aofFile.Close()
+	db.aofFile = aofFile
+	return nil
+}
 
-	// create tmp file 创建临时文件
-	file, err := ioutil.TempFile("", "aof")
-	if err != nil {
-		logger.Warn("tmp file create failed")
-		return nil, 0, err
+// finishRewrite uses the new base file and incr files since incrSeq as aof, and removes the old files
+func (db *DB) finishRewrite(base *aofInfo, incrSeq int64) error {
+	m := db.aofManifest.clone()
+	history := make([]*aofInfo, 0, len(m.incrs)+1)
+	if m.base != nil {
+		history = append(history, m.base)
+	}
+	m.base = base
+	m.currBaseSeq = base.seq
+	m.incrs = m.incrs[:0]
+	for _, info := range db.aofManifest.incrs {
+		if info.seq >= incrSeq {
+			m.incrs = append(m.incrs, info)
+		} else {
+			history = append(history, info)
+		}
 	}
-	return file, filesize, nil
+	// 替换 manifest 之后新的 base 文件才生效, 此前宕机仍可使用旧的文件恢复
+	if err := db.persistAofManifest(m); err != nil {
+		logger.Warn(err)
+		_ = os.Remove(db.aofFilePath(base))
+		return err
+	}
+	db.aofManifest = m
+	for _, info := range history {
+		if err := os.Remove(db.aofFilePath(info)); err != nil {
+			logger.Warn(err)
+		}
+	}
+	db.resetAofSize()
+	return nil
 }
 
-// 重写完毕后写入缓冲区中的数据并替换正式文件
-func (db *DB) finishRewrite(tmpFile *os.File) {
-	db.pausingAof.Lock() // pausing aof 暂停AOF写入
-	defer db.pausingAof.Unlock()
-
-	// write commands created during rewriting to tmp file
-	// 将重写缓冲区内的数据写入临时文件
-	// 因为handleAof已被暂停，在遍历期间aofRewriteChan中不会有新数据
-loop:
-	for {
-		// aof is pausing, there won't be any new commands in aofRewriteBuffer
-		select {
-		case cmd := <-db.aofRewriteBuffer:
-			_, err := tmpFile.Write(cmd.ToBytes())
-			if err != nil {
-				logger.Warn(err)
-			}
-		default:
-			// channel is empty, break loop 只有 channel 为空时才会进入此分支 -> 跳出
-			break loop
+// resetAofSize takes current size of aof files as the base of auto rewrite
+func (db *DB) resetAofSize() {
+	var baseSize, size int64
+	for _, info := range db.aofManifest.files() {
+		stat, err := os.Stat(db.aofFilePath(info))
+		if err != nil {
+			continue
+		}
+		size += stat.Size()
+		if info.fileType == aofFileTypeBase {
+			baseSize = stat.Size()
 		}
 	}
-	// 释放重写缓冲区
-	close(db.aofRewriteBuffer)
-	db.aofRewriteBuffer = nil
-
-	// replace current aof file by tmp file
-	// 使用临时文件代替aof文件
-	_ = db.aofFile.Close()
-	_ = os.Rename(tmpFile.Name(), db.aofFilename)
+	atomic.StoreInt64(&db.aofCurrentSize, size)
+	atomic.StoreInt64(&db.aofBaseSize, baseSize)
+}
 
-	// reopen aof file for further write
-	// 重新打开文件描述符以保证正常写入
-	aofFile, err := os.OpenFile(db.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
+// syncDir fsyncs directory so that renaming in it is persisted
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	db.aofFile = aofFile
-}
\ No newline at end of file
+	defer d.Close()
+	return d.Sync()
+}
+
+func init() {
+	RegisterCommand("BgRewriteAof", execBgRewriteAof, noPrepare, nil, 1)
+}
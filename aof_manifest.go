@@ -0,0 +1,241 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/rdb"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+/*
+ * Multi-part aof, compatible with redis 7:
+ * aof 由 appenddirname 目录下的多个文件组成, manifest 文件记录了它们的加载顺序:
+ *   file appendonly.aof.1.base.rdb seq 1 type b
+ *   file appendonly.aof.1.incr.aof seq 1 type i
+ *   file appendonly.aof.2.incr.aof seq 2 type i
+ * base 文件是重写生成的快照 (rdb 格式或命令), incr 文件是其后追加的命令, 只有最后一个 incr 文件会被写入.
+ * 重写开始时切换到新的 incr 文件, 重写完成后用新的 base 文件和切换后的 incr 文件生成新的 manifest,
+ * manifest 通过 rename 原子地替换, 所以任何时刻宕机都能从 manifest 恢复完整的数据, 也不再需要重写缓冲区.
+ */
+
+const (
+	aofFileTypeBase = "b"
+	aofFileTypeIncr = "i"
+
+	aofManifestSuffix = ".manifest"
+	aofBaseSuffix     = ".base"
+	aofIncrSuffix     = ".incr"
+	aofFormatSuffix   = ".aof"
+	rdbFormatSuffix   = ".rdb"
+)
+
+// aofInfo describes a file of multi-part aof
+type aofInfo struct {
+	filename string
+	seq      int64
+	fileType string
+}
+
+// aofManifest lists files of multi-part aof
+type aofManifest struct {
+	// nil if aof has never been rewritten
+	base  *aofInfo
+	incrs []*aofInfo
+	// the largest sequence number ever used
+	currBaseSeq int64
+	currIncrSeq int64
+}
+
+// files returns files in loading order
+func (m *aofManifest) files() []*aofInfo {
+	files := make([]*aofInfo, 0, len(m.incrs)+1)
+	if m.base != nil {
+		files = append(files, m.base)
+	}
+	return append(files, m.incrs...)
+}
+
+// lastIncr returns the incr file being written, nil if there is none
+func (m *aofManifest) lastIncr() *aofInfo {
+	if len(m.incrs) == 0 {
+		return nil
+	}
+	return m.incrs[len(m.incrs)-1]
+}
+
+func (m *aofManifest) clone() *aofManifest {
+	c := *m
+	c.incrs = append([]*aofInfo{}, m.incrs...)
+	return &c
+}
+
+func (m *aofManifest) encode() []byte {
+	buf := &bytes.Buffer{}
+	for _, info := range m.files() {
+		buf.WriteString("file " + info.filename +
+			" seq " + strconv.FormatInt(info.seq, 10) +
+			" type " + info.fileType + "\n")
+	}
+	return buf.Bytes()
+}
+
+// parseAofManifest reads manifest, each line is key-value pairs of a file
+func parseAofManifest(reader io.Reader) (*aofManifest, error) {
+	m := &aofManifest{}
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields)%2 != 0 {
+			return nil, errors.New("invalid aof manifest line: " + line)
+		}
+		info := &aofInfo{}
+		for i := 0; i < len(fields); i += 2 {
+			switch fields[i] {
+			case "file":
+				info.filename = fields[i+1]
+			case "seq":
+				seq, err := strconv.ParseInt(fields[i+1], 10, 64)
+				if err != nil {
+					return nil, errors.New("invalid aof manifest line: " + line)
+				}
+				info.seq = seq
+			case "type":
+				info.fileType = fields[i+1]
+			}
+		}
+		// files must be in aof dir
+		if info.filename == "" || info.fileType == "" || strings.ContainsAny(info.filename, `/\`) {
+			return nil, errors.New("invalid aof manifest line: " + line)
+		}
+		switch info.fileType {
+		case aofFileTypeBase:
+			if m.base != nil {
+				return nil, errors.New("found duplicate base file in aof manifest")
+			}
+			m.base = info
+			m.currBaseSeq = info.seq
+		case aofFileTypeIncr:
+			if info.seq <= m.currIncrSeq {
+				return nil, errors.New("incr files in aof manifest are out of order")
+			}
+			m.incrs = append(m.incrs, info)
+			m.currIncrSeq = info.seq
+		default:
+			// history files of redis are waiting to be deleted, ignore them
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (db *DB) aofManifestPath() string {
+	return filepath.Join(db.aofDirname, db.aofFilename+aofManifestSuffix)
+}
+
+func (db *DB) aofFilePath(info *aofInfo) string {
+	return filepath.Join(db.aofDirname, info.filename)
+}
+
+func (db *DB) makeBaseAofInfo(seq int64, isRdb bool) *aofInfo {
+	suffix := aofFormatSuffix
+	if isRdb {
+		suffix = rdbFormatSuffix
+	}
+	return &aofInfo{
+		filename: db.aofFilename + "." + strconv.FormatInt(seq, 10) + aofBaseSuffix + suffix,
+		seq:      seq,
+		fileType: aofFileTypeBase,
+	}
+}
+
+func (db *DB) makeIncrAofInfo(seq int64) *aofInfo {
+	return &aofInfo{
+		filename: db.aofFilename + "." + strconv.FormatInt(seq, 10) + aofIncrSuffix + aofFormatSuffix,
+		seq:      seq,
+		fileType: aofFileTypeIncr,
+	}
+}
+
+// openAofManifest creates aof dir and reads manifest into db.aofManifest.
+// A single aof file of old version is moved into aof dir as base file
+func (db *DB) openAofManifest(legacyFilename string) error {
+	if err := os.MkdirAll(db.aofDirname, 0755); err != nil {
+		return err
+	}
+	file, err := os.Open(db.aofManifestPath())
+	if err == nil {
+		defer file.Close()
+		m, err := parseAofManifest(file)
+		if err != nil {
+			return err
+		}
+		db.aofManifest = m
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	db.aofManifest = &aofManifest{}
+	if info, err := os.Stat(legacyFilename); err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+	// upgrade from single aof file
+	header := make([]byte, len(rdb.Magic))
+	legacy, err := os.Open(legacyFilename)
+	if err != nil {
+		return err
+	}
+	n, _ := io.ReadFull(legacy, header)
+	_ = legacy.Close()
+	m := &aofManifest{}
+	m.base = db.makeBaseAofInfo(1, string(header[:n]) == rdb.Magic)
+	m.currBaseSeq = 1
+	// 先链接再删除, 任何时刻宕机都至少保留一份可用的 aof
+	baseFilename := db.aofFilePath(m.base)
+	_ = os.Remove(baseFilename)
+	if err := os.Link(legacyFilename, baseFilename); err != nil {
+		return err
+	}
+	if err := db.persistAofManifest(m); err != nil {
+		return err
+	}
+	db.aofManifest = m
+	return os.Remove(legacyFilename)
+}
+
+// persistAofManifest replaces manifest file atomically
+func (db *DB) persistAofManifest(m *aofManifest) error {
+	file, err := ioutil.TempFile(db.aofDirname, db.aofFilename+aofManifestSuffix+".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(m.encode())
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), db.aofManifestPath())
+	}
+	if err != nil {
+		_ = os.Remove(file.Name())
+		return fmt.Errorf("persist aof manifest: %v", err)
+	}
+	return syncDir(db.aofDirname)
+}
@@ -0,0 +1,135 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/config"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/rdb"
+	"JZ_Redis/redis/reply/asserts"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+)
+
+func TestParseAofManifest(t *testing.T) {
+	content := "file a.aof.2.base.rdb seq 2 type b\n" +
+		"file a.aof.1.base.aof seq 1 type h\n" +
+		"file a.aof.3.incr.aof seq 3 type i\n" +
+		"file a.aof.4.incr.aof seq 4 type i\n"
+	m, err := parseAofManifest(strings.NewReader(content))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if m.base == nil || m.base.filename != "a.aof.2.base.rdb" || m.currBaseSeq != 2 {
+		t.Errorf("unexpected base %+v", m.base)
+	}
+	if len(m.incrs) != 2 || m.lastIncr().filename != "a.aof.4.incr.aof" || m.currIncrSeq != 4 {
+		t.Errorf("unexpected incr files %+v", m.incrs)
+	}
+	// history file is dropped
+	expected := "file a.aof.2.base.rdb seq 2 type b\n" +
+		"file a.aof.3.incr.aof seq 3 type i\n" +
+		"file a.aof.4.incr.aof seq 4 type i\n"
+	if string(m.encode()) != expected {
+		t.Errorf("expected %q, actually %q", expected, m.encode())
+	}
+
+	for _, bad := range []string{
+		"file a.aof.1.incr.aof seq 1\n",
+		"file ../a.aof.1.incr.aof seq 1 type i\n",
+		"file a.aof.1.incr.aof seq x type i\n",
+		"file a.aof.2.incr.aof seq 2 type i\nfile a.aof.1.incr.aof seq 1 type i\n",
+		"file a.aof.1.base.aof seq 1 type b\nfile a.aof.2.base.aof seq 2 type b\n",
+	} {
+		if _, err := parseAofManifest(strings.NewReader(bad)); err == nil {
+			t.Errorf("expect error for %q", bad)
+		}
+	}
+}
+
+func TestAofCrashDuringRewrite(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: path.Join(tmpDir, "a.aof"),
+	}
+	aofDB := MakeDB()
+	aofDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	if err := aofDB.aofRewrite(); err != nil {
+		t.Error(err)
+		return
+	}
+	aofDB.Exec(nil, utils.ToCmdLine("set", "b", "2"))
+	// crash after switching to new incr file, before new base file is used
+	if _, _, err := aofDB.startRewrite(); err != nil {
+		t.Error(err)
+		return
+	}
+	aofDB.Exec(nil, utils.ToCmdLine("set", "c", "3"))
+	aofDB.Close()
+
+	manifest, err := ioutil.ReadFile(path.Join(tmpDir, defaultAofDirname, "a.aof.manifest"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := "file a.aof.1.base.aof seq 1 type b\n" +
+		"file a.aof.2.incr.aof seq 2 type i\n" +
+		"file a.aof.3.incr.aof seq 3 type i\n"
+	if string(manifest) != expected {
+		t.Errorf("expected %q, actually %q", expected, manifest)
+	}
+	aofDB = MakeDB()
+	defer aofDB.Close()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")), "1")
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "b")), "2")
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "c")), "3")
+}
+
+func TestUpgradeSingleAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	// single aof file with rdb preamble
+	buf := &bytes.Buffer{}
+	encoder := rdb.NewEncoder(buf)
+	_ = encoder.WriteHeader(nil)
+	_ = encoder.WriteDBHeader(0, 1, 0)
+	_ = encoder.WriteStringObject("a", []byte("1"), 0)
+	_ = encoder.WriteEnd()
+	buf.Write(makeAofCmd("set", utils.ToCmdLine("b", "2")).ToBytes())
+	aofFilename := path.Join(tmpDir, "a.aof")
+	if err := ioutil.WriteFile(aofFilename, buf.Bytes(), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+	}
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")), "1")
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "b")), "2")
+	if _, err := os.Stat(aofFilename); !os.IsNotExist(err) {
+		t.Errorf("expect single aof file moved, actually %v", err)
+	}
+	if _, err := os.Stat(path.Join(tmpDir, defaultAofDirname, "a.aof.1.base.rdb")); err != nil {
+		t.Error(err)
+	}
+}
@@ -2,10 +2,19 @@ package JZ_Redis
 
 import (
 	"JZ_Redis/config"
+	"JZ_Redis/datastruct/dict"
+	"JZ_Redis/datastruct/lock"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path"
 	"strconv"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -18,7 +27,7 @@ func TestAof(t *testing.T) {
 	}
 	aofFilename := path.Join(tmpDir, "a.aof")
 	defer func() {
-		_ = os.Remove(aofFilename)
+		_ = os.RemoveAll(tmpDir)
 	}()
 	config.Properties = &config.ServerProperties{
 		AppendOnly:     true,
@@ -88,7 +97,7 @@ func TestRewriteAOF(t *testing.T) {
 	}
 	aofFilename := path.Join(tmpDir, "a.aof")
 	defer func() {
-		_ = os.Remove(aofFilename)
+		_ = os.RemoveAll(tmpDir)
 	}()
 	config.Properties = &config.ServerProperties{
 		AppendOnly:     true,
@@ -177,3 +186,514 @@ func TestRewriteAOF(t *testing.T) {
 	aofReadDB.Close()
 }
 
+func TestLoadTruncatedAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	valid := append(makeAofCmd("set", utils.ToCmdLine("a", "1")).ToBytes(),
+		makeAofCmd("set", utils.ToCmdLine("b", "2")).ToBytes()...)
+	partial := makeAofCmd("set", utils.ToCmdLine("c", "3")).ToBytes()
+	partial = partial[:len(partial)-3]
+	if err := ioutil.WriteFile(aofFilename, append(valid, partial...), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// refuse to load
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+	}
+	db := &DB{
+		data:        dict.MakeSimple(),
+		ttlMap:      dict.MakeSimple(),
+		versionMap:  dict.MakeSimple(),
+		locker:      lock.Make(lockerSize),
+		blocking:    makeBlockingQueues(),
+		aofFilename: "a.aof",
+		aofDirname:  path.Join(tmpDir, defaultAofDirname),
+	}
+	// single aof file is moved into aof dir as base file
+	if err := db.openAofManifest(aofFilename); err != nil {
+		t.Error(err)
+		return
+	}
+	err = db.loadAof()
+	if err == nil || !strings.Contains(err.Error(), "offset "+strconv.Itoa(len(valid))) {
+		t.Errorf("expect truncated error at offset %d, actually %v", len(valid), err)
+	}
+
+	// truncate and load
+	config.Properties.AofLoadTruncated = true
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "b")), "2")
+	asserts.AssertNullBulk(t, aofDB.Exec(nil, utils.ToCmdLine("get", "c")))
+	info, err := os.Stat(path.Join(tmpDir, defaultAofDirname, "a.aof.1.base.aof"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if info.Size() != int64(len(valid)) {
+		t.Errorf("expect aof size %d, actually %d", len(valid), info.Size())
+	}
+}
+
+func TestLoadCorruptedAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	valid := makeAofCmd("set", utils.ToCmdLine("a", "1")).ToBytes()
+	tests := map[string]string{
+		"bad format":      "*2\r\n$3\r\nget\r\n",
+		"unknown command": "*2\r\n$3\r\nfoo\r\n$1\r\na\r\n",
+		"wrong arity":     "*1\r\n$3\r\nset\r\n",
+		"huge length":     "*1\r\n$9223372036854775807\r\n",
+	}
+	for name, bad := range tests {
+		data := append(append([]byte{}, valid...), bad...)
+		data = append(data, valid...)
+		if err := ioutil.WriteFile(aofFilename, data, 0600); err != nil {
+			t.Error(err)
+			return
+		}
+		// manifest of the previous case is removed so that aofFilename is loaded again
+		_ = os.RemoveAll(path.Join(tmpDir, defaultAofDirname))
+		config.Properties = &config.ServerProperties{
+			AppendOnly:       true,
+			AppendFilename:   aofFilename,
+			AofLoadTruncated: true,
+		}
+		func() {
+			defer func() {
+				err := recover()
+				if err == nil || !strings.Contains(fmt.Sprint(err), "offset "+strconv.Itoa(len(valid))) {
+					t.Errorf("%s: expect corruption at offset %d, actually %v", name, len(valid), err)
+				}
+			}()
+			MakeDB()
+		}()
+	}
+}
+
+func TestLoadAofExecutorPanic(t *testing.T) {
+	// executor panics if arity is not checked
+	err := testDB.loadAofCommand(utils.ToCmdLine("set"))
+	if err == nil {
+		t.Error("expect error of panicked executor")
+	}
+}
+
+func TestAofFsyncAlways(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+		AppendFsync:    "always",
+	}
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	// aof has been written when command returns
+	aofDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	data, err := ioutil.ReadFile(path.Join(tmpDir, defaultAofDirname, "a.aof.1.incr.aof"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := makeAofCmd("set", utils.ToCmdLine("a", "1")).ToBytes()
+	if !utils.BytesEquals(data, expected) {
+		t.Errorf("expected %q, actually %q", expected, data)
+	}
+	if lag := aofDB.aofFsyncLag(); lag != 0 {
+		t.Errorf("expect no fsync lag, actually %v", lag)
+	}
+}
+
+func TestParseAppendFsync(t *testing.T) {
+	cases := map[string]string{
+		"always":   aofFsyncAlways,
+		"EVERYSEC": aofFsyncEverySec,
+		"no":       aofFsyncNo,
+		"":         aofFsyncEverySec,
+		"sometime": aofFsyncEverySec,
+	}
+	for policy, expected := range cases {
+		if actual := parseAppendFsync(policy); actual != expected {
+			t.Errorf("%s: expected %s, actually %s", policy, expected, actual)
+		}
+	}
+}
+
+// waitRewrite waits until no rewrite is in progress
+func waitRewrite(t *testing.T, db *DB) {
+	for i := 0; i < 100; i++ {
+		if db.aofRewriting.currentDuration() < 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("rewrite timeout")
+}
+
+func TestBgRewriteAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+	}
+	aofDB := MakeDB()
+	for i := 0; i < 10; i++ {
+		aofDB.Exec(nil, utils.ToCmdLine("set", "a", strconv.Itoa(i)))
+	}
+	result := aofDB.Exec(nil, utils.ToCmdLine("info", "persistence"))
+	if !strings.Contains(string(result.ToBytes()), "aof_last_rewrite_time_sec:-1\r\n") {
+		t.Errorf("unexpected info %s", result.ToBytes())
+	}
+
+	// only one rewrite at the same time
+	aofDB.aofRewriting.begin()
+	result = aofDB.Exec(nil, utils.ToCmdLine("bgrewriteaof"))
+	asserts.AssertErrReply(t, result, "ERR Background append only file rewriting already in progress")
+	result = aofDB.Exec(nil, utils.ToCmdLine("info"))
+	if !strings.Contains(string(result.ToBytes()), "aof_rewrite_in_progress:1\r\n") {
+		t.Errorf("unexpected info %s", result.ToBytes())
+	}
+	aofDB.aofRewriting.end(nil)
+
+	time.Sleep(100 * time.Millisecond) // wait for async goroutine finish its job
+	result = aofDB.Exec(nil, utils.ToCmdLine("bgrewriteaof"))
+	asserts.AssertStatusReply(t, result, "Background append only file rewriting started")
+	waitRewrite(t, aofDB)
+	result = aofDB.Exec(nil, utils.ToCmdLine("info", "persistence"))
+	info := string(result.ToBytes())
+	expectedSize := len(makeAofCmd("set", utils.ToCmdLine("a", "9")).ToBytes())
+	for _, field := range []string{
+		"aof_rewrite_in_progress:0\r\n",
+		"aof_last_bgrewrite_status:ok\r\n",
+		"aof_base_size:" + strconv.Itoa(expectedSize) + "\r\n",
+	} {
+		if !strings.Contains(info, field) {
+			t.Errorf("expect %q in info %s", field, info)
+		}
+	}
+	aofDB.Close()
+
+	aofDB = MakeDB()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")), "9")
+	aofDB.Close()
+}
+
+func TestCloseDuringRewrite(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+	}
+	aofDB := MakeDB()
+	aofDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	// rewrite waits for the writer holding lock
+	aofDB.locker.Lock("a")
+	asserts.AssertStatusReply(t, aofDB.Exec(nil, utils.ToCmdLine("bgrewriteaof")), "Background append only file rewriting started")
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		aofDB.Close()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	aofDB.locker.UnLock("a")
+	<-closed
+	if err := aofDB.bgRewriteAof(); err != errRewriteOnClosing {
+		t.Errorf("expect no rewrite after closing, actually %v", err)
+	}
+
+	aofDB = MakeDB()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")), "1")
+	aofDB.Close()
+}
+
+func TestAutoRewriteAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:               true,
+		AppendFilename:           aofFilename,
+		AutoAofRewritePercentage: 100,
+		AutoAofRewriteMinSize:    1024,
+	}
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	for i := 0; i < 100; i++ {
+		aofDB.Exec(nil, utils.ToCmdLine("set", "a", strconv.Itoa(i)))
+	}
+	// give aof goroutine a chance to write
+	time.Sleep(100 * time.Millisecond)
+	waitRewrite(t, aofDB)
+	if atomic.LoadInt64(&aofDB.aofBaseSize) == 0 {
+		t.Error("expect aof rewritten automatically")
+	}
+	if size := atomic.LoadInt64(&aofDB.aofCurrentSize); size >= 1024*2 {
+		t.Errorf("expect aof shrunk by rewrite, actually %d bytes", size)
+	}
+}
+
+func TestRewriteAofSnapshot(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+	}
+	aofDB := MakeDB()
+	aofDB.Exec(nil, utils.ToCmdLine("set", "str", "1", "ex", "1000"))
+	aofDB.Exec(nil, utils.ToCmdLine("set", "expired", "1", "px", "1"))
+	time.Sleep(10 * time.Millisecond)
+
+	// writes during rewrite are neither lost nor applied twice
+	count := 1000
+	finished := make(chan struct{})
+	go func() {
+		for i := 0; i < count; i++ {
+			aofDB.Exec(nil, utils.ToCmdLine("rpush", "list", strconv.Itoa(i)))
+			aofDB.Exec(nil, utils.ToCmdLine("incr", "counter"))
+		}
+		close(finished)
+	}()
+	for i := 0; i < 3; i++ {
+		if err := aofDB.aofRewrite(); err != nil {
+			t.Error(err)
+		}
+	}
+	<-finished
+	baseFilename := aofDB.aofFilePath(aofDB.aofManifest.base)
+	aofDB.Close()
+
+	// old files and temp files are removed, only manifest, base file and the last incr file are left
+	files, err := ioutil.ReadDir(path.Join(tmpDir, defaultAofDirname))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(files) != 3 {
+		t.Errorf("expect 3 files in aof dir, actually %d files", len(files))
+	}
+	data, err := ioutil.ReadFile(baseFilename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// ttl follows its entity
+	if !strings.Contains(string(data), "\r\n$3\r\nstr\r\n$1\r\n1\r\n*3\r\n$9\r\nPEXPIREAT\r\n$3\r\nstr\r\n") {
+		t.Errorf("expect PEXPIREAT right after entity, actually %q", data)
+	}
+	if strings.Contains(string(data), "$7\r\nexpired\r\n$1\r\n1\r\n") {
+		t.Error("expired key should not be rewritten")
+	}
+
+	aofDB = MakeDB()
+	defer aofDB.Close()
+	asserts.AssertIntReply(t, aofDB.Exec(nil, utils.ToCmdLine("llen", "list")), count)
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "counter")), strconv.Itoa(count))
+	result := aofDB.Exec(nil, utils.ToCmdLine("ttl", "str"))
+	if intResult, ok := result.(*reply.IntReply); !ok || intResult.Code <= 0 {
+		t.Errorf("expect positive ttl, actually %s", result.ToBytes())
+	}
+}
+
+func TestRdbPreambleAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:        true,
+		AppendFilename:    aofFilename,
+		AofUseRdbPreamble: true,
+		AofLoadTruncated:  true,
+	}
+	aofDB := MakeDB()
+	aofDB.Exec(nil, utils.ToCmdLine("set", "str", "a"))
+	aofDB.Exec(nil, utils.ToCmdLine("expire", "str", "1000"))
+	aofDB.Exec(nil, utils.ToCmdLine("rpush", "list", "a", "b"))
+	aofDB.Exec(nil, utils.ToCmdLine("hset", "hash", "f", "v"))
+	aofDB.Exec(nil, utils.ToCmdLine("zadd", "zset", "1", "a"))
+	if err := aofDB.aofRewrite(); err != nil {
+		t.Error(err)
+		return
+	}
+	// commands after rewrite are appended into incr file
+	aofDB.Exec(nil, utils.ToCmdLine("rpush", "list", "c"))
+	aofDB.Exec(nil, utils.ToCmdLine("sadd", "set", "x"))
+	aofDB.Close()
+
+	aofDir := path.Join(tmpDir, defaultAofDirname)
+	base, err := ioutil.ReadFile(path.Join(aofDir, "a.aof.1.base.rdb"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !strings.HasPrefix(string(base), "REDIS") {
+		t.Errorf("expect rdb base file, actually %q", base[:10])
+	}
+	incrFilename := path.Join(aofDir, "a.aof.2.incr.aof")
+	data, err := ioutil.ReadFile(incrFilename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	// a truncated command at tail is removed
+	partial := makeAofCmd("set", utils.ToCmdLine("c", "3")).ToBytes()
+	if err := ioutil.WriteFile(incrFilename, append(data, partial[:len(partial)-3]...), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+
+	aofDB = MakeDB()
+	defer aofDB.Close()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "str")), "a")
+	asserts.AssertMultiBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("lrange", "list", "0", "-1")), []string{"a", "b", "c"})
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("hget", "hash", "f")), "v")
+	asserts.AssertIntReply(t, aofDB.Exec(nil, utils.ToCmdLine("zcard", "zset")), 1)
+	asserts.AssertIntReply(t, aofDB.Exec(nil, utils.ToCmdLine("sismember", "set", "x")), 1)
+	asserts.AssertNullBulk(t, aofDB.Exec(nil, utils.ToCmdLine("get", "c")))
+	if ttl, ok := aofDB.Exec(nil, utils.ToCmdLine("ttl", "str")).(*reply.IntReply); !ok || ttl.Code <= 0 {
+		t.Error("expect ttl of str restored")
+	}
+	info, err := os.Stat(incrFilename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if info.Size() != int64(len(data)) {
+		t.Errorf("expect aof size %d, actually %d", len(data), info.Size())
+	}
+}
+
+func TestAofLazyExpire(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+		AppendFsync:    "always",
+	}
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	aofDB.Exec(nil, utils.ToCmdLine("set", "a", "1", "PX", "10"))
+	time.Sleep(20 * time.Millisecond)
+	// removed by reading or by active expiration, the deletion is persisted only once
+	asserts.AssertNullBulk(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")))
+	data, err := ioutil.ReadFile(path.Join(tmpDir, defaultAofDirname, "a.aof.1.incr.aof"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	del := reply.MakeMultiBulkReply(utils.ToCmdLine("del", "a")).ToBytes()
+	if n := strings.Count(string(data), string(del)); n != 1 {
+		t.Errorf("expect 1 del in aof, actually %d: %q", n, data)
+	}
+}
+
+func TestAofRollback(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	aofFilename := path.Join(tmpDir, "a.aof")
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		AppendOnly:     true,
+		AppendFilename: aofFilename,
+		AppendFsync:    "always",
+	}
+	aofDB := MakeDB()
+	defer aofDB.Close()
+	aofDB.Exec(nil, utils.ToCmdLine("rpush", "list", "a"))
+	conn := connection.NewFakeConn()
+	aofDB.Exec(conn, utils.ToCmdLine("multi"))
+	aofDB.Exec(conn, utils.ToCmdLine("set", "str", "1"))
+	aofDB.Exec(conn, utils.ToCmdLine("lpush", "list", "b"))
+	aofDB.Exec(conn, utils.ToCmdLine("incr", "list"))
+	result := aofDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertErrReply(t, result, "EXECABORT Transaction rolled back: WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	aofDB.Exec(conn, utils.ToCmdLine("multi"))
+	aofDB.Exec(conn, utils.ToCmdLine("lpush", "list", "c"))
+	aofDB.Exec(conn, utils.ToCmdLine("exec"))
+
+	// neither the aborted transaction nor its rollback is persisted
+	data, err := ioutil.ReadFile(path.Join(tmpDir, defaultAofDirname, "a.aof.1.incr.aof"))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := string(reply.MakeMultiBulkReply(utils.ToCmdLine("rpush", "list", "a")).ToBytes()) +
+		string(reply.MakeMultiBulkReply(utils.ToCmdLine("lpush", "list", "c")).ToBytes())
+	if string(data) != expected {
+		t.Errorf("expected aof %q, actually %q", expected, data)
+	}
+}
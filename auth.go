@@ -0,0 +1,35 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/config"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+)
+
+// Auth validate client's password
+func Auth(db *DB, c redis.Connection, args [][]byte) redis.Reply {
+	if len(args) != 1 {
+		return reply.MakeArgNumErrReply("auth")
+	}
+	if config.Properties.RequirePass == "" {
+		return reply.MakeErrReply("ERR Client sent AUTH, but no password is set")
+	}
+	passwd := string(args[0])
+	// a wrong password doesn't revoke previous authentication
+	if config.Properties.RequirePass != passwd {
+		return reply.MakeErrReply("ERR invalid password")
+	}
+	if c != nil {
+		c.SetPassword(passwd)
+	}
+	return &reply.OkReply{}
+}
+
+// isAuthenticated returns true if no password is required or the connection has sent the right one,
+// nil connection means internal invoker such as aof loading, it is always authenticated
+func isAuthenticated(c redis.Connection) bool {
+	if config.Properties.RequirePass == "" || c == nil {
+		return true
+	}
+	return c.GetPassword() == config.Properties.RequirePass
+}
@@ -0,0 +1,31 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/config"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply/asserts"
+	"testing"
+)
+
+func TestAuth(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("auth", "a")), "ERR Client sent AUTH, but no password is set")
+
+	passwd := config.Properties.RequirePass
+	config.Properties.RequirePass = "secret"
+	defer func() {
+		config.Properties.RequirePass = passwd
+	}()
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("get", "a")), "NOAUTH Authentication required")
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("auth", "wrong")), "ERR invalid password")
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("get", "a")), "NOAUTH Authentication required")
+	asserts.AssertStatusReply(t, testDB.Exec(conn, utils.ToCmdLine("auth", "secret")), "OK")
+	asserts.AssertNullBulk(t, testDB.Exec(conn, utils.ToCmdLine("get", "a")))
+	// wrong password doesn't revoke authentication
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("auth", "wrong")), "ERR invalid password")
+	asserts.AssertNullBulk(t, testDB.Exec(conn, utils.ToCmdLine("get", "a")))
+	// internal invoker without connection
+	asserts.AssertStatusReply(t, testDB.Exec(nil, utils.ToCmdLine("set", "a", "1")), "OK")
+}
@@ -0,0 +1,100 @@
+package JZ_Redis
+
+import (
+	"sync"
+	"time"
+)
+
+// bgJobState records progress of a background persistence job such as aof rewrite or bgsave,
+// it guards against concurrent runs of the same job and is shown in INFO persistence
+type bgJobState struct {
+	mu         sync.Mutex
+	inProgress bool
+	startTime  time.Time
+	// ok or err, ok if never run
+	lastStatus string
+	// -1 if never run
+	lastDuration time.Duration
+	// finish time of the last successful run, server start time if never succeeded
+	lastSuccess time.Time
+	// done when the running job finished
+	running sync.WaitGroup
+}
+
+func makeBgJobState() *bgJobState {
+	return &bgJobState{
+		lastStatus:   "ok",
+		lastDuration: -1,
+		lastSuccess:  time.Now(),
+	}
+}
+
+// begin returns false if there is another job in progress
+func (s *bgJobState) begin() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.inProgress {
+		return false
+	}
+	s.inProgress = true
+	s.startTime = time.Now()
+	s.running.Add(1)
+	return true
+}
+
+func (s *bgJobState) end(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.inProgress = false
+	s.lastDuration = time.Since(s.startTime)
+	if err != nil {
+		s.lastStatus = "err"
+	} else {
+		s.lastStatus = "ok"
+		s.lastSuccess = time.Now()
+	}
+	s.running.Done()
+}
+
+// wait blocks until the running job finished
+func (s *bgJobState) wait() {
+	s.running.Wait()
+}
+
+// currentDuration returns time since the running job started, -1 if not running
+func (s *bgJobState) currentDuration() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.inProgress {
+		return -1
+	}
+	return time.Since(s.startTime)
+}
+
+// bgJobStatus is a snapshot of bgJobState
+type bgJobStatus struct {
+	inProgress      bool
+	lastStatus      string
+	lastDuration    time.Duration
+	currentDuration time.Duration
+	// start time of the running or last job
+	lastStart   time.Time
+	lastSuccess time.Time
+}
+
+func (s *bgJobState) status() bgJobStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := bgJobStatus{
+		inProgress:      s.inProgress,
+		lastStatus:      s.lastStatus,
+		lastDuration:    s.lastDuration,
+		currentDuration: -1,
+		lastStart:       s.startTime,
+		lastSuccess:     s.lastSuccess,
+	}
+	if s.inProgress {
+		status.currentDuration = time.Since(s.startTime)
+	}
+	return status
+}
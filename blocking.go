@@ -0,0 +1,513 @@
+package JZ_Redis
+
+import (
+	List "JZ_Redis/datastruct/list"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * Blocking list operations: BLPOP, BRPOP, BRPOPLPUSH, BLMOVE
+ * 若所有 key 均为空, 客户端会被挂起并按顺序加入每个 key 的等待队列(FIFO)
+ * push 命令在持有 key 锁的情况下将新元素弹出并交给等待时间最长的客户端, 因此元素不会被其它客户端抢走
+ * BRPOPLPUSH/BLMOVE 的客户端同样由 push 命令完成 pop 和 push, 所以 push 命令加锁时会一并锁住这些客户端的目标 key,
+ * 元素在两个 list 间的移动是原子的, 并且作为一条 LMOVE 写入 aof
+ */
+
+var blockingCmds = map[string]bool{
+	"blpop":      true,
+	"brpop":      true,
+	"brpoplpush": true,
+	"blmove":     true,
+}
+
+func isBlockingCmd(cmdName string) bool {
+	return blockingCmds[cmdName]
+}
+
+// blockedClient is a client waiting for elements of lists
+type blockedClient struct {
+	conn redis.Connection
+	keys []string
+	// pop from head of list if popLeft is true, otherwise pop from tail
+	popLeft bool
+	// BRPOPLPUSH and BLMOVE push popped element into dest
+	isMove   bool
+	dest     string
+	pushLeft bool
+	// receives popped element, nil means client has been unblocked without element
+	result chan *poppedElement
+	// served or cancelled, guarded by blockingQueues.mu
+	done bool
+}
+
+type poppedElement struct {
+	key   string
+	value []byte
+	// the element is not popped if the dest of BRPOPLPUSH or BLMOVE holds wrong type
+	err reply.ErrorReply
+}
+
+// blockingQueues stores clients blocked on each key in order of blocking time
+type blockingQueues struct {
+	mu sync.Mutex
+	// key -> *List.LinkedList of *blockedClient
+	queues map[string]*List.LinkedList
+	// redis.Connection -> *blockedClient, a connection could be blocked by only one command
+	clients map[redis.Connection]*blockedClient
+	// number of blocked BRPOPLPUSH and BLMOVE clients, accessed atomically
+	moveClients int32
+}
+
+func makeBlockingQueues() *blockingQueues {
+	return &blockingQueues{
+		queues:  make(map[string]*List.LinkedList),
+		clients: make(map[redis.Connection]*blockedClient),
+	}
+}
+
+// block appends client into waiting queues of its keys
+func (q *blockingQueues) block(bc *blockedClient) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for _, key := range bc.keys {
+		queue, ok := q.queues[key]
+		if !ok {
+			queue = List.Make()
+			q.queues[key] = queue
+		}
+		queue.Add(bc)
+	}
+	if bc.conn != nil {
+		q.clients[bc.conn] = bc
+	}
+	if bc.isMove {
+		atomic.AddInt32(&q.moveClients, 1)
+	}
+}
+
+// unblock removes client from all waiting queues, q.mu must be held
+func (q *blockingQueues) unblock(bc *blockedClient) {
+	bc.done = true
+	for _, key := range bc.keys {
+		queue, ok := q.queues[key]
+		if !ok {
+			continue
+		}
+		queue.RemoveAllByVal(bc)
+		if queue.Len() == 0 {
+			delete(q.queues, key)
+		}
+	}
+	if bc.conn != nil {
+		delete(q.clients, bc.conn)
+	}
+	if bc.isMove {
+		atomic.AddInt32(&q.moveClients, -1)
+	}
+}
+
+// hasWaiters returns whether there is any client blocked on the given key
+func (q *blockingQueues) hasWaiters(key string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	_, ok := q.queues[key]
+	return ok
+}
+
+// moveDests returns dest keys of BRPOPLPUSH and BLMOVE clients blocked on the given keys.
+// Serving such a client pushes into its dest, which may serve clients blocked on the dest, so their dest keys are included too
+func (q *blockingQueues) moveDests(keys []string) []string {
+	if atomic.LoadInt32(&q.moveClients) == 0 {
+		return nil
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	var dests []string
+	visited := make(map[string]bool)
+	pending := append([]string{}, keys...)
+	for len(pending) > 0 {
+		key := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if visited[key] {
+			continue
+		}
+		visited[key] = true
+		queue, ok := q.queues[key]
+		if !ok {
+			continue
+		}
+		queue.ForEach(func(i int, v interface{}) bool {
+			bc, _ := v.(*blockedClient)
+			if bc.isMove && !visited[bc.dest] {
+				dests = append(dests, bc.dest)
+				pending = append(pending, bc.dest)
+			}
+			return true
+		})
+	}
+	return dests
+}
+
+// popWaiter unblocks and returns the longest waiting client of the given key
+func (q *blockingQueues) popWaiter(key string) *blockedClient {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	queue, ok := q.queues[key]
+	if !ok {
+		return nil
+	}
+	bc, _ := queue.Get(0).(*blockedClient)
+	q.unblock(bc)
+	return bc
+}
+
+// cancel unblocks client, returns false if it has been served already
+func (q *blockingQueues) cancel(bc *blockedClient) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if bc.done {
+		return false
+	}
+	q.unblock(bc)
+	return true
+}
+
+// cancelConn wakes up the command blocking the given connection
+func (q *blockingQueues) cancelConn(c redis.Connection) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	bc, ok := q.clients[c]
+	if !ok || bc.done {
+		return
+	}
+	q.unblock(bc)
+	bc.result <- nil
+}
+
+// parseBlockingTimeout parses timeout in seconds, 0 means blocking indefinitely
+func parseBlockingTimeout(arg []byte) (time.Duration, reply.ErrorReply) {
+	timeout, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(timeout) || math.IsInf(timeout, 0) {
+		return 0, reply.MakeErrReply("ERR timeout is not a float or out of range")
+	}
+	if timeout < 0 {
+		return 0, reply.MakeErrReply("ERR timeout is negative")
+	}
+	return time.Duration(timeout * float64(time.Second)), nil
+}
+
+// parseListDirection parses LEFT or RIGHT
+func parseListDirection(arg []byte) (left bool, ok bool) {
+	switch strings.ToUpper(string(arg)) {
+	case "LEFT":
+		return true, true
+	case "RIGHT":
+		return false, true
+	}
+	return false, false
+}
+
+// makeBlockedClient parses how the blocking command pops and pushes
+func makeBlockedClient(c redis.Connection, cmdName string, args [][]byte) (*blockedClient, reply.ErrorReply) {
+	bc := &blockedClient{
+		conn:   c,
+		result: make(chan *poppedElement, 1),
+	}
+	switch cmdName {
+	case "blpop", "brpop":
+		bc.popLeft = cmdName == "blpop"
+		seen := make(map[string]bool)
+		for _, arg := range args[:len(args)-1] {
+			key := string(arg)
+			if !seen[key] {
+				seen[key] = true
+				bc.keys = append(bc.keys, key)
+			}
+		}
+	case "brpoplpush":
+		bc.keys = []string{string(args[0])}
+		bc.isMove = true
+		bc.dest = string(args[1])
+		bc.pushLeft = true
+	case "blmove":
+		var ok1, ok2 bool
+		bc.popLeft, ok1 = parseListDirection(args[2])
+		bc.pushLeft, ok2 = parseListDirection(args[3])
+		if !ok1 || !ok2 {
+			return nil, &reply.SyntaxErrReply{}
+		}
+		bc.keys = []string{string(args[0])}
+		bc.isMove = true
+		bc.dest = string(args[1])
+	}
+	return bc, nil
+}
+
+// execBlockingCommand executes command immediately if any list is not empty,
+// otherwise blocks the client until an element is pushed or timeout
+func execBlockingCommand(db *DB, c redis.Connection, cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd := cmdTable[cmdName]
+	if !validateArity(cmd.arity, cmdLine) {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+	args := cmdLine[1:]
+	timeout, errReply := parseBlockingTimeout(args[len(args)-1])
+	if errReply != nil {
+		return errReply
+	}
+	bc, errReply := makeBlockedClient(c, cmdName, args)
+	if errReply != nil {
+		return errReply
+	}
+
+	write, read := cmd.prepare(args)
+	locked := db.lockWithMoveDests(write, read)
+	result := cmd.executor(db, args)
+	if !isEmptyPopResult(result) {
+		// blocked client changes nothing, keys popped later are versioned by the pusher
+		db.addVersion(write...)
+		db.RWUnLocks(locked, read)
+		return result
+	}
+	// block client before releasing locks, so that no push could sneak in before it is queued
+	db.blocking.block(bc)
+	db.RWUnLocks(locked, read)
+	return db.waitBlocked(bc, timeout)
+}
+
+// lockWithMoveDests locks keys like RWLocks, and also locks dest keys of BRPOPLPUSH and BLMOVE clients
+// which may be served by pushing into the write keys, so that the element is moved between two lists atomically.
+// It returns write keys actually locked, which should be passed to RWUnLocks
+func (db *DB) lockWithMoveDests(write []string, read []string) []string {
+	for {
+		locked := append(db.blocking.moveDests(write), write...)
+		db.RWLocks(locked, read)
+		// clients are blocked on a key only while holding its lock, so dest keys can't change once all of them are locked
+		if containsAllKeys(locked, db.blocking.moveDests(write)) {
+			return locked
+		}
+		db.RWUnLocks(locked, read)
+	}
+}
+
+func containsAllKeys(keys []string, subset []string) bool {
+	if len(subset) == 0 {
+		return true
+	}
+	keySet := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keySet[key] = true
+	}
+	for _, key := range subset {
+		if !keySet[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func isEmptyPopResult(result redis.Reply) bool {
+	switch result.(type) {
+	case *reply.NullBulkReply, *reply.NullMultiBulkReply:
+		return true
+	}
+	return false
+}
+
+// waitBlocked waits for element of blocked client
+func (db *DB) waitBlocked(bc *blockedClient, timeout time.Duration) redis.Reply {
+	var timeoutCh <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		timeoutCh = timer.C
+	}
+	select {
+	case elem := <-bc.result:
+		return db.finishBlocked(bc, elem)
+	case <-timeoutCh:
+		if db.blocking.cancel(bc) {
+			return db.finishBlocked(bc, nil)
+		}
+		// served right before timeout
+		return db.finishBlocked(bc, <-bc.result)
+	}
+}
+
+// finishBlocked makes reply for the unblocked client, elements of BRPOPLPUSH and BLMOVE have been pushed into dest
+func (db *DB) finishBlocked(bc *blockedClient, elem *poppedElement) redis.Reply {
+	if elem != nil && elem.err != nil {
+		return elem.err
+	}
+	if !bc.isMove {
+		if elem == nil {
+			return reply.MakeNullMultiBulkReply()
+		}
+		return reply.MakeMultiBulkReply([][]byte{[]byte(elem.key), elem.value})
+	}
+	if elem == nil {
+		return &reply.NullBulkReply{}
+	}
+	return reply.MakeBulkReply(elem.value)
+}
+
+func pushCmdName(left bool) string {
+	if left {
+		return "lpush"
+	}
+	return "rpush"
+}
+
+func popCmdName(left bool) string {
+	if left {
+		return "lpop"
+	}
+	return "rpop"
+}
+
+func directionArg(left bool) []byte {
+	if left {
+		return []byte("LEFT")
+	}
+	return []byte("RIGHT")
+}
+
+func pushElement(list *List.LinkedList, value []byte, left bool) {
+	if left {
+		list.Insert(0, value)
+	} else {
+		list.Add(value)
+	}
+}
+
+// popFromList pops an element from list and writes aof, empty list will be removed
+func (db *DB) popFromList(key string, list *List.LinkedList, left bool) []byte {
+	var val []byte
+	if left {
+		val, _ = list.Remove(0).([]byte)
+	} else {
+		val, _ = list.RemoveLast().([]byte)
+	}
+	db.AddAof(reply.MakeMultiBulkReply(utils.ToCmdLine(popCmdName(left), key)))
+	db.notifyKeyspaceEvent(notifyList, popCmdName(left), key)
+	if list.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return val
+}
+
+// signalListReady hands elements of the list to clients blocked on key, longest waiting first.
+// It should be called after pushing into list, while holding the write lock of key and dest keys from lockWithMoveDests
+func (db *DB) signalListReady(key string) {
+	if !db.blocking.hasWaiters(key) {
+		return
+	}
+	// elements pushed by transaction are not handed out until EXEC succeeds
+	if effects := db.getTxEffects(key); effects != nil {
+		effects.readyKeys = append(effects.readyKeys, key)
+		return
+	}
+	list, errReply := db.getAsList(key)
+	if errReply != nil || list == nil {
+		return
+	}
+	for list.Len() > 0 {
+		bc := db.blocking.popWaiter(key)
+		if bc == nil {
+			return
+		}
+		if bc.isMove {
+			db.serveBlockedMove(bc, key)
+			continue
+		}
+		val := db.popFromList(key, list, bc.popLeft)
+		bc.result <- &poppedElement{key: key, value: val}
+	}
+}
+
+// serveBlockedMove moves an element from key to dest of BRPOPLPUSH or BLMOVE client just like LMOVE,
+// the type of dest is checked before popping
+func (db *DB) serveBlockedMove(bc *blockedClient, key string) {
+	args := [][]byte{[]byte(key), []byte(bc.dest), directionArg(bc.popLeft), directionArg(bc.pushLeft)}
+	result := execLMove(db, args)
+	if errReply, ok := result.(reply.ErrorReply); ok {
+		bc.result <- &poppedElement{key: key, err: errReply}
+		return
+	}
+	db.addVersion(bc.dest)
+	bulk, _ := result.(*reply.BulkReply)
+	bc.result <- &poppedElement{key: key, value: bulk.Arg}
+}
+
+// execBPop pops from the first non-empty list without blocking,
+// used in multi and aof loading, where blocking commands never block
+func execBPop(db *DB, args [][]byte, left bool) redis.Reply {
+	if _, errReply := parseBlockingTimeout(args[len(args)-1]); errReply != nil {
+		return errReply
+	}
+	for _, arg := range args[:len(args)-1] {
+		key := string(arg)
+		list, errReply := db.getAsList(key)
+		if errReply != nil {
+			return errReply
+		}
+		if list == nil {
+			continue
+		}
+		val := db.popFromList(key, list, left)
+		return reply.MakeMultiBulkReply([][]byte{arg, val})
+	}
+	return reply.MakeNullMultiBulkReply()
+}
+
+// execBLPop removes and returns the first element of the first non-empty list, blocks if all lists are empty
+func execBLPop(db *DB, args [][]byte) redis.Reply {
+	return execBPop(db, args, true)
+}
+
+// execBRPop removes and returns the last element of the first non-empty list, blocks if all lists are empty
+func execBRPop(db *DB, args [][]byte) redis.Reply {
+	return execBPop(db, args, false)
+}
+
+func prepareBPop(args [][]byte) ([]string, []string) {
+	return writeAllKeys(args[:len(args)-1])
+}
+
+func undoBPop(db *DB, args [][]byte) []CmdLine {
+	keys, _ := prepareBPop(args)
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execBRPopLPush is the blocking version of RPOPLPUSH
+func execBRPopLPush(db *DB, args [][]byte) redis.Reply {
+	if _, errReply := parseBlockingTimeout(args[2]); errReply != nil {
+		return errReply
+	}
+	return execRPopLPush(db, args[:2])
+}
+
+// execBLMove is the blocking version of LMOVE
+func execBLMove(db *DB, args [][]byte) redis.Reply {
+	if _, errReply := parseBlockingTimeout(args[4]); errReply != nil {
+		return errReply
+	}
+	return execLMove(db, args[:4])
+}
+
+func init() {
+	RegisterCommand("BLPop", execBLPop, prepareBPop, undoBPop, -3)
+	RegisterCommand("BRPop", execBRPop, prepareBPop, undoBPop, -3)
+	RegisterCommand("BRPopLPush", execBRPopLPush, prepareRPopLPush, undoListMove, 4)
+	RegisterCommand("BLMove", execBLMove, prepareRPopLPush, undoListMove, 6)
+}
@@ -0,0 +1,277 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"testing"
+	"time"
+)
+
+// waitBlocking waits until n clients are blocked on key
+func waitBlocking(t *testing.T, key string, n int) {
+	for i := 0; i < 100; i++ {
+		testDB.blocking.mu.Lock()
+		queue, ok := testDB.blocking.queues[key]
+		size := 0
+		if ok {
+			size = queue.Len()
+		}
+		testDB.blocking.mu.Unlock()
+		if size == n {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d clients blocked on %s", n, key)
+}
+
+func TestBLPop(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key2, "a", "b"))
+	result := testDB.Exec(nil, utils.ToCmdLine("blpop", key1, key2, "1"))
+	asserts.AssertMultiBulkReply(t, result, []string{key2, "a"})
+	result = testDB.Exec(nil, utils.ToCmdLine("brpop", key1, key2, "1"))
+	asserts.AssertMultiBulkReply(t, result, []string{key2, "b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key2))
+	asserts.AssertIntReply(t, result, 0)
+
+	// block then served by push
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("blpop", key1, key2, "0"))
+		asserts.AssertMultiBulkReply(t, result, []string{key2, "c"})
+	}()
+	waitBlocking(t, key2, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("rpush", key2, "c"))
+	asserts.AssertIntReply(t, result, 1)
+	<-done
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key2))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("blpop", key1, "-1"))
+	asserts.AssertErrReply(t, result, "ERR timeout is negative")
+	result = testDB.Exec(nil, utils.ToCmdLine("blpop", key1, "a"))
+	asserts.AssertErrReply(t, result, "ERR timeout is not a float or out of range")
+}
+
+func TestBLPopTimeout(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	start := time.Now()
+	result := testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0.1"))
+	if _, ok := result.(*reply.NullMultiBulkReply); !ok {
+		t.Errorf("expected null multi bulk, actually %s", result.ToBytes())
+	}
+	if time.Since(start) < 100*time.Millisecond {
+		t.Error("returned before timeout")
+	}
+	if testDB.blocking.hasWaiters(key) {
+		t.Error("timeout client should be removed from queue")
+	}
+}
+
+func TestBLPopFIFO(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	results := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			result := testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0"))
+			results <- string(result.(*reply.MultiBulkReply).Args[1])
+		}()
+		// make sure the first goroutine blocks first
+		waitBlocking(t, key, i+1)
+	}
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "first"))
+	if v := <-results; v != "first" {
+		t.Errorf("expected longest waiting client served first, actually %s", v)
+	}
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "second"))
+	if v := <-results; v != "second" {
+		t.Errorf("expected second, actually %s", v)
+	}
+}
+
+func TestBRPopLPush(t *testing.T) {
+	testDB.Flush()
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("brpoplpush", src, dest, "0"))
+		asserts.AssertBulkReply(t, result, "b")
+	}()
+	waitBlocking(t, src, 1)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", src, "a", "b"))
+	<-done
+	result := testDB.Exec(nil, utils.ToCmdLine("lrange", src, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a"})
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", dest, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b"})
+}
+
+func TestBLMove(t *testing.T) {
+	testDB.Flush()
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", dest, "x"))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("blmove", src, dest, "LEFT", "RIGHT", "0"))
+		asserts.AssertBulkReply(t, result, "a")
+	}()
+	waitBlocking(t, src, 1)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", src, "a", "b"))
+	<-done
+	result := testDB.Exec(nil, utils.ToCmdLine("lrange", dest, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"x", "a"})
+
+	result = testDB.Exec(nil, utils.ToCmdLine("blmove", src, dest, "UP", "RIGHT", "0"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+	result = testDB.Exec(nil, utils.ToCmdLine("lmove", src, dest, "RIGHT", "LEFT"))
+	asserts.AssertBulkReply(t, result, "b")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", src))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestBLMoveAtomic(t *testing.T) {
+	testDB.Flush()
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("blmove", src, dest, "LEFT", "LEFT", "0"))
+		asserts.AssertBulkReply(t, result, "a")
+	}()
+	waitBlocking(t, src, 1)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", src, "a"))
+	// element has been moved when push returns
+	result := testDB.Exec(nil, utils.ToCmdLine("lrange", dest, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a"})
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", src))
+	asserts.AssertIntReply(t, result, 0)
+	<-done
+
+	// dest holding wrong type is checked before popping
+	testDB.Exec(nil, utils.ToCmdLine("set", dest, "1"))
+	done = make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("brpoplpush", src, dest, "0"))
+		asserts.AssertErrReply(t, result, "WRONGTYPE Operation against a key holding the wrong kind of value")
+	}()
+	waitBlocking(t, src, 1)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", src, "b"))
+	<-done
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", src, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("get", dest))
+	asserts.AssertBulkReply(t, result, "1")
+}
+
+func TestBLMoveChain(t *testing.T) {
+	testDB.Flush()
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	moved := make(chan struct{})
+	go func() {
+		defer close(moved)
+		result := testDB.Exec(nil, utils.ToCmdLine("brpoplpush", src, dest, "0"))
+		asserts.AssertBulkReply(t, result, "a")
+	}()
+	waitBlocking(t, src, 1)
+	popped := make(chan struct{})
+	go func() {
+		defer close(popped)
+		result := testDB.Exec(nil, utils.ToCmdLine("blpop", dest, "0"))
+		asserts.AssertMultiBulkReply(t, result, []string{dest, "a"})
+	}()
+	waitBlocking(t, dest, 1)
+	// pushing into src serves the client blocked on dest as well
+	testDB.Exec(nil, utils.ToCmdLine("rpush", src, "a"))
+	<-moved
+	<-popped
+	result := testDB.Exec(nil, utils.ToCmdLine("exists", src, dest))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestBLPopMultiRollback(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		result := testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0"))
+		asserts.AssertMultiBulkReply(t, result, []string{key, "c"})
+	}()
+	waitBlocking(t, key, 1)
+
+	// elements pushed by rolled back transaction are not handed to blocked client
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("rpush", key, "a", "b"))
+	testDB.Exec(conn, utils.ToCmdLine("incr", key))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertErrReply(t, result, "EXECABORT Transaction rolled back: WRONGTYPE Operation against a key holding the wrong kind of value")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+	if !testDB.blocking.hasWaiters(key) {
+		t.Error("client should still be blocked")
+	}
+
+	// blocked client is served after exec succeeded
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("rpush", key, "c", "d"))
+	testDB.Exec(conn, utils.ToCmdLine("exec"))
+	<-done
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d"})
+}
+
+func TestBLPopWatch(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		testDB.Exec(nil, utils.ToCmdLine("blpop", key, "0.05"))
+	}()
+	<-done
+	// blocked command which popped nothing doesn't change watched key
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if reply.IsErrorReply(result) || string(result.ToBytes()) == "*-1\r\n" {
+		t.Errorf("expect exec succeeded, actually %s", result.ToBytes())
+	}
+}
+
+func TestBlockedClientClose(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		testDB.Exec(conn, utils.ToCmdLine("blpop", key, "0"))
+	}()
+	waitBlocking(t, key, 1)
+	testDB.AfterClientClose(conn)
+	<-done
+	if testDB.blocking.hasWaiters(key) {
+		t.Error("closed client should be removed from queue")
+	}
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "a"))
+	result := testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, 1)
+}
@@ -0,0 +1,151 @@
+package cluster
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/logger"
+	"JZ_Redis/redis/client"
+	"JZ_Redis/redis/reply"
+	"bytes"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// Router knows which node owns a slot and relays commands to other nodes
+// 所有节点按地址排序后平分 16384 个 slot, 每个节点根据相同的配置得到相同的划分
+type Router struct {
+	self string
+	// sorted addresses of all nodes, including self
+	nodes []string
+	// requirepass of peers, all nodes share the same config
+	password string
+
+	mu sync.Mutex
+	// peer address -> client
+	clients map[string]*client.Client
+	closed  bool
+}
+
+var errRouterClosed = errors.New("router is closed")
+
+// MakeRouter creates a Router, cluster mode is disabled if there is no peer
+func MakeRouter(self string, peers []string, password string) *Router {
+	router := &Router{
+		self:     self,
+		password: password,
+		clients:  make(map[string]*client.Client),
+	}
+	if len(peers) == 0 {
+		return router
+	}
+	nodes := make([]string, 0, len(peers)+1)
+	nodes = append(nodes, self)
+	for _, peer := range peers {
+		if peer != "" && peer != self {
+			nodes = append(nodes, peer)
+		}
+	}
+	sort.Strings(nodes)
+	router.nodes = nodes
+	return router
+}
+
+// Enabled tells whether running in cluster mode
+func (router *Router) Enabled() bool {
+	return len(router.nodes) > 1
+}
+
+// OwnerOf returns address of the node owning the given slot
+func (router *Router) OwnerOf(slot int) string {
+	if !router.Enabled() {
+		return router.self
+	}
+	return router.nodes[slot*len(router.nodes)/SlotCount]
+}
+
+// IsLocal tells whether the given slot is owned by current node
+func (router *Router) IsLocal(slot int) bool {
+	return !router.Enabled() || router.OwnerOf(slot) == router.self
+}
+
+func (router *Router) getClient(addr string) (*client.Client, error) {
+	router.mu.Lock()
+	c, ok := router.clients[addr]
+	closed := router.closed
+	router.mu.Unlock()
+	if closed {
+		return nil, errRouterClosed
+	}
+	if ok {
+		return c, nil
+	}
+
+	// dial without holding lock, so that an unreachable peer doesn't block relaying to others
+	c, err := client.MakeClient(addr)
+	if err != nil {
+		return nil, err
+	}
+	c.Start()
+	if err := router.auth(c); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	if router.closed {
+		c.Close()
+		return nil, errRouterClosed
+	}
+	// another goroutine may have connected to the same peer
+	if existed, ok := router.clients[addr]; ok {
+		c.Close()
+		return existed, nil
+	}
+	router.clients[addr] = c
+	return c, nil
+}
+
+// auth authenticates connection to peer if requirepass is set
+func (router *Router) auth(c *client.Client) error {
+	if router.password == "" {
+		return nil
+	}
+	result := c.Send([][]byte{[]byte("AUTH"), []byte(router.password)})
+	if reply.IsErrorReply(result) {
+		return errors.New("auth failed: " + string(bytes.TrimSpace(result.ToBytes()[1:])))
+	}
+	return nil
+}
+
+var noAuthPrefix = []byte("-NOAUTH")
+
+// Relay sends command line to the given node and returns its reply
+func (router *Router) Relay(addr string, cmdLine [][]byte) redis.Reply {
+	c, err := router.getClient(addr)
+	if err != nil {
+		logger.Warn(err)
+		return reply.MakeErrReply("ERR connect to " + addr + " failed: " + err.Error())
+	}
+	result := c.Send(cmdLine)
+	// client reconnects silently after connection broken, authenticate the new connection and retry
+	if router.password != "" && bytes.HasPrefix(result.ToBytes(), noAuthPrefix) {
+		if err := router.auth(c); err != nil {
+			logger.Warn(err)
+			return reply.MakeErrReply("ERR connect to " + addr + " failed: " + err.Error())
+		}
+		result = c.Send(cmdLine)
+	}
+	return result
+}
+
+// Close closes connections to peers
+func (router *Router) Close() {
+	router.mu.Lock()
+	defer router.mu.Unlock()
+	router.closed = true
+	for addr, c := range router.clients {
+		c.Close()
+		delete(router.clients, addr)
+	}
+}
@@ -0,0 +1,57 @@
+package cluster
+
+import "strings"
+
+/*
+ * Key space is divided into 16384 hash slots like redis cluster:
+ *   slot = CRC16(key) mod 16384
+ * if the key contains a hash tag such as "{user1000}.following", only the part inside {} is hashed,
+ * so that related keys can be placed in the same slot
+ */
+
+// SlotCount is the number of hash slots
+const SlotCount = 16384
+
+// crc16 table of CCITT XMODEM, the same as redis cluster
+var crc16Table [256]uint16
+
+func init() {
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		crc16Table[i] = crc
+	}
+}
+
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i++ {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// hashTag returns the part to hash, it is the content of the first non-empty {...} if exists
+func hashTag(key string) string {
+	begin := strings.IndexByte(key, '{')
+	if begin < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[begin+1:], '}')
+	if end <= 0 {
+		// no `}` or empty tag `{}`
+		return key
+	}
+	return key[begin+1 : begin+1+end]
+}
+
+// HashSlot returns the slot of the given key
+func HashSlot(key string) int {
+	return int(crc16(hashTag(key)) % SlotCount)
+}
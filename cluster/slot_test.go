@@ -0,0 +1,45 @@
+package cluster
+
+import "testing"
+
+func TestHashSlot(t *testing.T) {
+	// values from redis cluster spec and `CLUSTER KEYSLOT`
+	cases := map[string]int{
+		"123456789":            12739,
+		"foo":                  12182,
+		"{user1000}.following": HashSlot("user1000"),
+		"{}foo":                HashSlot("{}foo"),
+		"foo{}{bar}":           HashSlot("foo{}{bar}"),
+	}
+	for key, slot := range cases {
+		if actual := HashSlot(key); actual != slot {
+			t.Errorf("slot of %q: expect %d, actually %d", key, slot, actual)
+		}
+	}
+	if crc16("123456789") != 0x31C3 {
+		t.Errorf("wrong crc16 0x%X", crc16("123456789"))
+	}
+	if HashSlot("{user1000}.followers") != HashSlot("{user1000}.following") {
+		t.Error("keys with the same hash tag should be in the same slot")
+	}
+}
+
+func TestRouter(t *testing.T) {
+	router := MakeRouter("127.0.0.1:6399", nil, "")
+	if router.Enabled() || !router.IsLocal(100) {
+		t.Error("router without peers should own all slots")
+	}
+	a := MakeRouter("127.0.0.1:6399", []string{"127.0.0.1:6400"}, "")
+	b := MakeRouter("127.0.0.1:6400", []string{"127.0.0.1:6399"}, "")
+	for slot := 0; slot < SlotCount; slot += 97 {
+		if a.OwnerOf(slot) != b.OwnerOf(slot) {
+			t.Fatalf("nodes disagree on owner of slot %d", slot)
+		}
+		if a.IsLocal(slot) == b.IsLocal(slot) {
+			t.Fatalf("slot %d should be owned by exactly one node", slot)
+		}
+	}
+	if a.OwnerOf(0) != "127.0.0.1:6399" || a.OwnerOf(SlotCount-1) != "127.0.0.1:6400" {
+		t.Error("slots should be divided by sorted address")
+	}
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"JZ_Redis"
+	"JZ_Redis/rdb"
+	"JZ_Redis/redis/parser"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+/*
+ * check-aof verifies an append only file offline, like redis-check-aof:
+ *   check-aof [--fix] <file>
+ * 每条记录必须是 multi bulk, 命令已注册且参数个数正确. 文件可以以 rdb 快照开头 (aof-use-rdb-preamble 或 base.rdb 文件).
+ * 发现错误时打印其偏移量, 指定 --fix 时把文件截断到最后一条合法命令
+ */
+
+// aofStats is the result of scanning
+type aofStats struct {
+	// keys in rdb preamble, -1 if there is no preamble
+	rdbKeys int
+	rdbSize int64
+	// command name -> count
+	commands map[string]int
+	// size of valid content, the file should be truncated here if err is not nil
+	validSize int64
+	err       error
+}
+
+// checkAof scans the whole aof and stops at the first bad entry
+func checkAof(reader io.Reader) *aofStats {
+	stats := &aofStats{
+		rdbKeys:  -1,
+		commands: make(map[string]int),
+	}
+	bufReader := bufio.NewReader(reader)
+	if header, _ := bufReader.Peek(len(rdb.Magic)); string(header) == rdb.Magic {
+		stats.rdbKeys = 0
+		decoder := rdb.NewDecoder(bufReader)
+		err := decoder.Parse(func(object *rdb.Object) bool {
+			stats.rdbKeys++
+			return true
+		})
+		if err != nil {
+			stats.err = fmt.Errorf("bad rdb preamble: %v", err)
+			return stats
+		}
+		stats.rdbSize = decoder.Offset()
+		stats.validSize = stats.rdbSize
+	}
+
+	cmdReader := parser.NewCommandReader(bufReader)
+	for {
+		cmdLine, err := cmdReader.ReadCommand()
+		if err == io.EOF {
+			return stats
+		}
+		if err != nil {
+			stats.err = err
+			return stats
+		}
+		if err := JZ_Redis.CheckCmdLine(cmdLine); err != nil {
+			stats.err = err
+			return stats
+		}
+		stats.commands[strings.ToLower(string(cmdLine[0]))]++
+		stats.validSize = stats.rdbSize + cmdReader.Offset()
+	}
+}
+
+func printStats(stats *aofStats) {
+	if stats.rdbKeys >= 0 {
+		fmt.Printf("RDB preamble: %d keys, %d bytes\n", stats.rdbKeys, stats.rdbSize)
+	}
+	names := make([]string, 0, len(stats.commands))
+	total := 0
+	for name, count := range stats.commands {
+		names = append(names, name)
+		total += count
+	}
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := stats.commands[names[i]], stats.commands[names[j]]
+		if ci != cj {
+			return ci > cj
+		}
+		return names[i] < names[j]
+	})
+	fmt.Printf("Commands: %d\n", total)
+	for _, name := range names {
+		fmt.Printf("  %-20s %d\n", name, stats.commands[name])
+	}
+}
+
+func run(filename string, fix bool) error {
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	stats := checkAof(file)
+	_ = file.Close()
+	printStats(stats)
+	if stats.err == nil {
+		fmt.Println("AOF is valid")
+		return nil
+	}
+
+	fmt.Printf("AOF is not valid at offset %d: %v\n", stats.validSize, stats.err)
+	if !fix {
+		return errors.New("use --fix to truncate the file to the last valid command")
+	}
+	if stats.rdbKeys >= 0 && stats.validSize == 0 {
+		return errors.New("rdb preamble is corrupted, it can't be fixed by truncating")
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+	if err := os.Truncate(filename, stats.validSize); err != nil {
+		return err
+	}
+	fmt.Printf("Successfully truncated AOF from %d to %d bytes\n", info.Size(), stats.validSize)
+	return nil
+}
+
+func main() {
+	fix := flag.Bool("fix", false, "truncate the file to the last valid command")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [--fix] <file.aof>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err := run(flag.Arg(0), *fix); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/rdb"
+	"JZ_Redis/redis/reply"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func makeAof(cmdLines ...[][]byte) []byte {
+	buf := &bytes.Buffer{}
+	for _, cmdLine := range cmdLines {
+		buf.Write(reply.MakeMultiBulkReply(cmdLine).ToBytes())
+	}
+	return buf.Bytes()
+}
+
+func TestCheckAof(t *testing.T) {
+	valid := makeAof(
+		utils.ToCmdLine("set", "a", "1"),
+		utils.ToCmdLine("SET", "b", "2"),
+		utils.ToCmdLine("rpush", "list", "a", "b"),
+	)
+	stats := checkAof(bytes.NewReader(valid))
+	if stats.err != nil || stats.validSize != int64(len(valid)) || stats.rdbKeys != -1 {
+		t.Errorf("expect valid aof, actually %+v", stats)
+	}
+	if stats.commands["set"] != 2 || stats.commands["rpush"] != 1 {
+		t.Errorf("wrong stats %v", stats.commands)
+	}
+
+	tests := map[string][]byte{
+		"truncated":       []byte("*3\r\n$3\r\nset\r\n$1\r\na"),
+		"unknown command": makeAof(utils.ToCmdLine("foo", "a")),
+		"wrong arity":     makeAof(utils.ToCmdLine("get", "a", "b")),
+		"not multi bulk":  []byte("+OK\r\n"),
+	}
+	for name, bad := range tests {
+		stats := checkAof(bytes.NewReader(append(append([]byte{}, valid...), bad...)))
+		if stats.err == nil || stats.validSize != int64(len(valid)) {
+			t.Errorf("%s: expect error at %d, actually %+v", name, len(valid), stats)
+		}
+	}
+}
+
+func TestCheckAofWithRdbPreamble(t *testing.T) {
+	buf := &bytes.Buffer{}
+	encoder := rdb.NewEncoder(buf)
+	_ = encoder.WriteHeader(nil)
+	_ = encoder.WriteDBHeader(0, 2, 0)
+	_ = encoder.WriteStringObject("a", []byte("1"), 0)
+	_ = encoder.WriteListObject("b", [][]byte{[]byte("x")}, 0)
+	_ = encoder.WriteEnd()
+	rdbSize := int64(buf.Len())
+	buf.Write(makeAof(utils.ToCmdLine("set", "c", "3")))
+	validSize := int64(buf.Len())
+	buf.WriteString("*1\r\n")
+
+	stats := checkAof(bytes.NewReader(buf.Bytes()))
+	if stats.rdbKeys != 2 || stats.rdbSize != rdbSize || stats.commands["set"] != 1 {
+		t.Errorf("wrong stats %+v", stats)
+	}
+	if stats.err == nil || stats.validSize != validSize {
+		t.Errorf("expect error at %d, actually %+v", validSize, stats)
+	}
+
+	// corrupted preamble
+	data := buf.Bytes()
+	data[rdbSize-1] ^= 0xFF
+	stats = checkAof(bytes.NewReader(data))
+	if stats.err == nil || stats.validSize != 0 {
+		t.Errorf("expect bad preamble, actually %+v", stats)
+	}
+}
+
+func TestFix(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	filename := path.Join(tmpDir, "appendonly.aof")
+	valid := makeAof(utils.ToCmdLine("set", "a", "1"))
+	if err := ioutil.WriteFile(filename, append(append([]byte{}, valid...), "*2\r\n$3"...), 0644); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if err := run(filename, false); err == nil {
+		t.Error("expect error without --fix")
+	}
+	if err := run(filename, true); err != nil {
+		t.Error(err)
+		return
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.Equal(data, valid) {
+		t.Errorf("expect truncated to %q, actually %q", valid, data)
+	}
+	if err := run(filename, false); err != nil {
+		t.Error(err)
+	}
+}
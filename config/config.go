@@ -19,6 +19,26 @@ type ServerProperties struct {
 	MaxClients     int    `cfg:"maxclients"`
 	RequirePass    string `cfg:"requirepass"`
 
+	// directory of multi-part aof files, it is beside appendfilename
+	AppendDirname string `cfg:"appenddirname"`
+	// truncate the incomplete command at tail of aof when loading, otherwise refuse to start
+	AofLoadTruncated bool `cfg:"aof-load-truncated"`
+	// when to fsync aof file: always, everysec or no
+	AppendFsync string `cfg:"appendfsync"`
+	// rewrite aof when it grows by the percentage since last rewrite and is larger than min size in bytes, 0 disables it
+	AutoAofRewritePercentage int `cfg:"auto-aof-rewrite-percentage"`
+	AutoAofRewriteMinSize    int `cfg:"auto-aof-rewrite-min-size"`
+	// write rdb snapshot at the head of rewritten aof, it loads faster than commands
+	AofUseRdbPreamble bool `cfg:"aof-use-rdb-preamble"`
+
+	// path of rdb snapshot file
+	DBFilename string `cfg:"dbfilename"`
+	// save rules in pairs of <seconds> <changes>, eg. "900 1 300 10", empty means disabled
+	Save string `cfg:"save"`
+
+	// classes of keyspace events to publish, empty means disabled
+	NotifyKeyspaceEvents string `cfg:"notify-keyspace-events"`
+
 	Peers []string `cfg:"peers"`
 	Self  string   `cfg:"self"`
 }
@@ -26,17 +46,30 @@ type ServerProperties struct {
 // Properties holds global config properties
 var Properties *ServerProperties
 
+// defaultProperties returns config with default values, which are overridden by config file
+func defaultProperties() *ServerProperties {
+	return &ServerProperties{
+		AppendFilename:   "appendonly.aof",
+		AppendDirname:    "appendonlydir",
+		AofLoadTruncated: true,
+		AppendFsync:      "everysec",
+
+		AutoAofRewritePercentage: 100,
+		AutoAofRewriteMinSize:    64 << 20,
+
+		DBFilename: "dump.rdb",
+	}
+}
+
 func init()  {
 	// default config
-	Properties = &ServerProperties{
-		Bind: "127.0.0.1",
-		Port: 6379,
-		AppendOnly: false,
-	}
+	Properties = defaultProperties()
+	Properties.Bind = "127.0.0.1"
+	Properties.Port = 6379
 }
 
 func parse(src io.Reader) *ServerProperties {
-	config := &ServerProperties{}
+	config := defaultProperties()
 
 	// read config file
 	rawMap := make(map[string]string)
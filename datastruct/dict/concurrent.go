@@ -290,7 +290,14 @@ func (dict *ConcurrentDict) RandomDistinctKeys(limit int) []string {
 	return arr
 }
 
+// Clear removes all keys in dict
 func (dict *ConcurrentDict) Clear() {
-	*dict = *MakeConcurrent(dict.shardCount)
+	// clear shards one by one instead of replacing the whole dict, other goroutines may be reading it
+	for _, shard := range dict.table {
+		shard.mutex.Lock()
+		atomic.AddInt32(&dict.count, -int32(len(shard.m)))
+		shard.m = make(map[string]interface{})
+		shard.mutex.Unlock()
+	}
 }
 
@@ -72,6 +72,7 @@ func (dict *SimpleDict) Keys() []string {
 	i := 0
 	for k := range dict.m {
 		result[i] = k
+		i++
 	}
 	return result
 }
@@ -113,4 +114,9 @@ func (dict *SimpleDict) RandomDistinctKeys(limit int) []string {
 		i++
 	}
 	return result
-}
\ No newline at end of file
+}
+
+// Clear removes all keys in dict
+func (dict *SimpleDict) Clear() {
+	*dict = *MakeSimple()
+}
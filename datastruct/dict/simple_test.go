@@ -14,6 +14,11 @@ func TestSimpleDict_Keys(t *testing.T) {
 	if len(d.Keys()) != size {
 		t.Errorf("expect %d keys, actual: %d", size, len(d.Keys()))
 	}
+	for _, key := range d.Keys() {
+		if _, ok := d.Get(key); !ok {
+			t.Errorf("unexpected key %q", key)
+		}
+	}
 }
 
 func TestSimpleDict_PutIfExists(t *testing.T) {
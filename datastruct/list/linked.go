@@ -61,12 +61,12 @@ func (list *LinkedList) Get(index int) (val interface{}) {
 	return list.find(index).val
 }
 
-// Set updates value at the given index, the index should between [0, list.size]
+// Set updates value at the given index, the index should between [0, list.size)
 func (list *LinkedList) Set(index int, val interface{}) {
 	if list == nil {
 		panic("list is nil")
 	}
-	if index < 0 || index > list.size { // 右边能取等吗？
+	if index < 0 || index >= list.size {
 		panic("index out of bound")
 	}
 	n := list.find(index)
@@ -104,7 +104,7 @@ func (list *LinkedList) Insert(index int, val interface{}) {
 
 func (list *LinkedList) removeNode(n *node) {
 	if n.prev == nil {
-		list.first = n
+		list.first = n.next
 	} else {
 		n.prev.next = n.next
 	}
@@ -136,7 +136,7 @@ func (list *LinkedList) Remove(index int) (val interface{}) {
 }
 
 // RemoveLast removes the last element and returns its value
-func (list *LinkedList) RemoveList() (val interface{}) {
+func (list *LinkedList) RemoveLast() (val interface{}) {
 	if list == nil {
 		panic("list is nil")
 	}
@@ -278,7 +278,7 @@ func (list *LinkedList) Contains(val interface{}) bool {
 	}
 	contains := false
 	list.ForEach(func(i int, actual interface{}) bool {
-		if actual == val {
+		if utils.Equals(actual, val) {
 			contains = true
 			return false
 		}
@@ -306,7 +306,7 @@ func (list *LinkedList) Range(start int, stop int) []interface{} {
 	sliceIndex := 0
 	for n != nil {
 		if i >= start && i < stop {
-			slice[sliceIndex] = n
+			slice[sliceIndex] = n.val
 			sliceIndex++
 		} else if i >= stop {
 			break
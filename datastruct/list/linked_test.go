@@ -0,0 +1,74 @@
+package list
+
+import (
+	"strconv"
+	"testing"
+)
+
+func toString(list *LinkedList) string {
+	s := "["
+	list.ForEach(func(i int, v interface{}) bool {
+		if i > 0 {
+			s += ", "
+		}
+		s += strconv.Itoa(v.(int))
+		return true
+	})
+	return s + "]"
+}
+
+func TestRemove(t *testing.T) {
+	list := Make(0, 1, 2, 3, 4)
+	list.Remove(0)
+	if toString(list) != "[1, 2, 3, 4]" {
+		t.Error("remove head failed: " + toString(list))
+	}
+	list.Remove(list.Len() - 1)
+	if toString(list) != "[1, 2, 3]" {
+		t.Error("remove tail failed: " + toString(list))
+	}
+	list.Remove(1)
+	if toString(list) != "[1, 3]" {
+		t.Error("remove middle failed: " + toString(list))
+	}
+	if v := list.RemoveLast(); v != 3 || toString(list) != "[1]" {
+		t.Error("remove last failed: " + toString(list))
+	}
+	list.Remove(0)
+	if list.Len() != 0 || toString(list) != "[]" {
+		t.Error("remove all failed: " + toString(list))
+	}
+	list.Add(5)
+	if toString(list) != "[5]" {
+		t.Error("add after clear failed: " + toString(list))
+	}
+}
+
+func TestRemoveVal(t *testing.T) {
+	list := Make(1, 2, 1, 3, 1)
+	if removed := list.RemoveByVal(1, 2); removed != 2 || toString(list) != "[2, 3, 1]" {
+		t.Error("remove by val failed: " + toString(list))
+	}
+	list = Make(1, 2, 1, 3, 1)
+	if removed := list.ReverseRemoveByVal(1, 2); removed != 2 || toString(list) != "[1, 2, 3]" {
+		t.Error("reverse remove by val failed: " + toString(list))
+	}
+	list = Make(1, 2, 1, 3, 1)
+	if removed := list.RemoveAllByVal(1); removed != 3 || toString(list) != "[2, 3]" {
+		t.Error("remove all by val failed: " + toString(list))
+	}
+}
+
+func TestRange(t *testing.T) {
+	list := Make(0, 1, 2, 3, 4)
+	slice := list.Range(1, 4)
+	if len(slice) != 3 {
+		t.Errorf("expected 3 elements, actually %d", len(slice))
+		return
+	}
+	for i, v := range slice {
+		if v.(int) != i+1 {
+			t.Errorf("expected %d, actually %v", i+1, v)
+		}
+	}
+}
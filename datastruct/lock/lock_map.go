@@ -171,3 +171,33 @@ func (locks *Locks) RWUnLocks(writeKeys []string, readKeys []string) {
 		}
 	}
 }
+
+// RLockAll obtains shared locks of all slots, it blocks writers of every key
+// slots are locked in the same order as RWLocks to avoid dead lock
+func (locks *Locks) RLockAll() {
+	for _, mu := range locks.table {
+		mu.RLock()
+	}
+}
+
+// RUnLockAll releases locks obtained by RLockAll
+func (locks *Locks) RUnLockAll() {
+	for i := len(locks.table) - 1; i >= 0; i-- {
+		locks.table[i].RUnlock()
+	}
+}
+
+// LockAll obtains exclusive locks of all slots, it blocks every reader and writer
+// slots are locked in the same order as RWLocks to avoid dead lock
+func (locks *Locks) LockAll() {
+	for _, mu := range locks.table {
+		mu.Lock()
+	}
+}
+
+// UnLockAll releases locks obtained by LockAll
+func (locks *Locks) UnLockAll() {
+	for i := len(locks.table) - 1; i >= 0; i-- {
+		locks.table[i].Unlock()
+	}
+}
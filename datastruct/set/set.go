@@ -90,7 +90,7 @@ func (set *Set) Union(another *Set) *Set {
 		return true
 	})
 	set.ForEach(func(member string) bool {
-		set.Add(member)
+		result.Add(member)
 		return true
 	})
 	return result
@@ -0,0 +1,31 @@
+package set
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func toString(set *Set) string {
+	members := set.ToSlice()
+	sort.Strings(members)
+	return "[" + strings.Join(members, ", ") + "]"
+}
+
+func TestSetAlgebra(t *testing.T) {
+	a := Make("a", "b", "c")
+	b := Make("b", "c", "d")
+	if s := toString(a.Intersect(b)); s != "[b, c]" {
+		t.Error("intersect failed: " + s)
+	}
+	if s := toString(a.Union(b)); s != "[a, b, c, d]" {
+		t.Error("union failed: " + s)
+	}
+	if s := toString(a.Diff(b)); s != "[a]" {
+		t.Error("diff failed: " + s)
+	}
+	// operands should not be modified
+	if s := toString(a); s != "[a, b, c]" {
+		t.Error("set modified: " + s)
+	}
+}
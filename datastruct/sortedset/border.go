@@ -2,6 +2,7 @@ package SortedSet
 
 import (
 	"errors"
+	"math"
 	"strconv"
 )
 
@@ -53,6 +54,18 @@ func (border *ScoreBorder) less(value float64) bool {
 	return border.Value <= value
 }
 
+// isEmptyRange returns true if no value could be within [min, max]
+// the receiver is the min border
+func (border *ScoreBorder) isEmptyRange(max *ScoreBorder) bool {
+	if border.Inf == positiveInf || max.Inf == negativeInf {
+		return true
+	}
+	if border.Inf == negativeInf || max.Inf == positiveInf {
+		return false
+	}
+	return border.Value > max.Value || (border.Value == max.Value && (border.Exclude || max.Exclude))
+}
+
 var positiveInfBorder = &ScoreBorder{
 	Inf: positiveInf,
 }
@@ -61,7 +74,11 @@ var negativeInfBorder = &ScoreBorder{
 	Inf: negativeInf,
 }
 
+// ParseScoreBorder parses `min` or `max` argument of ZRANGEBYSCORE like commands
 func ParseScoreBorder(s string) (*ScoreBorder, error)  {
+	if s == "" {
+		return nil, errors.New("ERR min or max is not a float")
+	}
 	if s == "inf" || s == "+inf" {
 		return positiveInfBorder, nil
 	}
@@ -70,7 +87,7 @@ func ParseScoreBorder(s string) (*ScoreBorder, error)  {
 	}
 	if s[0] == '(' {
 		value, err := strconv.ParseFloat(s[1:], 64)
-		if err != nil {
+		if err != nil || math.IsNaN(value) {
 			return nil, errors.New("ERR min or max is not a float")
 		}
 		return &ScoreBorder{
@@ -81,7 +98,7 @@ func ParseScoreBorder(s string) (*ScoreBorder, error)  {
 	}
 
 	value, err := strconv.ParseFloat(s, 64)
-	if err != nil {
+	if err != nil || math.IsNaN(value) {
 		return nil, errors.New("ERR min or max is not a float")
 	}
 	return &ScoreBorder{
@@ -89,4 +106,94 @@ func ParseScoreBorder(s string) (*ScoreBorder, error)  {
 		Value: value,
 		Exclude: false,
 	}, nil
-}
\ No newline at end of file
+}
+
+/*
+ * LexBorder is a struct represents `min` `max` parameter of redis command `ZRANGEBYLEX`
+ * can accept:
+ *   inclusive member, such as [a
+ *   exclusive member, such as (a
+ *   infinity: - and +
+ * 字典序范围只在所有成员 score 相同时有意义, 此时跳表中成员按字典序排列
+ */
+
+// LexBorder represents range of a member, including: <, <=, >, >=, +, -
+type LexBorder struct {
+	Inf     int8
+	Value   string
+	Exclude bool
+}
+
+// if max.greater(member) then the member is within the upper border
+func (border *LexBorder) greater(member string) bool {
+	if border.Inf == negativeInf {
+		return false
+	} else if border.Inf == positiveInf {
+		return true
+	}
+
+	if border.Exclude {
+		return border.Value > member
+	}
+	return border.Value >= member
+}
+
+// if min.less(member) then the member is within the lower border
+func (border *LexBorder) less(member string) bool {
+	if border.Inf == negativeInf {
+		return true
+	} else if border.Inf == positiveInf {
+		return false
+	}
+
+	if border.Exclude {
+		return border.Value < member
+	}
+	return border.Value <= member
+}
+
+// isEmptyRange returns true if no member could be within [min, max]
+// the receiver is the min border
+func (border *LexBorder) isEmptyRange(max *LexBorder) bool {
+	if border.Inf == positiveInf || max.Inf == negativeInf {
+		return true
+	}
+	if border.Inf == negativeInf || max.Inf == positiveInf {
+		return false
+	}
+	return border.Value > max.Value || (border.Value == max.Value && (border.Exclude || max.Exclude))
+}
+
+var positiveInfLexBorder = &LexBorder{
+	Inf: positiveInf,
+}
+
+var negativeInfLexBorder = &LexBorder{
+	Inf: negativeInf,
+}
+
+// ParseLexBorder parses `min` or `max` argument of ZRANGEBYLEX like commands
+func ParseLexBorder(s string) (*LexBorder, error) {
+	if s == "+" {
+		return positiveInfLexBorder, nil
+	}
+	if s == "-" {
+		return negativeInfLexBorder, nil
+	}
+	if len(s) == 0 {
+		return nil, errors.New("ERR min or max not valid string range item")
+	}
+	switch s[0] {
+	case '(':
+		return &LexBorder{
+			Value:   s[1:],
+			Exclude: true,
+		}, nil
+	case '[':
+		return &LexBorder{
+			Value:   s[1:],
+			Exclude: false,
+		}, nil
+	}
+	return nil, errors.New("ERR min or max not valid string range item")
+}
@@ -153,7 +153,7 @@ func (skiplist *skiplist) remove(memeber string, score float64) bool {
 	update := make([]*node, maxLevel)
 	node := skiplist.header
 
-	for i := int16(0); i < skiplist.level; i++ {
+	for i := skiplist.level - 1; i >= 0; i-- {
 		for node.level[i].forward != nil && (node.level[i].forward.Score < score ||
 			(node.level[i].forward.Score == score && node.level[i].forward.Member < memeber)) {
 			node = node.level[i].forward
@@ -187,9 +187,9 @@ func (skiplist *skiplist) removeNode(node *node, update []*node) {
 			update[i].level[i].span--
 		}
 	}
-	// 修改目标节点后继节点的backward指针
+	// 修改目标节点后继节点的backward指针, 删除首个节点时后继的 backward 为 nil 而不是 header
 	if node.level[0].forward != nil {
-		node.level[0].forward.backward = update[0]
+		node.level[0].forward.backward = node.backward
 	} else {
 		skiplist.tail = node.backward
 	}
@@ -216,7 +216,7 @@ func (skiplist *skiplist) getRank(member string, score float64) int64 {
 		}
 
 		/* x might be equal to zsl->header, so test if obj is non-NULL */
-		if x.Member == member {
+		if x != skiplist.header && x.Member == member {
 			return rank
 		}
 	}
@@ -232,7 +232,7 @@ func (skiplist *skiplist) getByRank(rank int64) *node {
 	n := skiplist.header
 
 	// 从顶层向下查询
-	for level := skiplist.level; level >= 0; level-- {
+	for level := skiplist.level - 1; level >= 0; level-- {
 		// 从当前层向前搜索
 		// 若当前层的下一个节点已经超过目标 (i+n.level[level].span > rank)，则结束当前层搜索进入下一层
 		for n.level[level].forward != nil && (i+n.level[level].span <= rank) {
@@ -250,17 +250,17 @@ func (skiplist *skiplist) getByRank(rank int64) *node {
 
 func (skiplist *skiplist) hasInRange(min *ScoreBorder, max *ScoreBorder) bool {
 	// min & max = empty
-	if min.Value > max.Value || (min.Value == max.Value && (min.Exclude || max.Exclude)) {
+	if min.isEmptyRange(max) {
 		return false
 	}
 	// min > tail
 	n := skiplist.tail
-	if n == nil || min.less(n.Score) {
+	if n == nil || !min.less(n.Score) {
 		return false
 	}
 	// max < head
 	n = skiplist.header.level[0].forward
-	if n == nil || max.greater(n.Score) {
+	if n == nil || !max.greater(n.Score) {
 		return false
 	}
 	return true
@@ -275,11 +275,11 @@ func (skiplist *skiplist) getFirstInScoreRange(min *ScoreBorder, max *ScoreBorde
 	n := skiplist.header
 
 	// scan from top level 从顶层向下查询
-	for i := skiplist.level; i >= 0; i-- {
+	for i := skiplist.level - 1; i >= 0; i-- {
 		// if forward is not in range then move forward
 		// 若 forward 节点仍未进入范围则继续向前(forward)
 		// 若 forward 节点已进入范围，当 level > 0 时 forward 节点不能保证是 *第一个* 在 min 范围内的节点， 因此需进入下一层查找
-		if n.level[i].forward != nil && !min.less(n.level[i].forward.Score) {
+		for n.level[i].forward != nil && !min.less(n.level[i].forward.Score) {
 			n = n.level[i].forward
 		}
 	}
@@ -324,7 +324,7 @@ func (skiplist *skiplist) RemoveRangeByScore(min *ScoreBorder, max *ScoreBorder)
 			}
 			node = node.level[i].forward
 		}
-		update[i] = node.level[i].forward
+		update[i] = node
 	}
 
 	// node is the first one within range
@@ -374,4 +374,91 @@ func (skiplist *skiplist) RemoveRangeByRank(start int64, stop int64) (removed []
 		i++
 	}
 	return removed
-}
\ No newline at end of file
+}
+
+func (skiplist *skiplist) hasInLexRange(min *LexBorder, max *LexBorder) bool {
+	// min & max = empty
+	if min.isEmptyRange(max) {
+		return false
+	}
+	// min > tail
+	n := skiplist.tail
+	if n == nil || !min.less(n.Member) {
+		return false
+	}
+	// max < head
+	n = skiplist.header.level[0].forward
+	if n == nil || !max.greater(n.Member) {
+		return false
+	}
+	return true
+}
+
+// getFirstInLexRange finds the first node within lex range, members are compared in skiplist order
+func (skiplist *skiplist) getFirstInLexRange(min *LexBorder, max *LexBorder) *node {
+	if !skiplist.hasInLexRange(min, max) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for i := skiplist.level - 1; i >= 0; i-- {
+		for n.level[i].forward != nil && !min.less(n.level[i].forward.Member) {
+			n = n.level[i].forward
+		}
+	}
+	/* This is an inner range, so the next node cannot be NULL */
+	n = n.level[0].forward
+	if !max.greater(n.Member) {
+		return nil
+	}
+	return n
+}
+
+func (skiplist *skiplist) getLastInLexRange(min *LexBorder, max *LexBorder) *node {
+	if !skiplist.hasInLexRange(min, max) {
+		return nil
+	}
+	n := skiplist.header
+	// scan from top level
+	for i := skiplist.level - 1; i >= 0; i-- {
+		for n.level[i].forward != nil && max.greater(n.level[i].forward.Member) {
+			n = n.level[i].forward
+		}
+	}
+	if !min.less(n.Member) {
+		return nil
+	}
+	return n
+}
+
+/*
+ * return removed elements
+ */
+func (skiplist *skiplist) RemoveRangeByLex(min *LexBorder, max *LexBorder) (removed []*Element) {
+	update := make([]*node, maxLevel)
+	removed = make([]*Element, 0)
+	// find last node before range of each level
+	node := skiplist.header
+	for i := skiplist.level - 1; i >= 0; i-- {
+		for node.level[i].forward != nil && !min.less(node.level[i].forward.Member) {
+			node = node.level[i].forward
+		}
+		update[i] = node
+	}
+
+	// node is the first one within range
+	node = node.level[0].forward
+
+	// remove nodes in range
+	for node != nil {
+		if !max.greater(node.Member) { // already out of range
+			break
+		}
+		next := node.level[0].forward
+		removedElement := node.Element
+		removed = append(removed, &removedElement)
+		skiplist.removeNode(node, update)
+		node = next
+	}
+	return removed
+}
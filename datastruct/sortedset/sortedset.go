@@ -27,7 +27,7 @@ func (sortedSet *SortedSet) Add(member string, score float64) bool {
 	}
 	if ok {
 		if score != element.Score{
-			sortedSet.skiplist.remove(member, score)
+			sortedSet.skiplist.remove(member, element.Score)
 			sortedSet.skiplist.insert(member, score)
 		}
 		return false
@@ -79,12 +79,16 @@ func (sortedSet *SortedSet) GetRank(member string, desc bool) (rank int64) {
 // ForEach visits each member which rank within [start, stop), sort by ascending order, rank start from 0
 func (sortedSet *SortedSet) ForEach(start int64, stop int64, desc bool, consumer func(element *Element) bool)  {
 	size := int64(sortedSet.Len()) // 这里的int64多余了，下同
-	if start < 0 || start >= size {
+	if start < 0 || start > size {
 		panic("illegal start " + strconv.FormatInt(start, 10))
 	}
 	if stop < start || stop > size {
 		panic("illegal stop " + strconv.FormatInt(stop, 10))
 	}
+	if start == stop {
+		// empty range
+		return
+	}
 
 	// find start node
 	var node *node
@@ -128,24 +132,13 @@ func (sortedSet *SortedSet) Range(start int64, stop int64, desc bool) []*Element
 
 // Count returns the number of members which score within the given border
 func (sortedSet *SortedSet) Count(min *ScoreBorder, max *ScoreBorder) int64 {
-	var i int64 = 0
-	// ascending order
-	sortedSet.ForEach(0, sortedSet.Len(), false, func(element *Element) bool {
-		gtMin := min.less(element.Score) // greater than min
-		if !gtMin {
-			// has not into range, continue foreach
-			return true
-		}
-		ltMax := max.greater(element.Score) // less than max
-		if !ltMax {
-			// break through score border, break foreach
-			return false
-		}
-		// gtMin && ltMax
-		i++
-		return true
-	})
-	return i
+	// 通过首尾节点的排名计算, 无需遍历范围内的所有节点
+	first := sortedSet.skiplist.getFirstInScoreRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.getLastInScoreRange(min, max)
+	return sortedSet.skiplist.getRank(last.Member, last.Score) - sortedSet.skiplist.getRank(first.Member, first.Score) + 1
 }
 
 // ForEachScore visits members which score within the given border
@@ -169,6 +162,11 @@ func (sortedSet *SortedSet) ForEachByScore(min *ScoreBorder, max *ScoreBorder, o
 
 	// A negative limit return all elements from the offset
 	for i := 0; (i < int(limit) || limit < 0) && node != nil; i++ {
+		gtMin := min.less(node.Score) // greater than min
+		ltMax := max.greater(node.Score)
+		if !gtMin || !ltMax {
+			break // break through score border
+		}
 		if !consumer(&node.Element) {
 			break
 		}
@@ -177,14 +175,6 @@ func (sortedSet *SortedSet) ForEachByScore(min *ScoreBorder, max *ScoreBorder, o
 		} else {
 			node = node.level[0].forward
 		}
-		if node == nil {
-			break
-		}
-		gtMin := min.less(node.Score) // greater than min
-		ltMax := max.greater(node.Score)
-		if !gtMin || !ltMax {
-			break // break through score border
-		}
 	}
 }
 
@@ -201,3 +191,89 @@ func (sortedSet *SortedSet) RangeByScore(min *ScoreBorder, max *ScoreBorder, off
 	})
 	return slice
 }
+
+// RemoveByScore removes members which score within the given border, returns the number of removed members
+func (sortedSet *SortedSet) RemoveByScore(min *ScoreBorder, max *ScoreBorder) int64 {
+	removed := sortedSet.skiplist.RemoveRangeByScore(min, max)
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+	}
+	return int64(len(removed))
+}
+
+// RemoveByRank removes members which rank within [start, stop), sort by ascending order, rank starts from 0
+func (sortedSet *SortedSet) RemoveByRank(start int64, stop int64) int64 {
+	removed := sortedSet.skiplist.RemoveRangeByRank(start+1, stop+1)
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+	}
+	return int64(len(removed))
+}
+
+// CountByLex returns the number of members which within the given lex border
+func (sortedSet *SortedSet) CountByLex(min *LexBorder, max *LexBorder) int64 {
+	first := sortedSet.skiplist.getFirstInLexRange(min, max)
+	if first == nil {
+		return 0
+	}
+	last := sortedSet.skiplist.getLastInLexRange(min, max)
+	return sortedSet.skiplist.getRank(last.Member, last.Score) - sortedSet.skiplist.getRank(first.Member, first.Score) + 1
+}
+
+// ForEachByLex visits members which within the given lex border
+func (sortedSet *SortedSet) ForEachByLex(min *LexBorder, max *LexBorder, offset int64, limit int64, desc bool, consumer func(element *Element) bool) {
+	// find first node
+	var node *node
+	if desc {
+		node = sortedSet.skiplist.getLastInLexRange(min, max)
+	} else {
+		node = sortedSet.skiplist.getFirstInLexRange(min, max)
+	}
+
+	for node != nil && offset > 0 {
+		if desc {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+		offset--
+	}
+
+	// A negative limit return all elements from the offset
+	for i := 0; (i < int(limit) || limit < 0) && node != nil; i++ {
+		if !min.less(node.Member) || !max.greater(node.Member) {
+			break // break through lex border
+		}
+		if !consumer(&node.Element) {
+			break
+		}
+		if desc {
+			node = node.backward
+		} else {
+			node = node.level[0].forward
+		}
+	}
+}
+
+// RangeByLex returns members which within the given lex border
+// param limit: < 0 means no limit
+func (sortedSet *SortedSet) RangeByLex(min *LexBorder, max *LexBorder, offset int64, limit int64, desc bool) []*Element {
+	if limit == 0 || offset < 0 {
+		return make([]*Element, 0)
+	}
+	slice := make([]*Element, 0)
+	sortedSet.ForEachByLex(min, max, offset, limit, desc, func(element *Element) bool {
+		slice = append(slice, element)
+		return true
+	})
+	return slice
+}
+
+// RemoveByLex removes members which within the given lex border, returns the number of removed members
+func (sortedSet *SortedSet) RemoveByLex(min *LexBorder, max *LexBorder) int64 {
+	removed := sortedSet.skiplist.RemoveRangeByLex(min, max)
+	for _, element := range removed {
+		delete(sortedSet.dict, element.Member)
+	}
+	return int64(len(removed))
+}
@@ -0,0 +1,156 @@
+package SortedSet
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+// sortedElements returns all elements sorted by score then member, as a reference of skiplist order
+func sortedElements(m map[string]float64) []*Element {
+	elements := make([]*Element, 0, len(m))
+	for member, score := range m {
+		elements = append(elements, &Element{Member: member, Score: score})
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].Score != elements[j].Score {
+			return elements[i].Score < elements[j].Score
+		}
+		return elements[i].Member < elements[j].Member
+	})
+	return elements
+}
+
+func assertElements(t *testing.T, actual []*Element, expected []*Element) {
+	if len(actual) != len(expected) {
+		t.Errorf("expected %d elements, actually %d", len(expected), len(actual))
+		return
+	}
+	for i := range actual {
+		if actual[i].Member != expected[i].Member || actual[i].Score != expected[i].Score {
+			t.Errorf("element %d: expected %v, actually %v", i, *expected[i], *actual[i])
+			return
+		}
+	}
+}
+
+func TestRandomOperations(t *testing.T) {
+	set := Make()
+	ref := make(map[string]float64)
+	for i := 0; i < 2000; i++ {
+		member := strconv.Itoa(rand.Intn(300))
+		if rand.Intn(4) == 0 {
+			_, existed := ref[member]
+			if set.Remove(member) != existed {
+				t.Fatalf("remove %s returns wrong result", member)
+			}
+			delete(ref, member)
+			continue
+		}
+		score := float64(rand.Intn(50))
+		set.Add(member, score)
+		ref[member] = score
+	}
+	expected := sortedElements(ref)
+	if set.Len() != int64(len(expected)) || set.skiplist.length != int64(len(expected)) {
+		t.Fatalf("expected len %d, actually %d", len(expected), set.Len())
+	}
+	assertElements(t, set.Range(0, set.Len(), false), expected)
+	for i, element := range expected {
+		if rank := set.GetRank(element.Member, false); rank != int64(i) {
+			t.Errorf("expected rank of %s is %d, actually %d", element.Member, i, rank)
+		}
+		if rank := set.GetRank(element.Member, true); rank != int64(len(expected)-1-i) {
+			t.Errorf("expected rev rank of %s is %d, actually %d", element.Member, len(expected)-1-i, rank)
+		}
+	}
+}
+
+func TestRangeByScore(t *testing.T) {
+	set := Make()
+	for i := 0; i < 10; i++ {
+		set.Add(strconv.Itoa(i), float64(i))
+	}
+	min, _ := ParseScoreBorder("(2")
+	max, _ := ParseScoreBorder("5")
+	result := set.RangeByScore(min, max, 0, -1, false)
+	assertElements(t, result, []*Element{{"3", 3}, {"4", 4}, {"5", 5}})
+	result = set.RangeByScore(min, max, 1, 1, true)
+	assertElements(t, result, []*Element{{"4", 4}})
+	// offset out of range
+	result = set.RangeByScore(min, max, 5, -1, false)
+	assertElements(t, result, []*Element{})
+	if n := set.Count(min, max); n != 3 {
+		t.Errorf("expected count 3, actually %d", n)
+	}
+
+	min, _ = ParseScoreBorder("-inf")
+	max, _ = ParseScoreBorder("(0")
+	if n := set.Count(min, max); n != 0 {
+		t.Errorf("expected count 0, actually %d", n)
+	}
+	max, _ = ParseScoreBorder("+inf")
+	if n := set.Count(min, max); n != 10 {
+		t.Errorf("expected count 10, actually %d", n)
+	}
+}
+
+func TestRemoveRange(t *testing.T) {
+	set := Make()
+	for i := 0; i < 10; i++ {
+		set.Add(strconv.Itoa(i), float64(i))
+	}
+	min, _ := ParseScoreBorder("2")
+	max, _ := ParseScoreBorder("(5")
+	if n := set.RemoveByScore(min, max); n != 3 {
+		t.Errorf("expected 3 removed, actually %d", n)
+	}
+	if n := set.RemoveByRank(0, 2); n != 2 {
+		t.Errorf("expected 2 removed, actually %d", n)
+	}
+	assertElements(t, set.Range(0, set.Len(), false), []*Element{{"5", 5}, {"6", 6}, {"7", 7}, {"8", 8}, {"9", 9}})
+	if _, ok := set.Get("0"); ok {
+		t.Error("removed member should not be found")
+	}
+	// empty range should not panic
+	set.Range(0, 0, false)
+}
+
+func TestRangeByLex(t *testing.T) {
+	set := Make()
+	for _, member := range []string{"a", "b", "c", "d", "e"} {
+		set.Add(member, 0)
+	}
+	min, _ := ParseLexBorder("(a")
+	max, _ := ParseLexBorder("[d")
+	result := set.RangeByLex(min, max, 0, -1, false)
+	assertElements(t, result, []*Element{{"b", 0}, {"c", 0}, {"d", 0}})
+	result = set.RangeByLex(min, max, 1, 1, true)
+	assertElements(t, result, []*Element{{"c", 0}})
+	if n := set.CountByLex(min, max); n != 3 {
+		t.Errorf("expected count 3, actually %d", n)
+	}
+
+	min, _ = ParseLexBorder("-")
+	max, _ = ParseLexBorder("+")
+	if n := set.CountByLex(min, max); n != 5 {
+		t.Errorf("expected count 5, actually %d", n)
+	}
+	min, _ = ParseLexBorder("[c")
+	max, _ = ParseLexBorder("(c")
+	if n := set.CountByLex(min, max); n != 0 {
+		t.Errorf("expected count 0, actually %d", n)
+	}
+
+	min, _ = ParseLexBorder("[b")
+	max, _ = ParseLexBorder("(e")
+	if n := set.RemoveByLex(min, max); n != 3 {
+		t.Errorf("expected 3 removed, actually %d", n)
+	}
+	assertElements(t, set.Range(0, set.Len(), false), []*Element{{"a", 0}, {"e", 0}})
+
+	if _, err := ParseLexBorder("a"); err == nil {
+		t.Error("expected error for border without prefix")
+	}
+}
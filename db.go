@@ -1,15 +1,23 @@
 package JZ_Redis
 
 import (
+	"JZ_Redis/cluster"
 	"JZ_Redis/config"
 	"JZ_Redis/datastruct/dict"
 	"JZ_Redis/datastruct/lock"
 	"JZ_Redis/interface/redis"
 	"JZ_Redis/lib/logger"
+	"JZ_Redis/lib/utils"
 	"JZ_Redis/pubsub"
 	"JZ_Redis/redis/reply"
+	"fmt"
 	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -17,6 +25,7 @@ const (
 	ttlDictSize = 1 << 10
 	lockerSize = 1024
 	aofQueueSize = 1 << 16
+	defaultAofDirname = "appendonlydir"
 )
 
 // DB stores data and execute user's commands
@@ -31,28 +40,61 @@ type DB struct {
 	// dict.Dict will ensure concurrent-safety of ite method
 	// use this mutex for complicated command only, eg. rpush, incr ...
 	locker *lock.Locks
-	// stop all data access for execFlushDB
-	stopWorld sync.WaitGroup
 	// handle publish/subscribe
 	hub *pubsub.Hub
+	// route shard channels to the node owning its slot
+	router *cluster.Router
+	// flags of enabled keyspace notifications, see notify.go
+	keyspaceEvents int
+	// clients blocked by BLPOP and so on
+	blocking *blockingQueues
+	// key -> *txEffects, side effects on write keys of running transactions are held until EXEC succeeds
+	txKeys dict.Dict
+	// number of running transactions, accessed atomically
+	txRunning int32
 
 	// main goroutine send commands to aof goroutine through aofChan
 	// 主线程使用此channel将要持久化的命令发送到异步协程
-	aofChan     chan *reply.MultiBulkReply
+	aofChan     chan *aofPayload
 	// append file 文件描述符
 	aofFile     *os.File
-	// append file路径
+	// base name of aof files, the appendfilename config
 	aofFilename string
+	// directory of aof files and manifest
+	aofDirname string
+	// files of multi-part aof, only modified by aof rewrite
+	aofManifest *aofManifest
 	// aof goroutine will send msg to main goroutine through this channel when aof tasks finished and ready to shutdown
 	aofFinished chan struct{}
+	// appendfsync policy: always, everysec or no
+	aofFsync string
+	// unix nano time of the last fsync of aof file, accessed atomically
+	aofLastFsync int64
+	// bytes written into aof file but not fsynced yet, accessed atomically
+	aofUnsyncedBytes int64
+	// size of aof file, and its size after last rewrite, used by auto rewrite
+	aofCurrentSize int64
+	aofBaseSize    int64
+	// guards against concurrent rewrites and records rewrite status
+	aofRewriting *bgJobState
 
-	// buffer commands received during aof rewrite progress
-	// aof重写需要的缓冲区
-	aofRewriteBuffer chan *reply.MultiBulkReply
+	// path of rdb snapshot file
+	rdbFilename string
+	// guards against concurrent saves and records save status
+	rdbSaving *bgJobState
+	// number of changes since last save, accessed atomically
+	dirty int64
+	// bgsave is triggered automatically if any of them is satisfied
+	saveParams []saveParam
 
 	// pause aof for start/finish aof rewrite progress
 	// 在必要的时候使用此字段停止持久化操作
 	pausingAof sync.RWMutex
+
+	// closed when db is closing, stops background goroutines such as active expire
+	closing chan struct{}
+	// background goroutines writing db, Close waits for them before closing aof
+	background sync.WaitGroup
 }
 
 // DataEntity stores data bound to a key, including a string, list, hash, set and so on
@@ -83,23 +125,418 @@ func MakeDB() *DB {
 		versionMap: dict.MakeConcurrent(dataDictSize),
 		locker:     lock.Make(lockerSize),
 		hub:        pubsub.MakeHub(),
+		router:     cluster.MakeRouter(config.Properties.Self, config.Properties.Peers, config.Properties.RequirePass),
+		blocking:   makeBlockingQueues(),
+		txKeys:     dict.MakeConcurrent(lockerSize),
+		closing:    make(chan struct{}),
+
+		aofRewriting: makeBgJobState(),
+		rdbFilename:  config.Properties.DBFilename,
+		rdbSaving:    makeBgJobState(),
+	}
+	keyspaceEvents, err := parseKeyspaceEvents(config.Properties.NotifyKeyspaceEvents)
+	if err != nil {
+		logger.Warn(err)
+	} else {
+		db.keyspaceEvents = keyspaceEvents
+	}
+
+	saveParams, err := parseSaveParams(config.Properties.Save)
+	if err != nil {
+		logger.Warn(err)
+	} else {
+		db.saveParams = saveParams
 	}
 
+	// load aof if it exists, otherwise load rdb
+	// refuse to start with broken data
+	loadedRdb := false
+	if config.Properties.AppendOnly {
+		db.aofFilename = filepath.Base(config.Properties.AppendFilename)
+		dirname := config.Properties.AppendDirname
+		if dirname == "" {
+			dirname = defaultAofDirname
+		}
+		// aof dir is beside appendfilename
+		db.aofDirname = filepath.Join(filepath.Dir(config.Properties.AppendFilename), dirname)
+		if err := db.openAofManifest(config.Properties.AppendFilename); err != nil {
+			panic(err)
+		}
+	}
+	if db.aofManifest != nil && len(db.aofManifest.files()) > 0 {
+		if err := db.loadAof(); err != nil {
+			panic(err)
+		}
+	} else if db.rdbFilename != "" && fileExists(db.rdbFilename) {
+		if err := db.loadRdb(); err != nil {
+			panic(err)
+		}
+		loadedRdb = true
+	}
+	// changes made by loading are not dirty
+	atomic.StoreInt64(&db.dirty, 0)
+
 	// aof
 	if config.Properties.AppendOnly {
-		db.aofFilename = config.Properties.AppendFilename
-		db.loadAof(0)
-		aofFile, err := os.OpenFile(db.aofFilename, os.O_APPEND|os.O_CREATE|os.O_RDWR, 0600)
-		if err != nil {
+		if err := db.openIncrAof(); err != nil {
 			logger.Warn(err)
 		} else {
-			db.aofFile = aofFile
-			db.aofChan = make(chan *reply.MultiBulkReply, aofQueueSize)
+			db.aofChan = make(chan *aofPayload, aofQueueSize)
+			db.resetAofSize()
 		}
 		db.aofFinished = make(chan struct{})
+		db.aofFsync = parseAppendFsync(config.Properties.AppendFsync)
+		db.aofLastFsync = time.Now().UnixNano()
 		go func() {
 			db.handleAof()
 		}()
+		if db.aofFsync == aofFsyncEverySec {
+			go db.fsyncEverySec()
+		}
+		// aof starts empty when data is loaded from rdb, write the loaded data into it
+		if loadedRdb && db.aofFile != nil {
+			if err := db.aofRewrite(); err != nil {
+				logger.Warn(err)
+			}
+		}
+	}
+	if len(db.saveParams) > 0 {
+		db.background.Add(1)
+		go func() {
+			defer db.background.Done()
+			db.saveCron()
+		}()
 	}
+	db.background.Add(1)
+	go func() {
+		defer db.background.Done()
+		db.activeExpire()
+	}()
 	return db
-}
\ No newline at end of file
+}
+
+// Exec executes command
+// parameter `cmdLine` contains command and its arguments, for example: "set key value"
+func (db *DB) Exec(c redis.Connection, cmdLine [][]byte) (result redis.Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn(fmt.Sprintf("error occurs: %v\n%s", err, string(debug.Stack())))
+			result = &reply.UnknownErrReply{}
+		}
+	}()
+
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	// authenticate
+	if cmdName == "auth" {
+		return Auth(db, c, cmdLine[1:])
+	}
+	if !isAuthenticated(c) {
+		return reply.MakeErrReply("NOAUTH Authentication required")
+	}
+
+	// subscribed connection could only execute pub/sub commands
+	if errReply := checkPubSubContext(c, cmdName); errReply != nil {
+		return errReply
+	}
+
+	// special commands
+	// 与连接状态相关或需要暂停整个数据库的命令不走普通的执行流程
+	result, done := execSpecialCmd(db, c, cmdLine, cmdName)
+	if done {
+		return result
+	}
+
+	// blocking commands
+	if isBlockingCmd(cmdName) {
+		return execBlockingCommand(db, c, cmdLine)
+	}
+
+	// normal commands
+	return execNormalCommand(db, cmdLine)
+}
+
+// execSpecialCmd handles commands which should not be executed under key locks
+// returns false if the given command is a normal command
+func execSpecialCmd(db *DB, c redis.Connection, cmdLine [][]byte, cmdName string) (redis.Reply, bool) {
+	switch cmdName {
+	case "multi":
+		if len(cmdLine) != 1 {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return StartMulti(c), true
+	case "exec":
+		if len(cmdLine) != 1 {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return execMulti(db, c), true
+	case "discard":
+		if len(cmdLine) != 1 {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return DiscardMulti(c), true
+	case "watch":
+		if !validateArity(-2, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return Watch(db, c, cmdLine[1:]), true
+	case "unwatch":
+		if len(cmdLine) != 1 {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return UnWatch(c), true
+	case "subscribe":
+		if !validateArity(-2, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return pubsub.Subscribe(db.hub, c, cmdLine[1:]), true
+	case "unsubscribe":
+		return pubsub.UnSubscribe(db.hub, c, cmdLine[1:]), true
+	case "psubscribe":
+		if !validateArity(-2, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return pubsub.PSubscribe(db.hub, c, cmdLine[1:]), true
+	case "punsubscribe":
+		return pubsub.PUnSubscribe(db.hub, c, cmdLine[1:]), true
+	case "ssubscribe":
+		if !validateArity(-2, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return execSSubscribe(db, c, cmdLine[1:]), true
+	case "sunsubscribe":
+		return pubsub.SUnSubscribe(db.hub, c, cmdLine[1:]), true
+	case "ping":
+		if c != nil && c.SubsCount() > 0 {
+			return pingInSubscribedState(cmdLine[1:]), true
+		}
+	}
+	// 事务中的其它命令只入队, 在 EXEC 时一起执行
+	if c != nil && c.InMultiState() {
+		return EnqueueCmd(c, cmdLine), true
+	}
+	// FLUSHDB locks all keys, it's not allowed in MULTI for the same reason as SAVE
+	if cmdName == "flushdb" {
+		if !validateArity(-1, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return execFlushDB(db, cmdLine[1:]), true
+	}
+	// SAVE blocks all writers, it would deadlock with keys locked by EXEC, so it's not allowed in MULTI
+	if cmdName == "save" {
+		if !validateArity(1, cmdLine) {
+			return reply.MakeArgNumErrReply(cmdName), true
+		}
+		return execSave(db, cmdLine[1:]), true
+	}
+	return nil, false
+}
+
+func execNormalCommand(db *DB, cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return reply.MakeErrReply("ERR unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return reply.MakeArgNumErrReply(cmdName)
+	}
+
+	prepare := cmd.prepare
+	write, read := prepare(cmdLine[1:])
+	locked := db.lockWithMoveDests(write, read)
+	defer db.RWUnLocks(locked, read)
+	db.addVersion(write...)
+	fun := cmd.executor
+	return fun(db, cmdLine[1:])
+}
+
+// validateArity checks number of args, the command name is included in cmdArgs
+// arity < 0 means len(cmdArgs) >= -arity
+func validateArity(arity int, cmdArgs [][]byte) bool {
+	argNum := len(cmdArgs)
+	if arity >= 0 {
+		return argNum == arity
+	}
+	return argNum >= -arity
+}
+
+// fileExists returns true if the file exists, or it can't be checked, so that loading reports the error
+func fileExists(filename string) bool {
+	_, err := os.Stat(filename)
+	return !os.IsNotExist(err)
+}
+
+/* ---- Lock Function ----- */
+
+// RWLocks lock keys for writing and reading
+func (db *DB) RWLocks(writeKeys []string, readKeys []string) {
+	db.locker.RWLocks(writeKeys, readKeys)
+}
+
+// RWUnLocks unlock keys for writing and reading
+func (db *DB) RWUnLocks(writeKeys []string, readKeys []string) {
+	db.locker.RWUnLocks(writeKeys, readKeys)
+}
+
+/* ---- Data Access ----- */
+
+// GetEntity returns DataEntity bind to given key
+func (db *DB) GetEntity(key string) (*DataEntity, bool) {
+	raw, ok := db.data.Get(key)
+	if !ok {
+		return nil, false
+	}
+	if db.IsExpired(key) {
+		return nil, false
+	}
+	entity, _ := raw.(*DataEntity)
+	return entity, true
+}
+
+// PutEntity a DataEntity into DB
+func (db *DB) PutEntity(key string, entity *DataEntity) int {
+	db.IsExpired(key) // expired key is treated as absent
+	return db.data.Put(key, entity)
+}
+
+// PutIfExists edit an existing DataEntity
+func (db *DB) PutIfExists(key string, entity *DataEntity) int {
+	db.IsExpired(key)
+	return db.data.PutIfExists(key, entity)
+}
+
+// PutIfAbsent insert an DataEntity only if the key not exists
+func (db *DB) PutIfAbsent(key string, entity *DataEntity) int {
+	db.IsExpired(key)
+	return db.data.PutIfAbsent(key, entity)
+}
+
+// Remove the given key from db
+func (db *DB) Remove(key string) {
+	db.data.Remove(key)
+	db.ttlMap.Remove(key)
+}
+
+// Removes the given keys from db
+func (db *DB) Removes(keys ...string) (deleted int) {
+	deleted = 0
+	for _, key := range keys {
+		_, exists := db.GetEntity(key)
+		if exists {
+			db.Remove(key)
+			deleted++
+		}
+	}
+	return deleted
+}
+
+// Flush clean database
+func (db *DB) Flush() {
+	db.locker.LockAll()
+	defer db.locker.UnLockAll()
+	db.flush()
+}
+
+// flush removes all keys, the invoker should hold all locks by LockAll
+func (db *DB) flush() {
+	// removed keys are changed for WATCH
+	db.data.ForEach(func(key string, val interface{}) bool {
+		db.addVersion(key)
+		return true
+	})
+	db.data.Clear()
+	db.ttlMap.Clear()
+}
+
+/* ---- TTL Functions ---- */
+
+// IsExpired check whether a key is expired, the expired key will be removed lazily
+// 惰性删除: 在访问 key 时检查其是否过期
+func (db *DB) IsExpired(key string) bool {
+	if !db.hasExpired(key, time.Now()) {
+		return false
+	}
+	db.expireKey(key)
+	return true
+}
+
+// hasExpired checks ttl of key without removing it, used where the key is not locked
+func (db *DB) hasExpired(key string, now time.Time) bool {
+	rawExpireTime, ok := db.ttlMap.Get(key)
+	if !ok {
+		return false
+	}
+	expireTime, _ := rawExpireTime.(time.Time)
+	return now.After(expireTime)
+}
+
+// expireKey removes an expired key, both lazy and active expiration use it.
+// The version of key is increased so that WATCH notices the deletion, and the deletion is propagated to aof as DEL
+func (db *DB) expireKey(key string) {
+	db.data.Remove(key)
+	// readers of the key may expire it concurrently, only one of them propagates the deletion
+	if db.ttlMap.Remove(key) == 0 {
+		return
+	}
+	db.addVersion(key)
+	// expiration is not undone by rollback, so it bypasses the side effects held by transaction
+	db.writeAof(reply.MakeMultiBulkReply(utils.ToCmdLine("del", key)))
+	db.publishKeyspaceEvent(notifyExpired, "expired", key)
+}
+
+// Expire sets ttlCmd of key
+func (db *DB) Expire(key string, expireTime time.Time) {
+	db.ttlMap.Put(key, expireTime)
+}
+
+// Persist cancel ttlCmd of key
+func (db *DB) Persist(key string) {
+	db.ttlMap.Remove(key)
+}
+
+/* ---- Version Functions ---- */
+
+// addVersion increases version code of the given keys, it is used by `watch`
+func (db *DB) addVersion(keys ...string) {
+	for _, key := range keys {
+		versionCode := db.GetVersion(key)
+		db.versionMap.Put(key, versionCode+1)
+	}
+}
+
+// GetVersion returns version code for given key
+func (db *DB) GetVersion(key string) uint32 {
+	entity, ok := db.versionMap.Get(key)
+	if !ok {
+		return 0
+	}
+	return entity.(uint32)
+}
+
+// AfterClientClose does some clean after client close connection
+func (db *DB) AfterClientClose(c redis.Connection) {
+	// wake up the blocking command of the client, so that it won't be served any more
+	db.blocking.cancelConn(c)
+	pubsub.UnsubscribeAll(db.hub, c)
+}
+
+// Close graceful shutdown database
+func (db *DB) Close() {
+	close(db.closing)
+	db.background.Wait()
+	db.rdbSaving.wait()
+	db.router.Close()
+	if db.aofFile != nil {
+		// running rewrite sends to aofChan, no rewrite starts once closing
+		db.aofRewriting.wait()
+		close(db.aofChan)
+		<-db.aofFinished // wait for aof finished
+		// wait for the running background fsync
+		db.pausingAof.Lock()
+		defer db.pausingAof.Unlock()
+		err := db.aofFile.Close()
+		if err != nil {
+			logger.Warn(err)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package JZ_Redis
+
+import "time"
+
+/*
+ * Active expiration
+ * 惰性删除只在访问 key 时才能发现其已过期, 从不被访问的 key 会一直占用内存
+ * 因此后台协程定期从 ttlMap 中随机抽样并删除其中已过期的 key, 算法与 redis 的 activeExpireCycle 相同:
+ *   1. 随机抽取 activeExpireSampleSize 个设置了过期时间的 key
+ *   2. 删除其中已经过期的 key
+ *   3. 若过期 key 的比例超过 25% 则认为仍有大量过期 key, 重复步骤 1, 直到超出本轮的时间限制
+ */
+
+const (
+	activeExpireInterval   = 100 * time.Millisecond
+	activeExpireSampleSize = 20
+	// stop current cycle if it has taken more than 25% of interval
+	activeExpireTimeLimit = activeExpireInterval / 4
+)
+
+// activeExpire runs activeExpireCycle periodically until db is closed
+func (db *DB) activeExpire() {
+	ticker := time.NewTicker(activeExpireInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.activeExpireCycle()
+		case <-db.closing:
+			return
+		}
+	}
+}
+
+// activeExpireCycle samples keys with ttl and removes the expired ones
+func (db *DB) activeExpireCycle() {
+	start := time.Now()
+	for db.ttlMap.Len() > 0 {
+		keys := db.ttlMap.RandomKeys(activeExpireSampleSize)
+		expired := 0
+		for _, key := range keys {
+			if db.expireIfNeeded(key) {
+				expired++
+			}
+		}
+		// less than 25% of sampled keys are expired, there are not many expired keys left
+		if expired*4 <= len(keys) {
+			return
+		}
+		if time.Since(start) > activeExpireTimeLimit {
+			return
+		}
+	}
+}
+
+// expireIfNeeded removes the key if it has expired
+func (db *DB) expireIfNeeded(key string) bool {
+	// lock the key, prevent from racing with commands writing it
+	db.locker.Lock(key)
+	defer db.locker.UnLock(key)
+	return db.IsExpired(key)
+}
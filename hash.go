@@ -0,0 +1,499 @@
+package JZ_Redis
+
+import (
+	Dict "JZ_Redis/datastruct/dict"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+	"github.com/shopspring/decimal"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func (db *DB) getAsDict(key string) (Dict.Dict, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	dict, ok := entity.Data.(Dict.Dict)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return dict, nil
+}
+
+func (db *DB) getOrInitDict(key string) (dict Dict.Dict, inited bool, errReply reply.ErrorReply) {
+	dict, errReply = db.getAsDict(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if dict == nil {
+		dict = Dict.MakeSimple()
+		db.PutEntity(key, &DataEntity{
+			Data: dict,
+		})
+		inited = true
+	}
+	return dict, inited, nil
+}
+
+// execHSet sets field in hash table, returns the number of new fields
+func execHSet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	if len(args)%2 != 1 {
+		return reply.MakeArgNumErrReply("hset")
+	}
+	key := string(args[0])
+
+	// get or init entity
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := 0
+	for i := 1; i < len(args); i += 2 {
+		field := string(args[i])
+		value := args[i+1]
+		result += dict.Put(field, value)
+	}
+	db.AddAof(makeAofCmd("hset", args))
+	db.notifyKeyspaceEvent(notifyHash, "hset", key)
+	return reply.MakeIntReply(int64(result))
+}
+
+func undoHSet(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	fields := make([]string, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		fields = append(fields, string(args[i]))
+	}
+	return rollbackHashFields(db, key, fields...)
+}
+
+// execHSetNX sets field in hash table only if field not exists
+func execHSetNX(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+	value := args[2]
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	result := dict.PutIfAbsent(field, value)
+	if result > 0 {
+		db.AddAof(makeAofCmd("hsetnx", args))
+		db.notifyKeyspaceEvent(notifyHash, "hset", key)
+	}
+	return reply.MakeIntReply(int64(result))
+}
+
+// execHGet gets field value of hash table
+func execHGet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.NullBulkReply{}
+	}
+
+	raw, exists := dict.Get(field)
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	value, _ := raw.([]byte)
+	return reply.MakeBulkReply(value)
+}
+
+// execHExists checks if a hash field exists
+func execHExists(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	_, exists := dict.Get(field)
+	if exists {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execHDel deletes a hash field
+func execHDel(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	fields := make([]string, len(args)-1)
+	fieldArgs := args[1:]
+	for i, v := range fieldArgs {
+		fields[i] = string(v)
+	}
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	deleted := 0
+	for _, field := range fields {
+		result := dict.Remove(field)
+		deleted += result
+	}
+	if deleted > 0 {
+		db.AddAof(makeAofCmd("hdel", args))
+		db.notifyKeyspaceEvent(notifyHash, "hdel", key)
+	}
+	if dict.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+
+	return reply.MakeIntReply(int64(deleted))
+}
+
+func undoHDel(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	fields := make([]string, 0, len(args)-1)
+	for _, arg := range args[1:] {
+		fields = append(fields, string(arg))
+	}
+	return rollbackHashFields(db, key, fields...)
+}
+
+// execHLen gets number of fields in hash table
+func execHLen(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(dict.Len()))
+}
+
+// execHStrlen gets string length of field value
+func execHStrlen(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	field := string(args[1])
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	raw, exists := dict.Get(field)
+	if exists {
+		value, _ := raw.([]byte)
+		return reply.MakeIntReply(int64(len(value)))
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execHMSet sets multi fields in hash table
+func execHMSet(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	if len(args)%2 != 1 {
+		return reply.MakeArgNumErrReply("hmset")
+	}
+	key := string(args[0])
+
+	// get or init entity
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	// put data
+	for i := 1; i < len(args); i += 2 {
+		dict.Put(string(args[i]), args[i+1])
+	}
+	db.AddAof(makeAofCmd("hmset", args))
+	db.notifyKeyspaceEvent(notifyHash, "hset", key)
+	return &reply.OkReply{}
+}
+
+// execHMGet gets multi fields in hash table
+func execHMGet(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	size := len(args) - 1
+	fields := make([]string, size)
+	for i := 0; i < size; i++ {
+		fields[i] = string(args[i+1])
+	}
+
+	// get entity
+	result := make([][]byte, size)
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return reply.MakeMultiBulkReply(result)
+	}
+
+	for i, field := range fields {
+		value, ok := dict.Get(field)
+		if !ok {
+			result[i] = nil
+		} else {
+			bytes, _ := value.([]byte)
+			result[i] = bytes
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execHKeys gets all field names in hash table
+func execHKeys(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	fields := make([][]byte, dict.Len())
+	i := 0
+	dict.ForEach(func(key string, val interface{}) bool {
+		fields[i] = []byte(key)
+		i++
+		return true
+	})
+	return reply.MakeMultiBulkReply(fields[:i])
+}
+
+// execHVals gets all field value in hash table
+func execHVals(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	values := make([][]byte, dict.Len())
+	i := 0
+	dict.ForEach(func(key string, val interface{}) bool {
+		values[i], _ = val.([]byte)
+		i++
+		return true
+	})
+	return reply.MakeMultiBulkReply(values[:i])
+}
+
+// execHGetAll gets all key-value entries in hash table
+func execHGetAll(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get entity
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	size := dict.Len()
+	result := make([][]byte, size*2)
+	i := 0
+	dict.ForEach(func(key string, val interface{}) bool {
+		result[i] = []byte(key)
+		i++
+		result[i], _ = val.([]byte)
+		i++
+		return true
+	})
+	return reply.MakeMultiBulkReply(result[:i])
+}
+
+// execHIncrBy increments the integer value of a hash field by the given number
+func execHIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	rawDelta := string(args[2])
+	delta, err := strconv.ParseInt(rawDelta, 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	value, exists := dict.Get(field)
+	if !exists {
+		dict.Put(field, []byte(strconv.FormatInt(delta, 10)))
+		db.AddAof(makeAofCmd("hincrby", args))
+		db.notifyKeyspaceEvent(notifyHash, "hincrby", key)
+		return reply.MakeIntReply(delta)
+	}
+	val, err := strconv.ParseInt(string(value.([]byte)), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR hash value is not an integer")
+	}
+	if (delta > 0 && val > math.MaxInt64-delta) || (delta < 0 && val < math.MinInt64-delta) {
+		return reply.MakeErrReply("ERR increment or decrement would overflow")
+	}
+	val += delta
+	bytes := []byte(strconv.FormatInt(val, 10))
+	dict.Put(field, bytes)
+	db.AddAof(makeAofCmd("hincrby", args))
+	db.notifyKeyspaceEvent(notifyHash, "hincrby", key)
+	return reply.MakeIntReply(val)
+}
+
+func undoHIncr(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	field := string(args[1])
+	return rollbackHashFields(db, key, field)
+}
+
+// execHIncrByFloat increments the float value of a hash field by the given number
+func execHIncrByFloat(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[1])
+	rawDelta := string(args[2])
+	delta, err := decimal.NewFromString(rawDelta)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not a valid float")
+	}
+
+	// get or init entity
+	dict, _, errReply := db.getOrInitDict(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	value, exists := dict.Get(field)
+	if !exists {
+		resultBytes := []byte(delta.String())
+		dict.Put(field, resultBytes)
+		db.AddAof(makeAofCmd("hincrbyfloat", args))
+		db.notifyKeyspaceEvent(notifyHash, "hincrbyfloat", key)
+		return reply.MakeBulkReply(resultBytes)
+	}
+	val, err := decimal.NewFromString(string(value.([]byte)))
+	if err != nil {
+		return reply.MakeErrReply("ERR hash value is not a float")
+	}
+	result := val.Add(delta)
+	resultBytes := []byte(result.String())
+	dict.Put(field, resultBytes)
+	db.AddAof(makeAofCmd("hincrbyfloat", args))
+	db.notifyKeyspaceEvent(notifyHash, "hincrbyfloat", key)
+	return reply.MakeBulkReply(resultBytes)
+}
+
+// execHRandField returns random fields of hash table
+// positive count returns distinct fields, negative count allows the same field to be returned multiple times
+func execHRandField(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	count := 1
+	withValues := false
+	if len(args) > 3 {
+		return &reply.SyntaxErrReply{}
+	}
+	if len(args) >= 2 {
+		count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil {
+			return reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		count = int(count64)
+	}
+	if len(args) == 3 {
+		if strings.ToLower(string(args[2])) != "withvalues" {
+			return &reply.SyntaxErrReply{}
+		}
+		withValues = true
+	}
+
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return errReply
+	}
+	if dict == nil {
+		if len(args) == 1 {
+			return &reply.NullBulkReply{}
+		}
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	if len(args) == 1 {
+		fields := dict.RandomKeys(1)
+		return reply.MakeBulkReply([]byte(fields[0]))
+	}
+	var fields []string
+	if count >= 0 {
+		fields = dict.RandomDistinctKeys(count)
+	} else {
+		fields = dict.RandomKeys(-count)
+	}
+	result := make([][]byte, 0, len(fields)*2)
+	for _, field := range fields {
+		result = append(result, []byte(field))
+		if withValues {
+			raw, _ := dict.Get(field)
+			value, _ := raw.([]byte)
+			result = append(result, value)
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+func init() {
+	RegisterCommand("HSet", execHSet, writeFirstKey, undoHSet, -4)
+	RegisterCommand("HSetNX", execHSetNX, writeFirstKey, undoHSet, 4)
+	RegisterCommand("HGet", execHGet, readFirstKey, nil, 3)
+	RegisterCommand("HExists", execHExists, readFirstKey, nil, 3)
+	RegisterCommand("HDel", execHDel, writeFirstKey, undoHDel, -3)
+	RegisterCommand("HLen", execHLen, readFirstKey, nil, 2)
+	RegisterCommand("HStrlen", execHStrlen, readFirstKey, nil, 3)
+	RegisterCommand("HMSet", execHMSet, writeFirstKey, undoHSet, -4)
+	RegisterCommand("HMGet", execHMGet, readFirstKey, nil, -3)
+	RegisterCommand("HKeys", execHKeys, readFirstKey, nil, 2)
+	RegisterCommand("HVals", execHVals, readFirstKey, nil, 2)
+	RegisterCommand("HGetAll", execHGetAll, readFirstKey, nil, 2)
+	RegisterCommand("HIncrBy", execHIncrBy, writeFirstKey, undoHIncr, 4)
+	RegisterCommand("HIncrByFloat", execHIncrByFloat, writeFirstKey, undoHIncr, 4)
+	RegisterCommand("HRandField", execHRandField, readFirstKey, nil, -2)
+}
@@ -0,0 +1,193 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"sort"
+	"strconv"
+	"testing"
+)
+
+func TestHSet(t *testing.T) {
+	testDB.Flush()
+	size := 100
+
+	key := utils.RandString(10)
+	for i := 0; i < size; i++ {
+		field := strconv.Itoa(i)
+		value := utils.RandString(10)
+		result := testDB.Exec(nil, utils.ToCmdLine("hset", key, field, value))
+		asserts.AssertIntReply(t, result, 1)
+		result = testDB.Exec(nil, utils.ToCmdLine("hget", key, field))
+		asserts.AssertBulkReply(t, result, value)
+		result = testDB.Exec(nil, utils.ToCmdLine("hstrlen", key, field))
+		asserts.AssertIntReply(t, result, len(value))
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("hlen", key))
+	asserts.AssertIntReply(t, result, size)
+
+	// update existed field and insert new field
+	result = testDB.Exec(nil, utils.ToCmdLine("hset", key, "0", "a", "new", "b"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("hsetnx", key, "0", "c"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("hget", key, "0"))
+	asserts.AssertBulkReply(t, result, "a")
+	result = testDB.Exec(nil, utils.ToCmdLine("hexists", key, "new"))
+	asserts.AssertIntReply(t, result, 1)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("hset", key, "0"))
+	asserts.AssertErrReply(t, result, "ERR wrong number of arguments for 'hset' command")
+	result = testDB.Exec(nil, utils.ToCmdLine("hset", key, "0", "a", "1"))
+	asserts.AssertErrReply(t, result, "ERR wrong number of arguments for 'hset' command")
+}
+
+func TestHDel(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("hmset", key, "a", "1", "b", "2"))
+	result := testDB.Exec(nil, utils.ToCmdLine("hdel", key, "a", "c"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("hexists", key, "a"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("hdel", key, "b"))
+	asserts.AssertIntReply(t, result, 1)
+	// empty hash should be removed
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestHMGet(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	result := testDB.Exec(nil, utils.ToCmdLine("hmset", key, "a", "1", "b", "2"))
+	asserts.AssertStatusReply(t, result, "OK")
+	result = testDB.Exec(nil, utils.ToCmdLine("hmget", key, "a", "c", "b"))
+	expected := reply.MakeMultiBulkReply([][]byte{[]byte("1"), nil, []byte("2")})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Errorf("expected %s, actually %s", expected.ToBytes(), result.ToBytes())
+	}
+}
+
+func TestHGetAll(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	size := 10
+	fields := make([]string, size)
+	values := make([]string, size)
+	args := []string{key}
+	for i := 0; i < size; i++ {
+		fields[i] = utils.RandString(10)
+		values[i] = utils.RandString(10)
+		args = append(args, fields[i], values[i])
+	}
+	testDB.Exec(nil, utils.ToCmdLine2("hset", args...))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("hkeys", key))
+	assertUnorderedMultiBulk(t, result, fields)
+	result = testDB.Exec(nil, utils.ToCmdLine("hvals", key))
+	assertUnorderedMultiBulk(t, result, values)
+	result = testDB.Exec(nil, utils.ToCmdLine("hgetall", key))
+	asserts.AssertMultiBulkReplySize(t, result, size*2)
+	all := result.(*reply.MultiBulkReply).Args
+	for i := 0; i < len(all); i += 2 {
+		value := testDB.Exec(nil, utils.ToCmdLine("hget", key, string(all[i])))
+		asserts.AssertBulkReply(t, value, string(all[i+1]))
+	}
+
+	result = testDB.Exec(nil, utils.ToCmdLine("hgetall", utils.RandString(10)))
+	asserts.AssertMultiBulkReplySize(t, result, 0)
+}
+
+func assertUnorderedMultiBulk(t *testing.T, actual interface{}, expected []string) {
+	multiBulk, ok := actual.(*reply.MultiBulkReply)
+	if !ok {
+		t.Errorf("expected multi bulk reply, actually %v", actual)
+		return
+	}
+	values := make([]string, len(multiBulk.Args))
+	for i, arg := range multiBulk.Args {
+		values[i] = string(arg)
+	}
+	sorted := append([]string{}, expected...)
+	sort.Strings(values)
+	sort.Strings(sorted)
+	if len(values) != len(sorted) {
+		t.Errorf("expected %d elements, actually %d", len(sorted), len(values))
+		return
+	}
+	for i := range values {
+		if values[i] != sorted[i] {
+			t.Errorf("expected %v, actually %v", sorted, values)
+			return
+		}
+	}
+}
+
+func TestHIncrBy(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	result := testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "a", "1"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "a", "-3"))
+	asserts.AssertIntReply(t, result, -2)
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "a", "x"))
+	asserts.AssertErrReply(t, result, "ERR value is not an integer or out of range")
+	// delta of new field is stored in canonical form
+	testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "e", "+05"))
+	result = testDB.Exec(nil, utils.ToCmdLine("hget", key, "e"))
+	asserts.AssertBulkReply(t, result, "5")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrbyfloat", key, "b", "1.5"))
+	asserts.AssertBulkReply(t, result, "1.5")
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrbyfloat", key, "b", "0.25"))
+	asserts.AssertBulkReply(t, result, "1.75")
+
+	testDB.Exec(nil, utils.ToCmdLine("hset", key, "c", "abc"))
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "c", "1"))
+	asserts.AssertErrReply(t, result, "ERR hash value is not an integer")
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrbyfloat", key, "c", "1"))
+	asserts.AssertErrReply(t, result, "ERR hash value is not a float")
+
+	testDB.Exec(nil, utils.ToCmdLine("hset", key, "d", strconv.FormatInt(1<<62, 10)))
+	result = testDB.Exec(nil, utils.ToCmdLine("hincrby", key, "d", strconv.FormatInt(1<<62, 10)))
+	asserts.AssertErrReply(t, result, "ERR increment or decrement would overflow")
+}
+
+func TestHRandField(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	result := testDB.Exec(nil, utils.ToCmdLine("hrandfield", key))
+	asserts.AssertNullBulk(t, result)
+
+	testDB.Exec(nil, utils.ToCmdLine("hset", key, "a", "1", "b", "2", "c", "3"))
+	result = testDB.Exec(nil, utils.ToCmdLine("hrandfield", key))
+	if bulk, ok := result.(*reply.BulkReply); !ok || len(bulk.Arg) != 1 {
+		t.Errorf("expected a field, actually %s", result.ToBytes())
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("hrandfield", key, "5"))
+	asserts.AssertMultiBulkReplySize(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("hrandfield", key, "-5"))
+	asserts.AssertMultiBulkReplySize(t, result, 5)
+	result = testDB.Exec(nil, utils.ToCmdLine("hrandfield", key, "2", "withvalues"))
+	asserts.AssertMultiBulkReplySize(t, result, 4)
+	result = testDB.Exec(nil, utils.ToCmdLine("hrandfield", key, "2", "novalues"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+}
+
+func TestUndoHSet(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("hset", key, "a", "1"))
+	args := utils.ToCmdLine(key, "a", "2", "b", "3")
+	undoCmdLines := undoHSet(testDB, args)
+	testDB.Exec(nil, utils.ToCmdLine2("hset", key, "a", "2", "b", "3"))
+	for _, cmdLine := range undoCmdLines {
+		testDB.applyUndoLog(cmdLine)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("hget", key, "a"))
+	asserts.AssertBulkReply(t, result, "1")
+	result = testDB.Exec(nil, utils.ToCmdLine("hexists", key, "b"))
+	asserts.AssertIntReply(t, result, 0)
+}
@@ -0,0 +1,76 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/config"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// execInfo returns information of server, only persistence section is supported now
+func execInfo(db *DB, args [][]byte) redis.Reply {
+	sections := map[string]bool{}
+	for _, arg := range args {
+		sections[strings.ToLower(string(arg))] = true
+	}
+	all := len(args) == 0 || sections["default"] || sections["all"] || sections["everything"]
+
+	builder := &strings.Builder{}
+	if all || sections["persistence"] {
+		db.writePersistenceInfo(builder)
+	}
+	return reply.MakeBulkReply([]byte(builder.String()))
+}
+
+func writeInfoField(builder *strings.Builder, name string, value string) {
+	builder.WriteString(name)
+	builder.WriteByte(':')
+	builder.WriteString(value)
+	builder.WriteString(reply.CRLF)
+}
+
+func boolInfo(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// durationSec formats duration in seconds, negative duration means not available
+func durationSec(d time.Duration) string {
+	if d < 0 {
+		return "-1"
+	}
+	return strconv.FormatInt(int64(d/time.Second), 10)
+}
+
+func (db *DB) writePersistenceInfo(builder *strings.Builder) {
+	saving := db.rdbSaving.status()
+	rewriting := db.aofRewriting.status()
+
+	builder.WriteString("# Persistence" + reply.CRLF)
+	writeInfoField(builder, "rdb_changes_since_last_save", strconv.FormatInt(atomic.LoadInt64(&db.dirty), 10))
+	writeInfoField(builder, "rdb_bgsave_in_progress", boolInfo(saving.inProgress))
+	writeInfoField(builder, "rdb_last_save_time", strconv.FormatInt(saving.lastSuccess.Unix(), 10))
+	writeInfoField(builder, "rdb_last_bgsave_status", saving.lastStatus)
+	writeInfoField(builder, "rdb_last_bgsave_time_sec", durationSec(saving.lastDuration))
+	writeInfoField(builder, "rdb_current_bgsave_time_sec", durationSec(saving.currentDuration))
+	aofEnabled := config.Properties.AppendOnly && db.aofFile != nil
+	writeInfoField(builder, "aof_enabled", boolInfo(aofEnabled))
+	writeInfoField(builder, "aof_rewrite_in_progress", boolInfo(rewriting.inProgress))
+	writeInfoField(builder, "aof_last_rewrite_time_sec", durationSec(rewriting.lastDuration))
+	writeInfoField(builder, "aof_current_rewrite_time_sec", durationSec(rewriting.currentDuration))
+	writeInfoField(builder, "aof_last_bgrewrite_status", rewriting.lastStatus)
+	if aofEnabled {
+		writeInfoField(builder, "aof_current_size", strconv.FormatInt(atomic.LoadInt64(&db.aofCurrentSize), 10))
+		writeInfoField(builder, "aof_base_size", strconv.FormatInt(atomic.LoadInt64(&db.aofBaseSize), 10))
+		writeInfoField(builder, "aof_fsync_lag_ms", strconv.FormatInt(int64(db.aofFsyncLag()/time.Millisecond), 10))
+	}
+}
+
+func init() {
+	RegisterCommand("Info", execInfo, noPrepare, nil, -1)
+}
@@ -4,13 +4,19 @@ package redis
 type Connection interface {
 	Write([]byte) error
 	SetPassword(string)
-	GetPassword(string)
+	GetPassword() string
 
 	// client should keep its subscribing channels
 	Subscribe(channel string)
 	UnSubscribe(channel string)
 	SubsCount() int
 	GetChannels() []string
+	PSubscribe(pattern string)
+	PUnSubscribe(pattern string)
+	GetPatterns() []string
+	SSubscribe(channel string)
+	SUnSubscribe(channel string)
+	GetShardChannels() []string
 
 	// used for `Multi` command
 	InMultiState() bool
@@ -19,4 +25,6 @@ type Connection interface {
 	EnqueueCmd([][]byte)
 	ClearQueueCmds()
 	GetWatching() map[string]uint32
+	AddTxError(err error)
+	GetTxErrors() []error
 }
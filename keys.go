@@ -0,0 +1,379 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/datastruct/dict"
+	List "JZ_Redis/datastruct/list"
+	"JZ_Redis/datastruct/set"
+	SortedSet "JZ_Redis/datastruct/sortedset"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/lib/wildcard"
+	"JZ_Redis/redis/reply"
+	"math"
+	"strconv"
+	"time"
+)
+
+// execDel removes a key from db
+func execDel(db *DB, args [][]byte) redis.Reply {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+
+	deleted := 0
+	for _, key := range keys {
+		if db.Removes(key) > 0 {
+			db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.AddAof(makeAofCmd("del", args))
+	}
+	return reply.MakeIntReply(int64(deleted))
+}
+
+func undoDel(db *DB, args [][]byte) []CmdLine {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return rollbackGivenKeys(db, keys...)
+}
+
+// execExists checks if the given keys are existed in db, returns number of existed keys
+func execExists(db *DB, args [][]byte) redis.Reply {
+	result := int64(0)
+	for _, arg := range args {
+		key := string(arg)
+		_, exists := db.GetEntity(key)
+		if exists {
+			result++
+		}
+	}
+	return reply.MakeIntReply(result)
+}
+
+// execType returns the type of entity, including: string, list, hash, set and zset
+func execType(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeStatusReply("none")
+	}
+	switch entity.Data.(type) {
+	case []byte:
+		return reply.MakeStatusReply("string")
+	case *List.LinkedList:
+		return reply.MakeStatusReply("list")
+	case dict.Dict:
+		return reply.MakeStatusReply("hash")
+	case *set.Set:
+		return reply.MakeStatusReply("set")
+	case *SortedSet.SortedSet:
+		return reply.MakeStatusReply("zset")
+	}
+	return &reply.UnknownErrReply{}
+}
+
+// prepareRename returns both src and dest as write keys, src will be removed
+func prepareRename(args [][]byte) ([]string, []string) {
+	src := string(args[0])
+	dest := string(args[1])
+	return []string{src, dest}, nil
+}
+
+func undoRename(db *DB, args [][]byte) []CmdLine {
+	src := string(args[0])
+	dest := string(args[1])
+	return rollbackGivenKeys(db, src, dest)
+}
+
+// renameEntity moves entity and its ttl from src to dest
+func (db *DB) renameEntity(src string, dest string, entity *DataEntity) {
+	if src == dest {
+		return
+	}
+	rawTTL, hasTTL := db.ttlMap.Get(src)
+	db.Remove(dest) // clean dest and its ttl
+	db.PutEntity(dest, entity)
+	db.Remove(src)
+	if hasTTL {
+		expireTime, _ := rawTTL.(time.Time)
+		db.Expire(dest, expireTime)
+	}
+	db.signalListReady(dest)
+}
+
+// execRename renames a key, the existed dest key will be overwritten
+func execRename(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+
+	entity, ok := db.GetEntity(src)
+	if !ok {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	db.renameEntity(src, dest, entity)
+	db.AddAof(makeAofCmd("rename", args))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	return &reply.OkReply{}
+}
+
+// execRenameNx renames a key, only if the new key does not exist
+func execRenameNx(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+
+	_, ok := db.GetEntity(dest)
+	if ok {
+		return reply.MakeIntReply(0)
+	}
+
+	entity, ok := db.GetEntity(src)
+	if !ok {
+		return reply.MakeErrReply("ERR no such key")
+	}
+	db.renameEntity(src, dest, entity)
+	db.AddAof(makeAofCmd("renamenx", args))
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_from", src)
+	db.notifyKeyspaceEvent(notifyGeneric, "rename_to", dest)
+	return reply.MakeIntReply(1)
+}
+
+// expireAt sets expire time of an existed key, the key will be removed immediately if expireTime has passed
+func (db *DB) expireAt(key string, expireTime time.Time) redis.Reply {
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+	if !expireTime.After(time.Now()) {
+		db.Remove(key)
+		db.AddAof(reply.MakeMultiBulkReply(utils.ToCmdLine("del", key)))
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+		return reply.MakeIntReply(1)
+	}
+	db.Expire(key, expireTime)
+	db.AddAof(makeExpireCmd(key, expireTime))
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+	return reply.MakeIntReply(1)
+}
+
+// parseTTLArg parses integer argument of expire commands
+func parseTTLArg(arg []byte) (int64, reply.ErrorReply) {
+	val, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return val, nil
+}
+
+// toExpireTime converts ttl or unix timestamp in the given unit into expire time.
+// Expire time is persisted in milliseconds and computed in nanoseconds, so it must fit into int64 nanoseconds
+func toExpireTime(cmdName string, raw int64, unit time.Duration, relative bool) (time.Time, reply.ErrorReply) {
+	errReply := reply.MakeErrReply("ERR invalid expire time in '" + cmdName + "' command")
+	if raw > math.MaxInt64/int64(unit) || raw < math.MinInt64/int64(unit) {
+		return time.Time{}, errReply
+	}
+	nanos := raw * int64(unit)
+	if relative {
+		now := time.Now().UnixNano()
+		if nanos > math.MaxInt64-now {
+			return time.Time{}, errReply
+		}
+		nanos += now
+	}
+	return time.Unix(0, nanos), nil
+}
+
+// execExpire sets a key's time to live in seconds
+func execExpire(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	ttlArg, errReply := parseTTLArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	expireTime, errReply := toExpireTime("expire", ttlArg, time.Second, true)
+	if errReply != nil {
+		return errReply
+	}
+	return db.expireAt(key, expireTime)
+}
+
+// execPExpire sets a key's time to live in milliseconds
+func execPExpire(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	ttlArg, errReply := parseTTLArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	expireTime, errReply := toExpireTime("pexpire", ttlArg, time.Millisecond, true)
+	if errReply != nil {
+		return errReply
+	}
+	return db.expireAt(key, expireTime)
+}
+
+// execExpireAt sets a key's expiration in unix timestamp
+func execExpireAt(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	raw, errReply := parseTTLArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	expireTime, errReply := toExpireTime("expireat", raw, time.Second, false)
+	if errReply != nil {
+		return errReply
+	}
+	return db.expireAt(key, expireTime)
+}
+
+// execPExpireAt sets a key's expiration in unix timestamp specified in milliseconds
+func execPExpireAt(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	raw, errReply := parseTTLArg(args[1])
+	if errReply != nil {
+		return errReply
+	}
+	expireTime, errReply := toExpireTime("pexpireat", raw, time.Millisecond, false)
+	if errReply != nil {
+		return errReply
+	}
+	return db.expireAt(key, expireTime)
+}
+
+// remainTTL returns remaining time to live of a key, -2 means key not exists, -1 means key has no ttl
+func (db *DB) remainTTL(key string, unit time.Duration) int64 {
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return -2
+	}
+	raw, exists := db.ttlMap.Get(key)
+	if !exists {
+		return -1
+	}
+	expireTime, _ := raw.(time.Time)
+	ttl := expireTime.Sub(time.Now())
+	// round to the nearest unit, same as redis
+	return int64((ttl + unit/2) / unit)
+}
+
+// execTTL returns a key's time to live in seconds
+func execTTL(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	return reply.MakeIntReply(db.remainTTL(key, time.Second))
+}
+
+// execPTTL returns a key's time to live in milliseconds
+func execPTTL(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	return reply.MakeIntReply(db.remainTTL(key, time.Millisecond))
+}
+
+// execPersist removes expiration from a key
+func execPersist(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	_, exists := db.GetEntity(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+
+	_, exists = db.ttlMap.Get(key)
+	if !exists {
+		return reply.MakeIntReply(0)
+	}
+
+	db.Persist(key)
+	db.AddAof(makeAofCmd("persist", args))
+	db.notifyKeyspaceEvent(notifyGeneric, "persist", key)
+	return reply.MakeIntReply(1)
+}
+
+// undoExpire restores ttl of the key, it also works for `persist`
+func undoExpire(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	return []CmdLine{
+		toTTLCmd(db, key).Args,
+	}
+}
+
+// execKeys returns all keys matching the given pattern
+func execKeys(db *DB, args [][]byte) redis.Reply {
+	pattern := wildcard.CompilePattern(string(args[0]))
+	result := make([][]byte, 0)
+	now := time.Now()
+	db.data.ForEach(func(key string, val interface{}) bool {
+		if !pattern.IsMatch(key) {
+			return true
+		}
+		// don't use db.IsExpired here, removing key during ForEach will dead lock the shard
+		if db.hasExpired(key, now) {
+			return true
+		}
+		result = append(result, []byte(key))
+		return true
+	})
+	return reply.MakeMultiBulkReply(result)
+}
+
+// maxRandomKeyRetry limits how many expired keys RANDOMKEY may encounter before giving up
+const maxRandomKeyRetry = 100
+
+// execRandomKey returns a random key from db
+func execRandomKey(db *DB, args [][]byte) redis.Reply {
+	for i := 0; i < maxRandomKeyRetry && db.data.Len() > 0; i++ {
+		keys := db.data.RandomKeys(1)
+		if len(keys) == 0 {
+			break
+		}
+		// no key is locked, so the expired key is skipped rather than removed
+		if _, ok := db.data.Get(keys[0]); ok && !db.hasExpired(keys[0], time.Now()) {
+			return reply.MakeBulkReply([]byte(keys[0]))
+		}
+	}
+	return &reply.NullBulkReply{}
+}
+
+// execFlushDB removes all keys in db
+func execFlushDB(db *DB, args [][]byte) redis.Reply {
+	// commands writing after flushdb must be persisted after it, so aof is added before releasing locks
+	db.locker.LockAll()
+	defer db.locker.UnLockAll()
+	db.flush()
+	db.AddAof(makeAofCmd("flushdb", args))
+	return &reply.OkReply{}
+}
+
+// execDBSize returns the number of keys in db, expired keys waiting for deletion are not counted
+func execDBSize(db *DB, args [][]byte) redis.Reply {
+	now := time.Now()
+	expired := 0
+	db.ttlMap.ForEach(func(key string, val interface{}) bool {
+		if expireTime, _ := val.(time.Time); now.After(expireTime) {
+			expired++
+		}
+		return true
+	})
+	return reply.MakeIntReply(int64(db.data.Len() - expired))
+}
+
+func init() {
+	RegisterCommand("Del", execDel, writeAllKeys, undoDel, -2)
+	RegisterCommand("Exists", execExists, readAllKeys, nil, -2)
+	RegisterCommand("Type", execType, readFirstKey, nil, 2)
+	RegisterCommand("Rename", execRename, prepareRename, undoRename, 3)
+	RegisterCommand("RenameNx", execRenameNx, prepareRename, undoRename, 3)
+	RegisterCommand("Expire", execExpire, writeFirstKey, rollbackFirstKey, 3)
+	RegisterCommand("PExpire", execPExpire, writeFirstKey, rollbackFirstKey, 3)
+	RegisterCommand("ExpireAt", execExpireAt, writeFirstKey, rollbackFirstKey, 3)
+	RegisterCommand("PExpireAt", execPExpireAt, writeFirstKey, rollbackFirstKey, 3)
+	RegisterCommand("TTL", execTTL, readFirstKey, nil, 2)
+	RegisterCommand("PTTL", execPTTL, readFirstKey, nil, 2)
+	RegisterCommand("Persist", execPersist, writeFirstKey, undoExpire, 2)
+	RegisterCommand("Keys", execKeys, noPrepare, nil, 2)
+	RegisterCommand("RandomKey", execRandomKey, noPrepare, nil, 1)
+	RegisterCommand("DBSize", execDBSize, noPrepare, nil, 1)
+	RegisterCommand("FlushDB", execFlushDB, nil, nil, -1)
+}
@@ -0,0 +1,225 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var testDB = MakeDB()
+
+func TestExists(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	result := testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 1)
+	key = utils.RandString(10)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestType(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	result := testDB.Exec(nil, utils.ToCmdLine("type", key))
+	asserts.AssertStatusReply(t, result, "string")
+
+	testDB.Remove(key)
+	result = testDB.Exec(nil, utils.ToCmdLine("type", key))
+	asserts.AssertStatusReply(t, result, "none")
+}
+
+func TestDel(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key1, "a"))
+	testDB.Exec(nil, utils.ToCmdLine("set", key2, "b"))
+	result := testDB.Exec(nil, utils.ToCmdLine("del", key1, key2, utils.RandString(10)))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key1))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestRename(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	newKey := key + utils.RandString(2)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	result := testDB.Exec(nil, utils.ToCmdLine("rename", key, newKey))
+	asserts.AssertStatusReply(t, result, "OK")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("get", newKey))
+	asserts.AssertBulkReply(t, result, value)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("rename", key, newKey))
+	asserts.AssertErrReply(t, result, "ERR no such key")
+}
+
+func TestRenameNx(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	newKey := key + utils.RandString(2)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	testDB.Exec(nil, utils.ToCmdLine("set", newKey, value))
+	result := testDB.Exec(nil, utils.ToCmdLine("renamenx", key, newKey))
+	asserts.AssertIntReply(t, result, 0)
+
+	testDB.Exec(nil, utils.ToCmdLine("del", newKey))
+	result = testDB.Exec(nil, utils.ToCmdLine("renamenx", key, newKey))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("get", newKey))
+	asserts.AssertBulkReply(t, result, value)
+}
+
+func TestKeys(t *testing.T) {
+	testDB.Flush()
+	testDB.Exec(nil, utils.ToCmdLine("set", "hello", "1"))
+	testDB.Exec(nil, utils.ToCmdLine("set", "hallo", "1"))
+	testDB.Exec(nil, utils.ToCmdLine("set", "hxllo", "1"))
+	testDB.Exec(nil, utils.ToCmdLine("set", "world", "1"))
+	result := testDB.Exec(nil, utils.ToCmdLine("keys", "*"))
+	asserts.AssertMultiBulkReplySize(t, result, 4)
+	result = testDB.Exec(nil, utils.ToCmdLine("keys", "h?llo"))
+	asserts.AssertMultiBulkReplySize(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("keys", "h[ae]llo"))
+	asserts.AssertMultiBulkReplySize(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("dbsize"))
+	asserts.AssertIntReply(t, result, 4)
+}
+
+func TestRandomKey(t *testing.T) {
+	testDB.Flush()
+	result := testDB.Exec(nil, utils.ToCmdLine("randomkey"))
+	asserts.AssertNullBulk(t, result)
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	result = testDB.Exec(nil, utils.ToCmdLine("randomkey"))
+	asserts.AssertBulkReply(t, result, key)
+}
+
+func TestExpire(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	result := testDB.Exec(nil, utils.ToCmdLine("expire", key, "1000"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("ttl", key))
+	asserts.AssertIntReply(t, result, 1000)
+	result = testDB.Exec(nil, utils.ToCmdLine("pttl", key))
+	intResult, ok := result.(*reply.IntReply)
+	if !ok || intResult.Code <= 990000 || intResult.Code > 1000000 {
+		t.Errorf("expected pttl near 1000000, actually %s", result.ToBytes())
+	}
+
+	result = testDB.Exec(nil, utils.ToCmdLine("persist", key))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("ttl", key))
+	asserts.AssertIntReply(t, result, -1)
+	result = testDB.Exec(nil, utils.ToCmdLine("persist", key))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("expire", utils.RandString(10), "1000"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("ttl", utils.RandString(10)))
+	asserts.AssertIntReply(t, result, -2)
+
+	// expire in the past removes the key immediately
+	result = testDB.Exec(nil, utils.ToCmdLine("expire", key, "-1"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestExpireAt(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	expireAt := time.Now().Add(time.Minute)
+	result := testDB.Exec(nil, utils.ToCmdLine("expireat", key, strconv.FormatInt(expireAt.Unix(), 10)))
+	asserts.AssertIntReply(t, result, 1)
+	// expireat is in seconds, the fraction of current second has been truncated
+	result = testDB.Exec(nil, utils.ToCmdLine("ttl", key))
+	intResult, ok := result.(*reply.IntReply)
+	if !ok || intResult.Code < 59 || intResult.Code > 60 {
+		t.Errorf("expected ttl near 60, actually %s", result.ToBytes())
+	}
+
+	pExpireAt := time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond)
+	result = testDB.Exec(nil, utils.ToCmdLine("pexpireat", key, strconv.FormatInt(pExpireAt, 10)))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("ttl", key))
+	asserts.AssertIntReply(t, result, 3600)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("pexpire", key, "100"))
+	asserts.AssertIntReply(t, result, 1)
+	time.Sleep(150 * time.Millisecond)
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestExpireOverflow(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	for cmd, ttl := range map[string]string{
+		"expire":    "9223372037",
+		"pexpire":   "9223372036854775",
+		"expireat":  "9223372037",
+		"pexpireat": "9223372036854775",
+	} {
+		result := testDB.Exec(nil, utils.ToCmdLine(cmd, key, ttl))
+		asserts.AssertErrReply(t, result, "ERR invalid expire time in '"+cmd+"' command")
+		result = testDB.Exec(nil, utils.ToCmdLine("ttl", key))
+		asserts.AssertIntReply(t, result, -1)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("expire", key, "-9223372037"))
+	asserts.AssertErrReply(t, result, "ERR invalid expire time in 'expire' command")
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key))
+	asserts.AssertBulkReply(t, result, "1")
+}
+
+func TestActiveExpire(t *testing.T) {
+	db := MakeDB()
+	defer db.Close()
+	size := 100
+	for i := 0; i < size; i++ {
+		key := "expire" + strconv.Itoa(i)
+		db.Exec(nil, utils.ToCmdLine("set", key, "1", "PX", "10"))
+	}
+	db.Exec(nil, utils.ToCmdLine("set", "forever", "1"))
+	// keys are never read, only the active expiration cycle could remove them
+	time.Sleep(time.Second)
+	if db.data.Len() != 1 {
+		t.Errorf("expected 1 key left, actually %d", db.data.Len())
+	}
+	if db.ttlMap.Len() != 0 {
+		t.Errorf("expected empty ttl map, actually %d", db.ttlMap.Len())
+	}
+}
+
+func TestFlushDB(t *testing.T) {
+	testDB.Flush()
+	testDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	testDB.Exec(nil, utils.ToCmdLine("rpush", "b", "1"))
+	asserts.AssertStatusReply(t, testDB.Exec(nil, utils.ToCmdLine("flushdb")), "OK")
+	asserts.AssertIntReply(t, testDB.Exec(nil, utils.ToCmdLine("dbsize")), 0)
+
+	// flushdb locks all keys, it would dead lock with keys locked by exec
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	asserts.AssertErrReply(t, testDB.Exec(conn, utils.ToCmdLine("flushdb")), "ERR command 'flushdb' cannot be used in MULTI")
+	testDB.Exec(conn, utils.ToCmdLine("discard"))
+}
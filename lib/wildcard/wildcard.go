@@ -0,0 +1,160 @@
+package wildcard
+
+/*
+ * Pattern is a compiled glob-style pattern, same as the one used by redis `KEYS` command
+ * can accept:
+ *   *       matches any sequence of characters (including empty)
+ *   ?       matches exactly one character
+ *   [abc]   matches one character in the set, [a-z] for a range
+ *   [^abc]  matches one character not in the set
+ *   \x      escapes special character x
+ */
+
+const (
+	normal    = iota
+	all       // *
+	any       // ?
+	setSym    // [...]
+	negSetSym // [^...]
+)
+
+type item struct {
+	character byte
+	set       map[byte]bool
+	typeCode  int
+}
+
+func (i *item) contains(c byte) bool {
+	if i.typeCode == setSym {
+		return i.set[c]
+	}
+	// negSetSym
+	return !i.set[c]
+}
+
+// Pattern represents a wildcard pattern
+type Pattern struct {
+	items []*item
+}
+
+// CompilePattern convert wildcard string to Pattern
+func CompilePattern(src string) *Pattern {
+	items := make([]*item, 0)
+	escape := false
+	inSet := false
+	var set map[byte]bool
+	var setType int
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+		if escape {
+			if inSet {
+				set[ch] = true
+			} else {
+				items = append(items, &item{typeCode: normal, character: ch})
+			}
+			escape = false
+			continue
+		}
+		if ch == '\\' {
+			escape = true
+			continue
+		}
+		if inSet {
+			if ch == ']' {
+				items = append(items, &item{typeCode: setType, set: set})
+				inSet = false
+				continue
+			}
+			// range, for example [a-z]
+			if i+2 < len(src) && src[i+1] == '-' && src[i+2] != ']' {
+				low, high := ch, src[i+2]
+				if low > high {
+					low, high = high, low
+				}
+				for c := int(low); c <= int(high); c++ {
+					set[byte(c)] = true
+				}
+				i += 2
+				continue
+			}
+			set[ch] = true
+			continue
+		}
+		switch ch {
+		case '*':
+			// successive `*` are the same as one
+			if len(items) == 0 || items[len(items)-1].typeCode != all {
+				items = append(items, &item{typeCode: all})
+			}
+		case '?':
+			items = append(items, &item{typeCode: any})
+		case '[':
+			inSet = true
+			set = make(map[byte]bool)
+			setType = setSym
+			if i+1 < len(src) && src[i+1] == '^' {
+				setType = negSetSym
+				i++
+			}
+		default:
+			items = append(items, &item{typeCode: normal, character: ch})
+		}
+	}
+	if inSet {
+		// unclosed `[` is treated as a set ended at the end of pattern
+		items = append(items, &item{typeCode: setType, set: set})
+	}
+	if escape {
+		// trailing `\` matches itself
+		items = append(items, &item{typeCode: normal, character: '\\'})
+	}
+	return &Pattern{
+		items: items,
+	}
+}
+
+// IsMatch returns whether the given string matches pattern
+func (p *Pattern) IsMatch(s string) bool {
+	if len(p.items) == 0 {
+		return len(s) == 0
+	}
+	m := len(s)
+	n := len(p.items)
+	// table[i][j] means whether s[:i] matches items[:j]
+	table := make([][]bool, m+1)
+	for i := 0; i < m+1; i++ {
+		table[i] = make([]bool, n+1)
+	}
+	table[0][0] = true
+	for j := 1; j < n+1; j++ {
+		table[0][j] = table[0][j-1] && p.items[j-1].typeCode == all
+	}
+	for i := 1; i < m+1; i++ {
+		for j := 1; j < n+1; j++ {
+			it := p.items[j-1]
+			switch it.typeCode {
+			case all:
+				table[i][j] = table[i-1][j] || table[i][j-1]
+			case any:
+				table[i][j] = table[i-1][j-1]
+			case normal:
+				table[i][j] = table[i-1][j-1] && s[i-1] == it.character
+			default:
+				table[i][j] = table[i-1][j-1] && it.contains(s[i-1])
+			}
+		}
+	}
+	return table[m][n]
+}
+
+// Prefix returns the literal prefix of pattern, any matched string starts with it
+func (p *Pattern) Prefix() string {
+	prefix := make([]byte, 0)
+	for _, it := range p.items {
+		if it.typeCode != normal {
+			break
+		}
+		prefix = append(prefix, it.character)
+	}
+	return string(prefix)
+}
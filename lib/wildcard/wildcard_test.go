@@ -0,0 +1,56 @@
+package wildcard
+
+import "testing"
+
+func TestWildCard(t *testing.T) {
+	cases := []struct {
+		pattern string
+		src     string
+		match   bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"a", "a", true},
+		{"a", "b", false},
+		{"*", "", true},
+		{"*", "abc", true},
+		{"a*", "abc", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abcd", false},
+		{"**c", "abc", true},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h[a-c]llo", "hdllo", false},
+		{"orders.*", "orders.created", true},
+		{"orders.*", "users.created", false},
+		{"a\\*", "a*", true},
+		{"a\\*", "ab", false},
+	}
+	for _, c := range cases {
+		p := CompilePattern(c.pattern)
+		if p.IsMatch(c.src) != c.match {
+			t.Errorf("pattern %q against %q, expect %v", c.pattern, c.src, c.match)
+		}
+	}
+}
+
+func TestPrefix(t *testing.T) {
+	cases := map[string]string{
+		"orders.*": "orders.",
+		"*":        "",
+		"a?c":      "a",
+		"a\\*b*":   "a*b",
+		"abc":      "abc",
+		"[ab]c":    "",
+	}
+	for pattern, prefix := range cases {
+		if actual := CompilePattern(pattern).Prefix(); actual != prefix {
+			t.Errorf("prefix of %q: expect %q, actually %q", pattern, prefix, actual)
+		}
+	}
+}
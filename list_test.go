@@ -0,0 +1,299 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"fmt"
+	"strconv"
+	"testing"
+)
+
+func TestPush(t *testing.T) {
+	testDB.Flush()
+	size := 100
+
+	// rpush single
+	key := utils.RandString(10)
+	values := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		value := utils.RandString(10)
+		values[i] = []byte(value)
+		result := testDB.Exec(nil, utils.ToCmdLine("rpush", key, value))
+		asserts.AssertIntReply(t, result, i+1)
+	}
+	actual := testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	expected := reply.MakeMultiBulkReply(values)
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error("push error")
+	}
+	testDB.Remove(key)
+
+	// lpush multi
+	key = utils.RandString(10)
+	args := make([]string, size+1)
+	args[0] = key
+	expectedValues := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		value := utils.RandString(10)
+		args[i+1] = value
+		expectedValues[size-i-1] = []byte(value)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine2("lpush", args...))
+	asserts.AssertIntReply(t, result, size)
+	actual = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	expected = reply.MakeMultiBulkReply(expectedValues)
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error("push error")
+	}
+	testDB.Remove(key)
+}
+
+func TestPushX(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	result := testDB.Exec(nil, utils.ToCmdLine("rpushx", key, "1"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("lpushx", key, "1"))
+	asserts.AssertIntReply(t, result, 0)
+
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "1"))
+	result = testDB.Exec(nil, utils.ToCmdLine("rpushx", key, "2"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("lpushx", key, "0"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"0", "1", "2"})
+}
+
+func TestLRange(t *testing.T) {
+	// prepare list
+	testDB.Flush()
+	size := 100
+	key := utils.RandString(10)
+	values := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		value := utils.RandString(10)
+		testDB.Exec(nil, utils.ToCmdLine("rpush", key, value))
+		values[i] = []byte(value)
+	}
+
+	start := "0"
+	end := "9"
+	actual := testDB.Exec(nil, utils.ToCmdLine("lrange", key, start, end))
+	expected := reply.MakeMultiBulkReply(values[0:10])
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("range error [%s, %s]", start, end))
+	}
+
+	start = "0"
+	end = "200"
+	actual = testDB.Exec(nil, utils.ToCmdLine("lrange", key, start, end))
+	expected = reply.MakeMultiBulkReply(values)
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("range error [%s, %s]", start, end))
+	}
+
+	start = "0"
+	end = "-10"
+	actual = testDB.Exec(nil, utils.ToCmdLine("lrange", key, start, end))
+	expected = reply.MakeMultiBulkReply(values[0 : size-10+1])
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("range error [%s, %s]", start, end))
+	}
+
+	start = "0"
+	end = "-200"
+	actual = testDB.Exec(nil, utils.ToCmdLine("lrange", key, start, end))
+	asserts.AssertMultiBulkReplySize(t, actual, 0)
+
+	start = "-10"
+	end = "-1"
+	actual = testDB.Exec(nil, utils.ToCmdLine("lrange", key, start, end))
+	expected = reply.MakeMultiBulkReply(values[90:])
+	if !utils.BytesEquals(actual.ToBytes(), expected.ToBytes()) {
+		t.Error(fmt.Sprintf("range error [%s, %s]", start, end))
+	}
+}
+
+func TestLIndex(t *testing.T) {
+	// prepare list
+	testDB.Flush()
+	size := 100
+	key := utils.RandString(10)
+	values := make([][]byte, size)
+	for i := 0; i < size; i++ {
+		value := utils.RandString(10)
+		testDB.Exec(nil, utils.ToCmdLine("rpush", key, value))
+		values[i] = []byte(value)
+	}
+
+	result := testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, size)
+
+	for i := 0; i < size; i++ {
+		result = testDB.Exec(nil, utils.ToCmdLine("lindex", key, strconv.Itoa(i)))
+		asserts.AssertBulkReply(t, result, string(values[i]))
+	}
+
+	for i := 1; i <= size; i++ {
+		result = testDB.Exec(nil, utils.ToCmdLine("lindex", key, strconv.Itoa(-i)))
+		asserts.AssertBulkReply(t, result, string(values[size-i]))
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("lindex", key, strconv.Itoa(size)))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestLRem(t *testing.T) {
+	// prepare list
+	testDB.Flush()
+	key := utils.RandString(10)
+	values := []string{key, "a", "b", "a", "a", "c", "a", "a"}
+	testDB.Exec(nil, utils.ToCmdLine2("rpush", values...))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("lrem", key, "1", "a"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, 6)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("lrem", key, "-2", "a"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, 4)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("lrem", key, "0", "a"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "c"})
+}
+
+func TestLSet(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	values := []string{key, "a", "b", "c", "d", "e", "f"}
+	testDB.Exec(nil, utils.ToCmdLine2("rpush", values...))
+
+	// test positive index
+	size := len(values) - 1
+	for i := 0; i < size; i++ {
+		indexStr := strconv.Itoa(i)
+		value := utils.RandString(10)
+		result := testDB.Exec(nil, utils.ToCmdLine("lset", key, indexStr, value))
+		asserts.AssertStatusReply(t, result, "OK")
+		result = testDB.Exec(nil, utils.ToCmdLine("lindex", key, indexStr))
+		asserts.AssertBulkReply(t, result, value)
+	}
+	// test negative index
+	for i := 1; i <= size; i++ {
+		value := utils.RandString(10)
+		result := testDB.Exec(nil, utils.ToCmdLine("lset", key, strconv.Itoa(-i), value))
+		asserts.AssertStatusReply(t, result, "OK")
+		result = testDB.Exec(nil, utils.ToCmdLine("lindex", key, strconv.Itoa(size-i)))
+		asserts.AssertBulkReply(t, result, value)
+	}
+
+	// test illegal index
+	result := testDB.Exec(nil, utils.ToCmdLine("lset", key, strconv.Itoa(-size-1), "1"))
+	asserts.AssertErrReply(t, result, "ERR index out of range")
+	result = testDB.Exec(nil, utils.ToCmdLine("lset", key, strconv.Itoa(size), "1"))
+	asserts.AssertErrReply(t, result, "ERR index out of range")
+	result = testDB.Exec(nil, utils.ToCmdLine("lset", key, "a", "1"))
+	asserts.AssertErrReply(t, result, "ERR value is not an integer or out of range")
+}
+
+func TestLPop(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	values := []string{key, "a", "b", "c", "d", "e", "f"}
+	testDB.Exec(nil, utils.ToCmdLine2("rpush", values...))
+	size := len(values) - 1
+
+	for i := 0; i < size; i++ {
+		result := testDB.Exec(nil, utils.ToCmdLine("lpop", key))
+		asserts.AssertBulkReply(t, result, values[i+1])
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("lpop", key))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestRPop(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	values := []string{key, "a", "b", "c", "d", "e", "f"}
+	testDB.Exec(nil, utils.ToCmdLine2("rpush", values...))
+	size := len(values) - 1
+
+	for i := 0; i < size; i++ {
+		result := testDB.Exec(nil, utils.ToCmdLine("rpop", key))
+		asserts.AssertBulkReply(t, result, values[len(values)-i-1])
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("rpop", key))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestRPopLPush(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	values := []string{key1, "a", "b", "c", "d", "e", "f"}
+	testDB.Exec(nil, utils.ToCmdLine2("rpush", values...))
+	size := len(values) - 1
+
+	for i := 0; i < size; i++ {
+		result := testDB.Exec(nil, utils.ToCmdLine("rpoplpush", key1, key2))
+		asserts.AssertBulkReply(t, result, values[len(values)-i-1])
+		result = testDB.Exec(nil, utils.ToCmdLine("lindex", key2, "0"))
+		asserts.AssertBulkReply(t, result, values[len(values)-i-1])
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("rpop", key1))
+	asserts.AssertNullBulk(t, result)
+}
+
+func TestLInsert(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "a", "c"))
+	result := testDB.Exec(nil, utils.ToCmdLine("linsert", key, "before", "c", "b"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("linsert", key, "after", "c", "d"))
+	asserts.AssertIntReply(t, result, 4)
+	result = testDB.Exec(nil, utils.ToCmdLine("linsert", key, "after", "x", "y"))
+	asserts.AssertIntReply(t, result, -1)
+	result = testDB.Exec(nil, utils.ToCmdLine("linsert", key, "around", "c", "y"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "b", "c", "d"})
+}
+
+func TestLTrim(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "a", "b", "c", "d", "e"))
+	result := testDB.Exec(nil, utils.ToCmdLine("ltrim", key, "1", "-2"))
+	asserts.AssertStatusReply(t, result, "OK")
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "c", "d"})
+
+	result = testDB.Exec(nil, utils.ToCmdLine("ltrim", key, "5", "10"))
+	asserts.AssertStatusReply(t, result, "OK")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestUndoLPush(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	cmdLine := utils.ToCmdLine("lpush", key, value)
+	testDB.Exec(nil, cmdLine)
+	undoCmdLines := undoLPush(testDB, cmdLine[1:])
+	for _, cmdLine := range undoCmdLines {
+		testDB.applyUndoLog(cmdLine)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("llen", key))
+	asserts.AssertIntReply(t, result, 0)
+}
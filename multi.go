@@ -0,0 +1,244 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/logger"
+	"JZ_Redis/redis/reply"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync/atomic"
+)
+
+/*
+ * 事务的实现:
+ *   MULTI 之后的命令只入队, 不执行
+ *   EXEC 时通过每条命令的 PreFunc 得到所有相关的 key 并一次性加锁, 保证事务执行期间不被其它客户端打断
+ *   执行前检查 WATCH 的 key 版本号是否变化, 变化则放弃执行
+ *   执行期间写入 aof, keyspace 通知和唤醒阻塞客户端等副作用先暂存在 txEffects 中, EXEC 成功后才生效
+ *   执行过程中出错时按相反顺序应用 UndoFunc 生成的回滚日志直接恢复数据, 并丢弃暂存的副作用
+ */
+
+// StartMulti starts a transaction
+func StartMulti(c redis.Connection) redis.Reply {
+	if c.InMultiState() {
+		return reply.MakeErrReply("ERR MULTI calls can not be nested")
+	}
+	c.SetMultiState(true)
+	return &reply.OkReply{}
+}
+
+// EnqueueCmd puts command line into the queue of transaction, the command is checked before queueing
+func EnqueueCmd(c redis.Connection, cmdLine [][]byte) redis.Reply {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		errReply := reply.MakeErrReply("ERR unknown command '" + cmdName + "'")
+		c.AddTxError(errReply)
+		return errReply
+	}
+	if cmd.prepare == nil {
+		errReply := reply.MakeErrReply("ERR command '" + cmdName + "' cannot be used in MULTI")
+		c.AddTxError(errReply)
+		return errReply
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		errReply := reply.MakeArgNumErrReply(cmdName)
+		c.AddTxError(errReply)
+		return errReply
+	}
+	c.EnqueueCmd(cmdLine)
+	return reply.MakeQueuedReply()
+}
+
+// DiscardMulti drops queued commands and ends the transaction
+func DiscardMulti(c redis.Connection) redis.Reply {
+	if !c.InMultiState() {
+		return reply.MakeErrReply("ERR DISCARD without MULTI")
+	}
+	c.SetMultiState(false)
+	return &reply.OkReply{}
+}
+
+// Watch records current version of the given keys, the transaction will be aborted if any of them changed
+func Watch(db *DB, c redis.Connection, args [][]byte) redis.Reply {
+	if c.InMultiState() {
+		return reply.MakeErrReply("ERR WATCH inside MULTI is not allowed")
+	}
+	watching := c.GetWatching()
+	for _, bkey := range args {
+		key := string(bkey)
+		watching[key] = db.GetVersion(key)
+	}
+	return &reply.OkReply{}
+}
+
+// UnWatch forgets all watched keys
+func UnWatch(c redis.Connection) redis.Reply {
+	watching := c.GetWatching()
+	for key := range watching {
+		delete(watching, key)
+	}
+	return &reply.OkReply{}
+}
+
+func isWatchingChanged(db *DB, watching map[string]uint32) bool {
+	for key, ver := range watching {
+		if db.GetVersion(key) != ver {
+			return true
+		}
+	}
+	return false
+}
+
+// execMulti executes queued commands of the connection and ends the transaction
+func execMulti(db *DB, c redis.Connection) redis.Reply {
+	if !c.InMultiState() {
+		return reply.MakeErrReply("ERR EXEC without MULTI")
+	}
+	defer c.SetMultiState(false)
+	if len(c.GetTxErrors()) > 0 {
+		return reply.MakeErrReply("EXECABORT Transaction discarded because of previous errors.")
+	}
+	return ExecMulti(db, c.GetWatching(), c.GetQueuedCmdLine())
+}
+
+// ExecMulti executes the given command lines atomically
+// returns null multi bulk reply if any watched key changed, rollbacks all executed commands if any error occurs
+func ExecMulti(db *DB, watching map[string]uint32, cmdLines []CmdLine) redis.Reply {
+	// prepare
+	writeKeys := make([]string, 0) // may contains duplicate
+	readKeys := make([]string, 0)
+	for _, cmdLine := range cmdLines {
+		cmd := cmdTable[strings.ToLower(string(cmdLine[0]))]
+		write, read := cmd.prepare(cmdLine[1:])
+		writeKeys = append(writeKeys, write...)
+		readKeys = append(readKeys, read...)
+	}
+	// watched keys must not be changed by others during check
+	for key := range watching {
+		readKeys = append(readKeys, key)
+	}
+
+	locked := db.lockWithMoveDests(writeKeys, readKeys)
+	defer db.RWUnLocks(locked, readKeys)
+
+	if isWatchingChanged(db, watching) {
+		return reply.MakeNullMultiBulkReply()
+	}
+
+	effects := db.beginTxEffects(writeKeys)
+	// execute
+	results := make([]redis.Reply, 0, len(cmdLines))
+	undoCmdLines := make([][]CmdLine, 0, len(cmdLines))
+	var err error
+	for _, cmdLine := range cmdLines {
+		undoCmdLines = append(undoCmdLines, db.getUndoLogs(cmdLine))
+		result := db.execWithLock(cmdLine)
+		if reply.IsErrorReply(result) {
+			err = errors.New(strings.TrimSpace(string(result.ToBytes()[1:])))
+			break
+		}
+		results = append(results, result)
+	}
+	db.endTxEffects(writeKeys)
+	if err == nil {
+		db.applyTxEffects(effects)
+		db.addVersion(writeKeys...)
+		return reply.MakeMultiRawReply(results)
+	}
+
+	// undo if aborted, the last undo log belongs to the failed command
+	// side effects of the transaction are dropped, so undo logs restore data directly rather than executing commands
+	for i := len(undoCmdLines) - 1; i >= 0; i-- {
+		for _, cmdLine := range undoCmdLines[i] {
+			db.applyUndoLog(cmdLine)
+		}
+	}
+	return reply.MakeErrReply("EXECABORT Transaction rolled back: " + err.Error())
+}
+
+// txEffects holds side effects of a running transaction in order
+type txEffects struct {
+	aof       []*reply.MultiBulkReply
+	events    []keyspaceEvent
+	readyKeys []string
+}
+
+type keyspaceEvent struct {
+	class int
+	event string
+	key   string
+}
+
+// beginTxEffects holds side effects on the write keys until endTxEffects, the invoker should hold their locks
+func (db *DB) beginTxEffects(writeKeys []string) *txEffects {
+	effects := &txEffects{}
+	atomic.AddInt32(&db.txRunning, 1)
+	for _, key := range writeKeys {
+		db.txKeys.Put(key, effects)
+	}
+	return effects
+}
+
+func (db *DB) endTxEffects(writeKeys []string) {
+	for _, key := range writeKeys {
+		db.txKeys.Remove(key)
+	}
+	atomic.AddInt32(&db.txRunning, -1)
+}
+
+// getTxEffects returns side effects of the transaction writing key, or nil if key is not written by transaction
+func (db *DB) getTxEffects(key string) *txEffects {
+	if atomic.LoadInt32(&db.txRunning) == 0 {
+		return nil
+	}
+	raw, ok := db.txKeys.Get(key)
+	if !ok {
+		return nil
+	}
+	return raw.(*txEffects)
+}
+
+// applyTxEffects writes aof, publishes events and serves blocked clients after transaction succeeded,
+// the invoker should still hold locks of the transaction
+func (db *DB) applyTxEffects(effects *txEffects) {
+	for _, cmdLine := range effects.aof {
+		db.AddAof(cmdLine)
+	}
+	for _, e := range effects.events {
+		db.publishKeyspaceEvent(e.class, e.event, e.key)
+	}
+	signaled := make(map[string]bool)
+	for _, key := range effects.readyKeys {
+		if !signaled[key] {
+			signaled[key] = true
+			db.signalListReady(key)
+		}
+	}
+}
+
+// getUndoLogs returns undo command lines of the given command, it must be called before the command executed
+func (db *DB) getUndoLogs(cmdLine [][]byte) []CmdLine {
+	cmd, ok := cmdTable[strings.ToLower(string(cmdLine[0]))]
+	if !ok || cmd.undo == nil {
+		return nil
+	}
+	return cmd.undo(db, cmdLine[1:])
+}
+
+// execWithLock executes command assuming that the related keys have been locked by caller
+func (db *DB) execWithLock(cmdLine [][]byte) (result redis.Reply) {
+	defer func() {
+		if err := recover(); err != nil {
+			logger.Warn(fmt.Sprintf("error occurs: %v\n%s", err, string(debug.Stack())))
+			result = &reply.UnknownErrReply{}
+		}
+	}()
+	cmd, ok := cmdTable[strings.ToLower(string(cmdLine[0]))]
+	if !ok {
+		return reply.MakeErrReply("ERR unknown command '" + strings.ToLower(string(cmdLine[0])) + "'")
+	}
+	return cmd.executor(db, cmdLine[1:])
+}
@@ -0,0 +1,172 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"testing"
+	"time"
+)
+
+func TestMulti(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	result := testDB.Exec(conn, utils.ToCmdLine("multi"))
+	asserts.AssertStatusReply(t, result, "OK")
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	result = testDB.Exec(conn, utils.ToCmdLine("set", key, value))
+	asserts.AssertStatusReply(t, result, "QUEUED")
+	key2 := utils.RandString(10)
+	testDB.Exec(conn, utils.ToCmdLine("rpush", key2, value))
+	// queued commands are not executed before exec
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if reply.IsErrorReply(result) {
+		t.Errorf("exec failed: %s", result.ToBytes())
+		return
+	}
+	expected := reply.MakeMultiRawReply([]redis.Reply{&reply.OkReply{}, reply.MakeIntReply(1)})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Errorf("expected %s, actually %s", expected.ToBytes(), result.ToBytes())
+	}
+	result = testDB.Exec(conn, utils.ToCmdLine("get", key))
+	asserts.AssertBulkReply(t, result, value)
+	result = testDB.Exec(conn, utils.ToCmdLine("lrange", key2, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{value})
+	if conn.InMultiState() {
+		t.Error("expect not in multi state")
+	}
+}
+
+func TestMultiErrors(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertErrReply(t, result, "ERR EXEC without MULTI")
+	result = testDB.Exec(conn, utils.ToCmdLine("discard"))
+	asserts.AssertErrReply(t, result, "ERR DISCARD without MULTI")
+
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	result = testDB.Exec(conn, utils.ToCmdLine("multi"))
+	asserts.AssertErrReply(t, result, "ERR MULTI calls can not be nested")
+	result = testDB.Exec(conn, utils.ToCmdLine("watch", "a"))
+	asserts.AssertErrReply(t, result, "ERR WATCH inside MULTI is not allowed")
+	key := utils.RandString(10)
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	result = testDB.Exec(conn, utils.ToCmdLine("get"))
+	asserts.AssertErrReply(t, result, "ERR wrong number of arguments for 'get' command")
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertErrReply(t, result, "EXECABORT Transaction discarded because of previous errors.")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "1"))
+	result = testDB.Exec(conn, utils.ToCmdLine("discard"))
+	asserts.AssertStatusReply(t, result, "OK")
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestRollback(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	key := utils.RandString(10)
+	listKey := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	testDB.Exec(nil, utils.ToCmdLine("rpush", listKey, "a"))
+
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "2"))
+	testDB.Exec(conn, utils.ToCmdLine("rpush", listKey, "b"))
+	// runtime error
+	testDB.Exec(conn, utils.ToCmdLine("incr", listKey))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "3"))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertErrReply(t, result, "EXECABORT Transaction rolled back: WRONGTYPE Operation against a key holding the wrong kind of value")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key))
+	asserts.AssertBulkReply(t, result, "1")
+	result = testDB.Exec(nil, utils.ToCmdLine("lrange", listKey, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a"})
+}
+
+func TestWatch(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	key := utils.RandString(10)
+	value := utils.RandString(10)
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(nil, utils.ToCmdLine("set", key, value))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	key2 := utils.RandString(10)
+	testDB.Exec(conn, utils.ToCmdLine("set", key2, value))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertNotError(t, result)
+	if string(result.ToBytes()) != "*-1\r\n" {
+		t.Errorf("expected null multi bulk, actually %s", result.ToBytes())
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key2))
+	asserts.AssertNullBulk(t, result)
+
+	// watching is cleared after exec
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key2, value))
+	result = testDB.Exec(conn, utils.ToCmdLine("exec"))
+	asserts.AssertNotError(t, result)
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key2))
+	asserts.AssertBulkReply(t, result, value)
+
+	// unwatch
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(nil, utils.ToCmdLine("del", key))
+	result = testDB.Exec(conn, utils.ToCmdLine("unwatch"))
+	asserts.AssertStatusReply(t, result, "OK")
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, value))
+	testDB.Exec(conn, utils.ToCmdLine("exec"))
+	result = testDB.Exec(nil, utils.ToCmdLine("get", key))
+	asserts.AssertBulkReply(t, result, value)
+}
+
+func TestWatchExpiredKey(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1", "PX", "10"))
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	time.Sleep(20 * time.Millisecond)
+	// expired key is not counted even if it has not been removed
+	asserts.AssertIntReply(t, testDB.Exec(nil, utils.ToCmdLine("dbsize")), 0)
+	asserts.AssertNullBulk(t, testDB.Exec(nil, utils.ToCmdLine("get", key)))
+
+	// expiration changes the watched key
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "2"))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if string(result.ToBytes()) != "*-1\r\n" {
+		t.Errorf("expected null multi bulk, actually %s", result.ToBytes())
+	}
+}
+
+func TestWatchFlushDB(t *testing.T) {
+	testDB.Flush()
+	conn := connection.NewFakeConn()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	testDB.Exec(conn, utils.ToCmdLine("watch", key))
+	testDB.Exec(nil, utils.ToCmdLine("flushdb"))
+	testDB.Exec(conn, utils.ToCmdLine("multi"))
+	testDB.Exec(conn, utils.ToCmdLine("set", key, "2"))
+	result := testDB.Exec(conn, utils.ToCmdLine("exec"))
+	if string(result.ToBytes()) != "*-1\r\n" {
+		t.Errorf("expected null multi bulk, actually %s", result.ToBytes())
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
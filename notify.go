@@ -0,0 +1,107 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/pubsub"
+	"errors"
+)
+
+/*
+ * Keyspace notifications
+ * 每次修改 key 时向两个频道发布消息:
+ *   __keyspace@0__:<key>    消息内容为事件名, 例如 del
+ *   __keyevent@0__:<event>  消息内容为 key
+ * notify-keyspace-events 配置决定发布哪些消息, 每个字符代表一类事件:
+ *   K  keyspace 频道        E  keyevent 频道
+ *   g  通用命令(del, expire, rename ...)
+ *   $  string   l  list   s  set   h  hash   z  sorted set
+ *   x  过期事件  e  淘汰事件
+ *   A  g$lshzxe 的别名
+ * K 和 E 至少需要一个, 否则不会发布任何消息
+ */
+
+const (
+	notifyKeyspace = 1 << iota // K
+	notifyKeyevent             // E
+	notifyGeneric              // g
+	notifyString               // $
+	notifyList                 // l
+	notifySet                  // s
+	notifyHash                 // h
+	notifyZSet                 // z
+	notifyExpired              // x
+	notifyEvicted              // e
+
+	notifyAll = notifyGeneric | notifyString | notifyList | notifySet | notifyHash | notifyZSet | notifyExpired | notifyEvicted
+)
+
+const (
+	keyspaceChannelPrefix = "__keyspace@0__:"
+	keyeventChannelPrefix = "__keyevent@0__:"
+)
+
+// parseKeyspaceEvents converts notify-keyspace-events config into flags
+func parseKeyspaceEvents(s string) (int, error) {
+	flags := 0
+	for _, c := range s {
+		switch c {
+		case 'K':
+			flags |= notifyKeyspace
+		case 'E':
+			flags |= notifyKeyevent
+		case 'g':
+			flags |= notifyGeneric
+		case '$':
+			flags |= notifyString
+		case 'l':
+			flags |= notifyList
+		case 's':
+			flags |= notifySet
+		case 'h':
+			flags |= notifyHash
+		case 'z':
+			flags |= notifyZSet
+		case 'x':
+			flags |= notifyExpired
+		case 'e':
+			flags |= notifyEvicted
+		case 'A':
+			flags |= notifyAll
+		default:
+			return 0, errors.New("invalid notify-keyspace-events flag: " + string(c))
+		}
+	}
+	return flags, nil
+}
+
+// notifyKeyspaceEvent publishes the event if its class is enabled
+// class is one of notifyGeneric, notifyString ... notifyEvicted
+func (db *DB) notifyKeyspaceEvent(class int, event string, key string) {
+	if effects := db.getTxEffects(key); effects != nil {
+		effects.events = append(effects.events, keyspaceEvent{class: class, event: event, key: key})
+		return
+	}
+	db.publishKeyspaceEvent(class, event, key)
+}
+
+// publishKeyspaceEvent publishes the event immediately, even if the key is written by a running transaction
+func (db *DB) publishKeyspaceEvent(class int, event string, key string) {
+	flags := db.keyspaceEvents
+	if flags&class == 0 || flags&(notifyKeyspace|notifyKeyevent) == 0 {
+		return
+	}
+	if flags&notifyKeyspace > 0 {
+		pubsub.Publish(db.hub, [][]byte{[]byte(keyspaceChannelPrefix + key), []byte(event)})
+	}
+	if flags&notifyKeyevent > 0 {
+		pubsub.Publish(db.hub, [][]byte{[]byte(keyeventChannelPrefix + event), []byte(key)})
+	}
+}
+
+// notifyStoreEvent publishes event of *STORE commands, empty result deletes the destination
+func (db *DB) notifyStoreEvent(class int, event string, dest string, size int) {
+	if size > 0 {
+		db.notifyKeyspaceEvent(class, event, dest)
+	} else {
+		db.notifyKeyspaceEvent(notifyGeneric, "del", dest)
+	}
+}
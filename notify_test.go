@@ -0,0 +1,166 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"testing"
+	"time"
+)
+
+func TestParseKeyspaceEvents(t *testing.T) {
+	flags, err := parseKeyspaceEvents("KEA")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if flags != notifyKeyspace|notifyKeyevent|notifyAll {
+		t.Errorf("unexpected flags %b", flags)
+	}
+	flags, err = parseKeyspaceEvents("")
+	if err != nil || flags != 0 {
+		t.Errorf("empty config should disable notifications")
+	}
+	_, err = parseKeyspaceEvents("Kq")
+	if err == nil {
+		t.Error("expect error of unknown flag")
+	}
+}
+
+func keyspaceMsg(channel string, message string) string {
+	return string(reply.MakeMultiBulkReply([][]byte{
+		[]byte("message"), []byte(channel), []byte(message),
+	}).ToBytes())
+}
+
+func TestKeyspaceNotify(t *testing.T) {
+	testDB.Flush()
+	backup := testDB.keyspaceEvents
+	defer func() {
+		testDB.keyspaceEvents = backup
+	}()
+	testDB.keyspaceEvents, _ = parseKeyspaceEvents("Kl$gx")
+
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("subscribe", keyspaceChannelPrefix+key))
+	defer testDB.Exec(conn, utils.ToCmdLine("unsubscribe"))
+	conn.Clean()
+
+	channel := keyspaceChannelPrefix + key
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1"))
+	testDB.Exec(nil, utils.ToCmdLine("incr", key))
+	testDB.Exec(nil, utils.ToCmdLine("del", key))
+	testDB.Exec(nil, utils.ToCmdLine("rpush", key, "a"))
+	testDB.Exec(nil, utils.ToCmdLine("lpop", key))
+	expected := keyspaceMsg(channel, "set") +
+		keyspaceMsg(channel, "incrby") +
+		keyspaceMsg(channel, "del") +
+		keyspaceMsg(channel, "rpush") +
+		keyspaceMsg(channel, "lpop") +
+		keyspaceMsg(channel, "del")
+	if actual := string(conn.Bytes()); actual != expected {
+		t.Errorf("expected %q, actually %q", expected, actual)
+	}
+	conn.Clean()
+
+	// read command and disabled classes don't publish
+	testDB.Exec(nil, utils.ToCmdLine("get", key))
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a"))
+	if len(conn.Bytes()) > 0 {
+		t.Errorf("unexpected notification %q", conn.Bytes())
+	}
+	testDB.Exec(nil, utils.ToCmdLine("del", key))
+	conn.Clean()
+
+	testDB.Exec(nil, utils.ToCmdLine("set", key, "1", "px", "10"))
+	time.Sleep(20 * time.Millisecond)
+	testDB.Exec(nil, utils.ToCmdLine("get", key))
+	expected = keyspaceMsg(channel, "set") +
+		keyspaceMsg(channel, "expire") +
+		keyspaceMsg(channel, "expired")
+	if actual := string(conn.Bytes()); actual != expected {
+		t.Errorf("expected %q, actually %q", expected, actual)
+	}
+}
+
+func TestKeyeventNotify(t *testing.T) {
+	testDB.Flush()
+	backup := testDB.keyspaceEvents
+	defer func() {
+		testDB.keyspaceEvents = backup
+	}()
+	testDB.keyspaceEvents, _ = parseKeyspaceEvents("Ez")
+
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("subscribe", keyeventChannelPrefix+"zadd"))
+	defer testDB.Exec(conn, utils.ToCmdLine("unsubscribe"))
+	conn.Clean()
+
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a"))
+	expected := keyspaceMsg(keyeventChannelPrefix+"zadd", key)
+	if actual := string(conn.Bytes()); actual != expected {
+		t.Errorf("expected %q, actually %q", expected, actual)
+	}
+}
+
+func TestSAddExistingNotify(t *testing.T) {
+	testDB.Flush()
+	backup := testDB.keyspaceEvents
+	defer func() {
+		testDB.keyspaceEvents = backup
+	}()
+	testDB.keyspaceEvents, _ = parseKeyspaceEvents("Ks")
+
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("subscribe", keyspaceChannelPrefix+key))
+	defer testDB.Exec(conn, utils.ToCmdLine("unsubscribe"))
+	conn.Clean()
+
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a"))
+	// adding existing members changes nothing
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a"))
+	expected := keyspaceMsg(keyspaceChannelPrefix+key, "sadd")
+	if actual := string(conn.Bytes()); actual != expected {
+		t.Errorf("expected %q, actually %q", expected, actual)
+	}
+}
+
+func TestMultiNotify(t *testing.T) {
+	testDB.Flush()
+	backup := testDB.keyspaceEvents
+	defer func() {
+		testDB.keyspaceEvents = backup
+	}()
+	testDB.keyspaceEvents, _ = parseKeyspaceEvents("Kl$g")
+
+	key := utils.RandString(10)
+	conn := connection.NewFakeConn()
+	testDB.Exec(conn, utils.ToCmdLine("subscribe", keyspaceChannelPrefix+key))
+	defer testDB.Exec(conn, utils.ToCmdLine("unsubscribe"))
+	conn.Clean()
+
+	// rolled back transaction publishes nothing
+	txConn := connection.NewFakeConn()
+	testDB.Exec(txConn, utils.ToCmdLine("multi"))
+	testDB.Exec(txConn, utils.ToCmdLine("rpush", key, "a"))
+	testDB.Exec(txConn, utils.ToCmdLine("incr", key))
+	testDB.Exec(txConn, utils.ToCmdLine("exec"))
+	if len(conn.Bytes()) > 0 {
+		t.Errorf("unexpected notification %q", conn.Bytes())
+	}
+
+	testDB.Exec(txConn, utils.ToCmdLine("multi"))
+	testDB.Exec(txConn, utils.ToCmdLine("rpush", key, "a"))
+	testDB.Exec(txConn, utils.ToCmdLine("lpop", key))
+	testDB.Exec(txConn, utils.ToCmdLine("exec"))
+	channel := keyspaceChannelPrefix + key
+	expected := keyspaceMsg(channel, "rpush") +
+		keyspaceMsg(channel, "lpop") +
+		keyspaceMsg(channel, "del")
+	if actual := string(conn.Bytes()); actual != expected {
+		t.Errorf("expected %q, actually %q", expected, actual)
+	}
+}
@@ -0,0 +1,20 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+)
+
+// Ping the server
+func Ping(db *DB, args [][]byte) redis.Reply {
+	if len(args) == 0 {
+		return &reply.PongReply{}
+	} else if len(args) == 1 {
+		return reply.MakeStatusReply(string(args[0]))
+	}
+	return reply.MakeArgNumErrReply("ping")
+}
+
+func init() {
+	RegisterCommand("ping", Ping, noPrepare, nil, -1)
+}
@@ -0,0 +1,136 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/cluster"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/wildcard"
+	"JZ_Redis/pubsub"
+	"JZ_Redis/redis/reply"
+	"strconv"
+	"strings"
+)
+
+// commands allowed while the connection is subscribing any channel
+var pubSubContextCmds = map[string]struct{}{
+	"subscribe":    {},
+	"unsubscribe":  {},
+	"psubscribe":   {},
+	"punsubscribe": {},
+	"ssubscribe":   {},
+	"sunsubscribe": {},
+	"ping":         {},
+	"quit":         {},
+	"reset":        {},
+}
+
+// checkPubSubContext rejects commands which are not allowed in subscribed state
+func checkPubSubContext(c redis.Connection, cmdName string) redis.Reply {
+	if c == nil || c.SubsCount() == 0 {
+		return nil
+	}
+	if _, ok := pubSubContextCmds[cmdName]; ok {
+		return nil
+	}
+	return reply.MakeErrReply("ERR Can't execute '" + cmdName +
+		"': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context")
+}
+
+// pingInSubscribedState replies *2 pong message like redis does in subscribed state
+func pingInSubscribedState(args [][]byte) redis.Reply {
+	if len(args) > 1 {
+		return reply.MakeArgNumErrReply("ping")
+	}
+	message := []byte{}
+	if len(args) == 1 {
+		message = args[0]
+	}
+	return reply.MakeMultiBulkReply([][]byte{[]byte("pong"), message})
+}
+
+// execPublish sends message to subscribers of the channel, returns number of receivers
+func execPublish(db *DB, args [][]byte) redis.Reply {
+	return pubsub.Publish(db.hub, args)
+}
+
+// execPubSub inspects the state of pub/sub
+// PUBSUB CHANNELS [pattern] | NUMSUB [channel [channel ...]] | NUMPAT
+func execPubSub(db *DB, args [][]byte) redis.Reply {
+	subCmd := strings.ToLower(string(args[0]))
+	switch subCmd {
+	case "channels":
+		if len(args) > 2 {
+			return reply.MakeArgNumErrReply("pubsub|channels")
+		}
+		var pattern *wildcard.Pattern
+		if len(args) == 2 {
+			pattern = wildcard.CompilePattern(string(args[1]))
+		}
+		channels := pubsub.Channels(db.hub, pattern)
+		result := make([][]byte, len(channels))
+		for i, channel := range channels {
+			result[i] = []byte(channel)
+		}
+		return reply.MakeMultiBulkReply(result)
+	case "numsub":
+		result := make([]redis.Reply, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			result = append(result,
+				reply.MakeBulkReply(channel),
+				reply.MakeIntReply(int64(pubsub.NumSub(db.hub, string(channel)))))
+		}
+		return reply.MakeMultiRawReply(result)
+	case "numpat":
+		if len(args) != 1 {
+			return reply.MakeArgNumErrReply("pubsub|numpat")
+		}
+		return reply.MakeIntReply(int64(pubsub.NumPat(db.hub)))
+	}
+	return reply.MakeErrReply("ERR unknown subcommand '" + string(args[0]) + "'. Try PUBSUB HELP.")
+}
+
+// checkShardChannels returns the slot of the given shard channels, they must be in the same slot
+func checkShardChannels(channels [][]byte) (int, redis.Reply) {
+	slot := cluster.HashSlot(string(channels[0]))
+	for _, channel := range channels[1:] {
+		if cluster.HashSlot(string(channel)) != slot {
+			return 0, reply.MakeErrReply("CROSSSLOT Keys in request don't hash to the same slot")
+		}
+	}
+	return slot, nil
+}
+
+// movedReply redirects client to the owner of the slot
+func movedReply(slot int, addr string) redis.Reply {
+	return reply.MakeErrReply("MOVED " + strconv.Itoa(slot) + " " + addr)
+}
+
+// execSSubscribe subscribes shard channels, only the node owning the slot of channels accepts it
+func execSSubscribe(db *DB, c redis.Connection, args [][]byte) redis.Reply {
+	slot, errReply := checkShardChannels(args)
+	if errReply != nil {
+		return errReply
+	}
+	if !db.router.IsLocal(slot) {
+		return movedReply(slot, db.router.OwnerOf(slot))
+	}
+	return pubsub.SSubscribe(db.hub, c, args)
+}
+
+// execSPublish sends message to subscribers of the shard channel,
+// the message is relayed to the owner node if the channel doesn't belong to current node
+func execSPublish(db *DB, args [][]byte) redis.Reply {
+	slot := cluster.HashSlot(string(args[0]))
+	if !db.router.IsLocal(slot) {
+		cmdLine := make([][]byte, 0, len(args)+1)
+		cmdLine = append(cmdLine, []byte("spublish"))
+		cmdLine = append(cmdLine, args...)
+		return db.router.Relay(db.router.OwnerOf(slot), cmdLine)
+	}
+	return pubsub.SPublish(db.hub, args)
+}
+
+func init() {
+	RegisterCommand("Publish", execPublish, noPrepare, nil, 3)
+	RegisterCommand("SPublish", execSPublish, noPrepare, nil, 3)
+	RegisterCommand("PubSub", execPubSub, noPrepare, nil, -2)
+}
@@ -11,6 +11,10 @@ type Hub struct {
 	subs dict.Dict
 	// lock channel
 	subsLocker *lock.Locks
+	// pattern subscriptions
+	patterns *patternIndex
+	// shard channel -> list(*Client), shard channels are independent of normal channels
+	shardSubs dict.Dict
 }
 
 // MakeHub creates new hub
@@ -18,5 +22,7 @@ func MakeHub() *Hub {
 	return &Hub{
 		subs: dict.MakeConcurrent(4),
 		subsLocker: lock.Make(16),
+		patterns: makePatternIndex(),
+		shardSubs: dict.MakeConcurrent(4),
 	}
 }
@@ -0,0 +1,116 @@
+package pubsub
+
+import (
+	"JZ_Redis/datastruct/list"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/wildcard"
+	"sync"
+)
+
+/*
+ * patternIndex stores pattern subscriptions grouped by the literal prefix of pattern
+ * 发布消息时只需检查前缀是 channel 前缀的那些 pattern, 而不必遍历所有 pattern
+ * 例如 channel "orders.created" 只会检查前缀为 "", "o", "or", ... "orders.created" 的 pattern
+ */
+
+type patternSubscribers struct {
+	pattern *wildcard.Pattern
+	// list(redis.Connection)
+	clients *list.LinkedList
+}
+
+type patternIndex struct {
+	mu sync.RWMutex
+	// literal prefix -> pattern -> subscribers
+	byPrefix map[string]map[string]*patternSubscribers
+	// pattern -> subscribers
+	all map[string]*patternSubscribers
+}
+
+func makePatternIndex() *patternIndex {
+	return &patternIndex{
+		byPrefix: make(map[string]map[string]*patternSubscribers),
+		all:      make(map[string]*patternSubscribers),
+	}
+}
+
+// add returns false if the client has subscribed the pattern
+func (index *patternIndex) add(pattern string, client redis.Connection) bool {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	subs, ok := index.all[pattern]
+	if !ok {
+		compiled := wildcard.CompilePattern(pattern)
+		subs = &patternSubscribers{
+			pattern: compiled,
+			clients: list.Make(),
+		}
+		index.all[pattern] = subs
+		prefix := compiled.Prefix()
+		group, ok := index.byPrefix[prefix]
+		if !ok {
+			group = make(map[string]*patternSubscribers)
+			index.byPrefix[prefix] = group
+		}
+		group[pattern] = subs
+	}
+	if subs.clients.Contains(client) {
+		return false
+	}
+	subs.clients.Add(client)
+	return true
+}
+
+// remove returns false if the client hasn't subscribed the pattern
+func (index *patternIndex) remove(pattern string, client redis.Connection) bool {
+	index.mu.Lock()
+	defer index.mu.Unlock()
+
+	subs, ok := index.all[pattern]
+	if !ok {
+		return false
+	}
+	removed := subs.clients.RemoveAllByVal(client)
+	if subs.clients.Len() == 0 {
+		// clean
+		delete(index.all, pattern)
+		prefix := subs.pattern.Prefix()
+		group := index.byPrefix[prefix]
+		delete(group, pattern)
+		if len(group) == 0 {
+			delete(index.byPrefix, prefix)
+		}
+	}
+	return removed > 0
+}
+
+// forEachMatch visits every client subscribing a pattern which matches the channel
+func (index *patternIndex) forEachMatch(channel string, consumer func(pattern string, client redis.Connection)) {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+
+	for i := 0; i <= len(channel); i++ {
+		group, ok := index.byPrefix[channel[:i]]
+		if !ok {
+			continue
+		}
+		for pattern, subs := range group {
+			if !subs.pattern.IsMatch(channel) {
+				continue
+			}
+			subs.clients.ForEach(func(_ int, c interface{}) bool {
+				client, _ := c.(redis.Connection)
+				consumer(pattern, client)
+				return true
+			})
+		}
+	}
+}
+
+// count returns the number of unique patterns subscribed by clients
+func (index *patternIndex) count() int {
+	index.mu.RLock()
+	defer index.mu.RUnlock()
+	return len(index.all)
+}
@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/connection"
+	"testing"
+)
+
+func TestPatternIndex(t *testing.T) {
+	index := makePatternIndex()
+	c1 := connection.NewFakeConn()
+	c2 := connection.NewFakeConn()
+	index.add("orders.*", c1)
+	index.add("*.created", c1)
+	index.add("orders.*", c2)
+	index.add("users.?", c2)
+	if index.add("orders.*", c1) {
+		t.Error("duplicate subscription should return false")
+	}
+	if n := index.count(); n != 3 {
+		t.Errorf("expected 3 patterns, actually %d", n)
+	}
+
+	matched := 0
+	index.forEachMatch("orders.created", func(pattern string, client redis.Connection) {
+		if pattern == "users.?" {
+			t.Errorf("unexpected pattern %s", pattern)
+		}
+		matched++
+	})
+	if matched != 3 {
+		t.Errorf("expected 3 matches, actually %d", matched)
+	}
+
+	index.remove("orders.*", c1)
+	index.remove("orders.*", c2)
+	if _, ok := index.byPrefix["orders."]; ok {
+		t.Error("empty prefix group should be removed")
+	}
+	if index.remove("orders.*", c1) {
+		t.Error("remove absent subscription should return false")
+	}
+	matched = 0
+	index.forEachMatch("orders.created", func(pattern string, client redis.Connection) {
+		matched++
+	})
+	if matched != 1 {
+		t.Errorf("expected 1 match, actually %d", matched)
+	}
+}
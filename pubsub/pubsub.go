@@ -0,0 +1,245 @@
+package pubsub
+
+import (
+	"JZ_Redis/datastruct/dict"
+	"JZ_Redis/datastruct/list"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/wildcard"
+	"JZ_Redis/redis/reply"
+	"strconv"
+)
+
+var (
+	_subscribe    = "subscribe"
+	_unsubscribe  = "unsubscribe"
+	_psubscribe   = "psubscribe"
+	_punsubscribe = "punsubscribe"
+	messageBytes  = []byte("message")
+	pmessageBytes = []byte("pmessage")
+	// reply of unsubscribe when the client is not subscribing any channel
+	unSubscribeNothing  = []byte("*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n")
+	pUnSubscribeNothing = []byte("*3\r\n$12\r\npunsubscribe\r\n$-1\r\n:0\r\n")
+)
+
+// makeMsg makes subscribe/unsubscribe confirmation: *3 type channel count
+func makeMsg(t string, channel string, code int64) []byte {
+	return []byte("*3\r\n$" + strconv.FormatInt(int64(len(t)), 10) + reply.CRLF + t + reply.CRLF +
+		"$" + strconv.FormatInt(int64(len(channel)), 10) + reply.CRLF + channel + reply.CRLF +
+		":" + strconv.FormatInt(code, 10) + reply.CRLF)
+}
+
+/*
+ * invoker should lock channel
+ * return: is new subscribed
+ */
+func subscribe0(hub *Hub, channel string, client redis.Connection) bool {
+	client.Subscribe(channel)
+	return addSubscriber(hub.subs, channel, client)
+}
+
+/*
+ * invoker should lock channel
+ * return: is actually un-subscribe
+ */
+func unsubscribe0(hub *Hub, channel string, client redis.Connection) bool {
+	client.UnSubscribe(channel)
+	return removeSubscriber(hub.subs, channel, client)
+}
+
+// addSubscriber adds client into subscribers list of the channel in subs
+func addSubscriber(subs dict.Dict, channel string, client redis.Connection) bool {
+	raw, ok := subs.Get(channel)
+	var subscribers *list.LinkedList
+	if ok {
+		subscribers, _ = raw.(*list.LinkedList)
+	} else {
+		subscribers = list.Make()
+		subs.Put(channel, subscribers)
+	}
+	if subscribers.Contains(client) {
+		return false
+	}
+	subscribers.Add(client)
+	return true
+}
+
+// removeSubscriber removes client from subscribers list of the channel in subs, empty list is removed
+func removeSubscriber(subs dict.Dict, channel string, client redis.Connection) bool {
+	raw, ok := subs.Get(channel)
+	if ok {
+		subscribers, _ := raw.(*list.LinkedList)
+		removed := subscribers.RemoveAllByVal(client)
+		if subscribers.Len() == 0 {
+			// clean
+			subs.Remove(channel)
+		}
+		return removed > 0
+	}
+	return false
+}
+
+// Subscribe puts the given connection into the given channel
+func Subscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	channels := make([]string, len(args))
+	for i, b := range args {
+		channels[i] = string(b)
+	}
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	for _, channel := range channels {
+		subscribe0(hub, channel, c)
+		_ = c.Write(makeMsg(_subscribe, channel, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+// UnsubscribeAll removes the given connection from all subscribing channels, patterns and shard channels, confirmation is not sent
+func UnsubscribeAll(hub *Hub, c redis.Connection) {
+	channels := c.GetChannels()
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	for _, channel := range channels {
+		unsubscribe0(hub, channel, c)
+	}
+	for _, pattern := range c.GetPatterns() {
+		c.PUnSubscribe(pattern)
+		hub.patterns.remove(pattern, c)
+	}
+	sUnsubscribeAll(hub, c)
+}
+
+// UnSubscribe removes the given connection from the given channels, or all channels if no channel given
+func UnSubscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	var channels []string
+	if len(args) > 0 {
+		channels = make([]string, len(args))
+		for i, b := range args {
+			channels[i] = string(b)
+		}
+	} else {
+		channels = c.GetChannels()
+	}
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	if len(channels) == 0 {
+		_ = c.Write(unSubscribeNothing)
+		return &reply.NoReply{}
+	}
+
+	for _, channel := range channels {
+		unsubscribe0(hub, channel, c)
+		_ = c.Write(makeMsg(_unsubscribe, channel, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+// Publish send msg to all subscribing client, returns the number of receivers
+func Publish(hub *Hub, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return reply.MakeArgNumErrReply("publish")
+	}
+	channel := string(args[0])
+	message := args[1]
+
+	hub.subsLocker.Lock(channel)
+	defer hub.subsLocker.UnLock(channel)
+
+	receivers := 0
+	raw, ok := hub.subs.Get(channel)
+	if ok {
+		subscribers, _ := raw.(*list.LinkedList)
+		subscribers.ForEach(func(i int, c interface{}) bool {
+			client, _ := c.(redis.Connection)
+			replyArgs := make([][]byte, 3)
+			replyArgs[0] = messageBytes
+			replyArgs[1] = []byte(channel)
+			replyArgs[2] = message
+			_ = client.Write(reply.MakeMultiBulkReply(replyArgs).ToBytes())
+			return true
+		})
+		receivers += subscribers.Len()
+	}
+
+	// a client subscribing several matched patterns receives the message several times
+	hub.patterns.forEachMatch(channel, func(pattern string, client redis.Connection) {
+		replyArgs := make([][]byte, 4)
+		replyArgs[0] = pmessageBytes
+		replyArgs[1] = []byte(pattern)
+		replyArgs[2] = []byte(channel)
+		replyArgs[3] = message
+		_ = client.Write(reply.MakeMultiBulkReply(replyArgs).ToBytes())
+		receivers++
+	})
+	return reply.MakeIntReply(int64(receivers))
+}
+
+// PSubscribe puts the given connection into subscribers of the given patterns
+func PSubscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	for _, b := range args {
+		pattern := string(b)
+		c.PSubscribe(pattern)
+		hub.patterns.add(pattern, c)
+		_ = c.Write(makeMsg(_psubscribe, pattern, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+// PUnSubscribe removes the given connection from the given patterns, or all patterns if no pattern given
+func PUnSubscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	var patterns []string
+	if len(args) > 0 {
+		patterns = make([]string, len(args))
+		for i, b := range args {
+			patterns[i] = string(b)
+		}
+	} else {
+		patterns = c.GetPatterns()
+	}
+	if len(patterns) == 0 {
+		_ = c.Write(pUnSubscribeNothing)
+		return &reply.NoReply{}
+	}
+
+	for _, pattern := range patterns {
+		c.PUnSubscribe(pattern)
+		hub.patterns.remove(pattern, c)
+		_ = c.Write(makeMsg(_punsubscribe, pattern, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+// Channels returns active channels which match the given pattern, nil pattern matches all channels
+func Channels(hub *Hub, pattern *wildcard.Pattern) []string {
+	channels := make([]string, 0)
+	hub.subs.ForEach(func(channel string, val interface{}) bool {
+		if pattern == nil || pattern.IsMatch(channel) {
+			channels = append(channels, channel)
+		}
+		return true
+	})
+	return channels
+}
+
+// NumSub returns the number of subscribers of the given channel, pattern subscribers are not counted
+func NumSub(hub *Hub, channel string) int {
+	hub.subsLocker.Lock(channel)
+	defer hub.subsLocker.UnLock(channel)
+
+	raw, ok := hub.subs.Get(channel)
+	if !ok {
+		return 0
+	}
+	subscribers, _ := raw.(*list.LinkedList)
+	return subscribers.Len()
+}
+
+// NumPat returns the number of unique patterns subscribed by all clients
+func NumPat(hub *Hub) int {
+	return hub.patterns.count()
+}
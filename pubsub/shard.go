@@ -0,0 +1,106 @@
+package pubsub
+
+import (
+	"JZ_Redis/datastruct/list"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+)
+
+/*
+ * sharded pub/sub: SSUBSCRIBE, SUNSUBSCRIBE and SPUBLISH
+ * shard channel 只在拥有其 slot 的节点上订阅和发布, 路由由调用者负责, Hub 只处理本地的订阅关系
+ */
+
+var (
+	_ssubscribe   = "ssubscribe"
+	_sunsubscribe = "sunsubscribe"
+	smessageBytes = []byte("smessage")
+	// reply of sunsubscribe when the client is not subscribing any shard channel
+	sUnSubscribeNothing = []byte("*3\r\n$12\r\nsunsubscribe\r\n$-1\r\n:0\r\n")
+)
+
+// SSubscribe puts the given connection into the given shard channels
+func SSubscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	channels := make([]string, len(args))
+	for i, b := range args {
+		channels[i] = string(b)
+	}
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	for _, channel := range channels {
+		c.SSubscribe(channel)
+		addSubscriber(hub.shardSubs, channel, c)
+		_ = c.Write(makeMsg(_ssubscribe, channel, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+// SUnSubscribe removes the given connection from the given shard channels, or all shard channels if no channel given
+func SUnSubscribe(hub *Hub, c redis.Connection, args [][]byte) redis.Reply {
+	var channels []string
+	if len(args) > 0 {
+		channels = make([]string, len(args))
+		for i, b := range args {
+			channels[i] = string(b)
+		}
+	} else {
+		channels = c.GetShardChannels()
+	}
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	if len(channels) == 0 {
+		_ = c.Write(sUnSubscribeNothing)
+		return &reply.NoReply{}
+	}
+
+	for _, channel := range channels {
+		c.SUnSubscribe(channel)
+		removeSubscriber(hub.shardSubs, channel, c)
+		_ = c.Write(makeMsg(_sunsubscribe, channel, int64(c.SubsCount())))
+	}
+	return &reply.NoReply{}
+}
+
+func sUnsubscribeAll(hub *Hub, c redis.Connection) {
+	channels := c.GetShardChannels()
+
+	hub.subsLocker.Locks(channels...)
+	defer hub.subsLocker.UnLocks(channels...)
+
+	for _, channel := range channels {
+		c.SUnSubscribe(channel)
+		removeSubscriber(hub.shardSubs, channel, c)
+	}
+}
+
+// SPublish sends msg to local subscribers of the shard channel, returns the number of receivers
+func SPublish(hub *Hub, args [][]byte) redis.Reply {
+	if len(args) != 2 {
+		return reply.MakeArgNumErrReply("spublish")
+	}
+	channel := string(args[0])
+	message := args[1]
+
+	hub.subsLocker.Lock(channel)
+	defer hub.subsLocker.UnLock(channel)
+
+	raw, ok := hub.shardSubs.Get(channel)
+	if !ok {
+		return reply.MakeIntReply(0)
+	}
+	subscribers, _ := raw.(*list.LinkedList)
+	subscribers.ForEach(func(i int, c interface{}) bool {
+		client, _ := c.(redis.Connection)
+		replyArgs := make([][]byte, 3)
+		replyArgs[0] = smessageBytes
+		replyArgs[1] = []byte(channel)
+		replyArgs[2] = message
+		_ = client.Write(reply.MakeMultiBulkReply(replyArgs).ToBytes())
+		return true
+	})
+	return reply.MakeIntReply(int64(subscribers.Len()))
+}
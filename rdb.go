@@ -0,0 +1,356 @@
+package JZ_Redis
+
+import (
+	Dict "JZ_Redis/datastruct/dict"
+	List "JZ_Redis/datastruct/list"
+	HashSet "JZ_Redis/datastruct/set"
+	SortedSet "JZ_Redis/datastruct/sortedset"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/logger"
+	"JZ_Redis/rdb"
+	"JZ_Redis/redis/reply"
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+/*
+ * RDB snapshot
+ * SAVE 和 BGSAVE 都在持有所有 key 的读锁时把整个数据库编码到内存中, 之后再写入临时文件并 rename 为 dbfilename,
+ * 因此写命令只在编码期间被阻塞, 与 aof 重写相同.
+ * BGSAVE 在后台协程中执行, 与 SAVE 互斥.
+ * dirty 记录上次保存以来的修改次数, 配合 save 规则自动触发 BGSAVE
+ */
+
+var errBgSaveInProgress = errors.New("ERR Background save already in progress")
+
+// retry a failed automatic bgsave after the delay
+const bgSaveRetryDelay = 5 * time.Second
+
+// saveParam triggers bgsave if there are at least changes in seconds
+type saveParam struct {
+	seconds int
+	changes int64
+}
+
+// parseSaveParams parses save config like "900 1 300 10"
+func parseSaveParams(s string) ([]saveParam, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || s == `""` {
+		return nil, nil
+	}
+	fields := strings.Fields(s)
+	if len(fields)%2 != 0 {
+		return nil, errors.New("invalid save config: " + s)
+	}
+	params := make([]saveParam, 0, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		seconds, err := strconv.Atoi(fields[i])
+		if err != nil || seconds <= 0 {
+			return nil, errors.New("invalid save config: " + s)
+		}
+		changes, err := strconv.ParseInt(fields[i+1], 10, 64)
+		if err != nil || changes < 0 {
+			return nil, errors.New("invalid save config: " + s)
+		}
+		params = append(params, saveParam{seconds: seconds, changes: changes})
+	}
+	return params, nil
+}
+
+// dumpRdb encodes a snapshot of db, returns the number of changes included in it
+func (db *DB) dumpRdb() ([]byte, int64, error) {
+	// block writers during encoding, so the snapshot is consistent
+	db.locker.RLockAll()
+	defer db.locker.RUnLockAll()
+	dirty := atomic.LoadInt64(&db.dirty)
+
+	buf := &bytes.Buffer{}
+	if err := db.writeRdbSnapshot(buf); err != nil {
+		return nil, 0, err
+	}
+	return buf.Bytes(), dirty, nil
+}
+
+// writeRdbSnapshot encodes all keys in rdb format, the invoker should block writers
+func (db *DB) writeRdbSnapshot(writer io.Writer) error {
+	encoder := rdb.NewEncoder(writer)
+	aux := map[string]string{
+		"redis-bits": strconv.Itoa(strconv.IntSize),
+		"ctime":      strconv.FormatInt(time.Now().Unix(), 10),
+	}
+	if err := encoder.WriteHeader(aux); err != nil {
+		return err
+	}
+	if err := encoder.WriteDBHeader(0, db.data.Len(), db.ttlMap.Len()); err != nil {
+		return err
+	}
+	now := time.Now()
+	var err error
+	db.data.ForEach(func(key string, raw interface{}) bool {
+		var expireAt int64
+		if rawExpireTime, ok := db.ttlMap.Get(key); ok {
+			expireTime, _ := rawExpireTime.(time.Time)
+			if expireTime.Before(now) {
+				return true
+			}
+			expireAt = expireTime.UnixNano() / 1e6
+		}
+		entity, _ := raw.(*DataEntity)
+		err = writeRdbObject(encoder, key, entity, expireAt)
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return encoder.WriteEnd()
+}
+
+func writeRdbObject(encoder *rdb.Encoder, key string, entity *DataEntity, expireAt int64) error {
+	if entity == nil {
+		return nil
+	}
+	switch val := entity.Data.(type) {
+	case []byte:
+		return encoder.WriteStringObject(key, val, expireAt)
+	case *List.LinkedList:
+		values := make([][]byte, 0, val.Len())
+		val.ForEach(func(i int, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			values = append(values, bytes)
+			return true
+		})
+		return encoder.WriteListObject(key, values, expireAt)
+	case *HashSet.Set:
+		members := make([][]byte, 0, val.Len())
+		val.ForEach(func(member string) bool {
+			members = append(members, []byte(member))
+			return true
+		})
+		return encoder.WriteSetObject(key, members, expireAt)
+	case Dict.Dict:
+		hash := make(map[string][]byte, val.Len())
+		val.ForEach(func(field string, v interface{}) bool {
+			bytes, _ := v.([]byte)
+			hash[field] = bytes
+			return true
+		})
+		return encoder.WriteHashObject(key, hash, expireAt)
+	case *SortedSet.SortedSet:
+		entries := make([]*rdb.ZSetEntry, 0, val.Len())
+		val.ForEach(0, val.Len(), false, func(element *SortedSet.Element) bool {
+			entries = append(entries, &rdb.ZSetEntry{Member: element.Member, Score: element.Score})
+			return true
+		})
+		return encoder.WriteZSetObject(key, entries, expireAt)
+	}
+	return nil
+}
+
+// rdbSave0 writes snapshot into a temp file and renames it to dbfilename
+func (db *DB) rdbSave0() error {
+	data, dirty, err := db.dumpRdb()
+	if err != nil {
+		logger.Warn(err)
+		return err
+	}
+
+	dir, base := filepath.Split(db.rdbFilename)
+	if dir == "" {
+		dir = "."
+	}
+	file, err := ioutil.TempFile(dir, base+".tmp-*")
+	if err != nil {
+		logger.Warn(err)
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(file.Name(), db.rdbFilename)
+	}
+	if err != nil {
+		logger.Warn(err)
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := syncDir(dir); err != nil {
+		logger.Warn(err)
+	}
+	// changes made after snapshot are still dirty
+	atomic.AddInt64(&db.dirty, -dirty)
+	return nil
+}
+
+// rdbSave saves snapshot and blocks until finished
+func (db *DB) rdbSave() error {
+	if !db.rdbSaving.begin() {
+		return errBgSaveInProgress
+	}
+	err := db.rdbSave0()
+	db.rdbSaving.end(err)
+	return err
+}
+
+// bgSave saves snapshot in background, returns error if there is another save in progress
+func (db *DB) bgSave() error {
+	if !db.rdbSaving.begin() {
+		return errBgSaveInProgress
+	}
+	go func() {
+		err := db.rdbSave0()
+		db.rdbSaving.end(err)
+	}()
+	return nil
+}
+
+// execSave saves snapshot synchronously
+func execSave(db *DB, args [][]byte) redis.Reply {
+	if err := db.rdbSave(); err != nil {
+		if err == errBgSaveInProgress {
+			return reply.MakeErrReply(err.Error())
+		}
+		return reply.MakeErrReply("ERR " + err.Error())
+	}
+	return &reply.OkReply{}
+}
+
+// execBgSave saves snapshot in background
+func execBgSave(db *DB, args [][]byte) redis.Reply {
+	if err := db.bgSave(); err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	return reply.MakeStatusReply("Background saving started")
+}
+
+// execLastSave returns unix time of the last successful save
+func execLastSave(db *DB, args [][]byte) redis.Reply {
+	return reply.MakeIntReply(db.rdbSaving.status().lastSuccess.Unix())
+}
+
+// saveCron checks save rules every second until db is closed
+func (db *DB) saveCron() {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			db.checkSaveParams()
+		case <-db.closing:
+			return
+		}
+	}
+}
+
+// checkSaveParams starts bgsave if any save rule is satisfied
+func (db *DB) checkSaveParams() {
+	status := db.rdbSaving.status()
+	if status.inProgress {
+		return
+	}
+	// 上次保存失败时等待一段时间再重试, 避免不断重试
+	if status.lastStatus != "ok" && time.Since(status.lastStart) < bgSaveRetryDelay {
+		return
+	}
+	dirty := atomic.LoadInt64(&db.dirty)
+	for _, param := range db.saveParams {
+		if dirty >= param.changes && time.Since(status.lastSuccess) >= time.Duration(param.seconds)*time.Second {
+			if db.bgSave() == nil {
+				logger.Info(fmt.Sprintf("%d changes in %d seconds. Saving...", param.changes, param.seconds))
+			}
+			return
+		}
+	}
+}
+
+// loadRdb loads snapshot from dbfilename, it does nothing if the file doesn't exist
+func (db *DB) loadRdb() error {
+	file, err := os.Open(db.rdbFilename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer file.Close()
+
+	if err := db.loadRdbObjects(rdb.NewDecoder(bufio.NewReader(file))); err != nil {
+		return fmt.Errorf("bad rdb file %s: %v", db.rdbFilename, err)
+	}
+	return nil
+}
+
+// loadRdbObjects puts keys decoded from rdb into db, keys expired while server was down are skipped
+func (db *DB) loadRdbObjects(decoder *rdb.Decoder) error {
+	now := time.Now()
+	return decoder.Parse(func(object *rdb.Object) bool {
+		var expireTime time.Time
+		if object.ExpireAt > 0 {
+			expireTime = time.Unix(0, object.ExpireAt*1e6)
+			if expireTime.Before(now) {
+				return true
+			}
+		}
+		db.PutEntity(object.Key, rdbObjectToEntity(object))
+		if object.ExpireAt > 0 {
+			db.Expire(object.Key, expireTime)
+		}
+		return true
+	})
+}
+
+func rdbObjectToEntity(object *rdb.Object) *DataEntity {
+	var data interface{}
+	switch val := object.Value.(type) {
+	case []byte:
+		data = val
+	case [][]byte:
+		if object.Type == rdb.TypeList {
+			list := &List.LinkedList{}
+			for _, v := range val {
+				list.Add(v)
+			}
+			data = list
+		} else {
+			set := HashSet.Make()
+			for _, member := range val {
+				set.Add(string(member))
+			}
+			data = set
+		}
+	case map[string][]byte:
+		hash := Dict.MakeSimple()
+		for field, v := range val {
+			hash.Put(field, v)
+		}
+		data = hash
+	case []*rdb.ZSetEntry:
+		sortedSet := SortedSet.Make()
+		for _, entry := range val {
+			sortedSet.Add(entry.Member, entry.Score)
+		}
+		data = sortedSet
+	}
+	return &DataEntity{Data: data}
+}
+
+func init() {
+	// prepare is nil so that SAVE is rejected in MULTI, it is executed by execSpecialCmd
+	RegisterCommand("Save", execSave, nil, nil, 1)
+	RegisterCommand("BgSave", execBgSave, noPrepare, nil, 1)
+	RegisterCommand("LastSave", execLastSave, noPrepare, nil, 1)
+}
@@ -0,0 +1,36 @@
+package rdb
+
+/*
+ * crc-64-jones used by redis for rdb checksum
+ * poly: 0xad93d23594c935a9, reflected in and out, init 0, xor out 0
+ * hash/crc64 标准库总是对初始值和结果取反, 与 redis 不兼容, 所以需要自己实现
+ */
+
+// reversed representation of 0xad93d23594c935a9
+const jonesPoly = 0x95ac9329ac4bc9b5
+
+var crcTable = makeCRCTable()
+
+func makeCRCTable() *[256]uint64 {
+	table := new([256]uint64)
+	for i := 0; i < 256; i++ {
+		crc := uint64(i)
+		for j := 0; j < 8; j++ {
+			if crc&1 == 1 {
+				crc = (crc >> 1) ^ jonesPoly
+			} else {
+				crc >>= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}
+
+// CRC64 updates crc with p
+func CRC64(crc uint64, p []byte) uint64 {
+	for _, b := range p {
+		crc = crcTable[byte(crc)^b] ^ (crc >> 8)
+	}
+	return crc
+}
@@ -0,0 +1,380 @@
+package rdb
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+)
+
+// Object is a key read from rdb file
+type Object struct {
+	// one of TypeString, TypeList, TypeSet, TypeHash and TypeZSet2
+	Type byte
+	Key  string
+	// unix milliseconds, 0 means no expiration
+	ExpireAt int64
+	// []byte for string, [][]byte for list and set, map[string][]byte for hash, []*ZSetEntry for zset
+	Value interface{}
+}
+
+// ErrChecksum means the checksum in rdb file mismatches its content
+var ErrChecksum = errors.New("rdb checksum mismatch")
+
+const (
+	// maxStringLen limits length of a string, same as default proto-max-bulk-len of redis
+	maxStringLen = 512 * 1024 * 1024
+	// lengths read from file are not trusted, memory is allocated as data arrives
+	// so that a corrupted length fails at end of file rather than allocating too much
+	readChunkSize = 64 * 1024
+	maxPrealloc   = 1024
+)
+
+// Decoder reads rdb file
+type Decoder struct {
+	reader *bufio.Reader
+	crc    uint64
+	buf    []byte
+	// bytes consumed
+	offset int64
+}
+
+// NewDecoder creates Decoder, the given bufio.Reader is used directly so that data following rdb can be read from it
+func NewDecoder(reader io.Reader) *Decoder {
+	bufReader, ok := reader.(*bufio.Reader)
+	if !ok {
+		bufReader = bufio.NewReader(reader)
+	}
+	return &Decoder{
+		reader: bufReader,
+		buf:    make([]byte, 8),
+	}
+}
+
+// Offset returns the number of bytes consumed, it is the size of rdb after Parse succeeded
+func (dec *Decoder) Offset() int64 {
+	return dec.offset
+}
+
+func (dec *Decoder) readFull(p []byte) error {
+	n, err := io.ReadFull(dec.reader, p)
+	dec.offset += int64(n)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+	dec.crc = CRC64(dec.crc, p)
+	return nil
+}
+
+func (dec *Decoder) readByte() (byte, error) {
+	if err := dec.readFull(dec.buf[:1]); err != nil {
+		return 0, err
+	}
+	return dec.buf[0], nil
+}
+
+// readLength returns length, or encoding type of special string if isEncoded is true
+func (dec *Decoder) readLength() (length uint64, isEncoded bool, err error) {
+	first, err := dec.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+	switch first & 0xC0 {
+	case len6Bit:
+		return uint64(first & 0x3F), false, nil
+	case len14Bit:
+		next, err := dec.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(first&0x3F)<<8 | uint64(next), false, nil
+	case lenEncVal:
+		return uint64(first & 0x3F), true, nil
+	}
+	switch first {
+	case len32Bit:
+		if err := dec.readFull(dec.buf[:4]); err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(dec.buf)), false, nil
+	case len64Bit:
+		if err := dec.readFull(dec.buf[:8]); err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(dec.buf), false, nil
+	}
+	return 0, false, fmt.Errorf("unknown length encoding 0x%02x", first)
+}
+
+func (dec *Decoder) readPlainLength() (uint64, error) {
+	length, isEncoded, err := dec.readLength()
+	if err != nil {
+		return 0, err
+	}
+	if isEncoded {
+		return 0, errors.New("expect length, got encoded string")
+	}
+	return length, nil
+}
+
+func (dec *Decoder) readString() ([]byte, error) {
+	length, isEncoded, err := dec.readLength()
+	if err != nil {
+		return nil, err
+	}
+	if !isEncoded {
+		return dec.readBytes(length)
+	}
+	switch length {
+	case encInt8:
+		if err := dec.readFull(dec.buf[:1]); err != nil {
+			return nil, err
+		}
+		return formatInt(int64(int8(dec.buf[0]))), nil
+	case encInt16:
+		if err := dec.readFull(dec.buf[:2]); err != nil {
+			return nil, err
+		}
+		return formatInt(int64(int16(binary.LittleEndian.Uint16(dec.buf)))), nil
+	case encInt32:
+		if err := dec.readFull(dec.buf[:4]); err != nil {
+			return nil, err
+		}
+		return formatInt(int64(int32(binary.LittleEndian.Uint32(dec.buf)))), nil
+	case encLZF:
+		return nil, errors.New("lzf compressed string is not supported")
+	}
+	return nil, fmt.Errorf("unknown string encoding %d", length)
+}
+
+// readBytes reads string of the given length chunk by chunk
+func (dec *Decoder) readBytes(length uint64) ([]byte, error) {
+	if length > maxStringLen {
+		return nil, fmt.Errorf("string length %d exceeds limit", length)
+	}
+	s := make([]byte, 0, minUint64(length, readChunkSize))
+	for uint64(len(s)) < length {
+		n := int(minUint64(length-uint64(len(s)), readChunkSize))
+		s = append(s, make([]byte, n)...)
+		if err := dec.readFull(s[len(s)-n:]); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+func minUint64(a, b uint64) uint64 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (dec *Decoder) readStrings() ([][]byte, error) {
+	size, err := dec.readPlainLength()
+	if err != nil {
+		return nil, err
+	}
+	values := make([][]byte, 0, minUint64(size, maxPrealloc))
+	for i := uint64(0); i < size; i++ {
+		value, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+func (dec *Decoder) readHash() (map[string][]byte, error) {
+	size, err := dec.readPlainLength()
+	if err != nil {
+		return nil, err
+	}
+	hash := make(map[string][]byte, minUint64(size, maxPrealloc))
+	for i := uint64(0); i < size; i++ {
+		field, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		value, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		hash[string(field)] = value
+	}
+	return hash, nil
+}
+
+// readZSet reads zset, scores are binary double in zset2 and string in zset
+func (dec *Decoder) readZSet(valueType byte) ([]*ZSetEntry, error) {
+	size, err := dec.readPlainLength()
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]*ZSetEntry, 0, minUint64(size, maxPrealloc))
+	for i := uint64(0); i < size; i++ {
+		member, err := dec.readString()
+		if err != nil {
+			return nil, err
+		}
+		var score float64
+		if valueType == TypeZSet2 {
+			if err := dec.readFull(dec.buf[:8]); err != nil {
+				return nil, err
+			}
+			score = math.Float64frombits(binary.LittleEndian.Uint64(dec.buf))
+		} else {
+			score, err = dec.readZSetScore()
+			if err != nil {
+				return nil, err
+			}
+		}
+		entries = append(entries, &ZSetEntry{Member: string(member), Score: score})
+	}
+	return entries, nil
+}
+
+// readZSetScore reads score of rdb zset type: 253 nan, 254 +inf, 255 -inf, otherwise length of its string
+func (dec *Decoder) readZSetScore() (float64, error) {
+	length, err := dec.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch length {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	s := make([]byte, length)
+	if err := dec.readFull(s); err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(s), 64)
+}
+
+const typeZSet = 3
+
+func (dec *Decoder) readValue(valueType byte) (interface{}, error) {
+	switch valueType {
+	case TypeString:
+		return dec.readString()
+	case TypeList, TypeSet:
+		return dec.readStrings()
+	case TypeHash:
+		return dec.readHash()
+	case typeZSet, TypeZSet2:
+		return dec.readZSet(valueType)
+	}
+	return nil, fmt.Errorf("unsupported value type %d", valueType)
+}
+
+// Parse reads the whole rdb file and calls cb for every key, it stops if cb returns false.
+// Checksum is verified unless it is 0, which means checksum is disabled by the writer
+func (dec *Decoder) Parse(cb func(object *Object) bool) error {
+	header := make([]byte, len(Magic)+len(version))
+	if err := dec.readFull(header); err != nil {
+		return err
+	}
+	if string(header[:len(Magic)]) != Magic {
+		return errors.New("not a rdb file")
+	}
+	ver, err := strconv.Atoi(string(header[len(Magic):]))
+	if err != nil || ver < 1 || ver > 9 {
+		return fmt.Errorf("unsupported rdb version %q", header[len(Magic):])
+	}
+
+	var expireAt int64
+	for {
+		op, err := dec.readByte()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case opEOF:
+			return dec.verifyChecksum(ver)
+		case opAux:
+			// aux fields such as redis-ver are ignored
+			if _, err := dec.readString(); err != nil {
+				return err
+			}
+			if _, err := dec.readString(); err != nil {
+				return err
+			}
+		case opSelectDB:
+			if _, err := dec.readPlainLength(); err != nil {
+				return err
+			}
+		case opResizeDB:
+			if _, err := dec.readPlainLength(); err != nil {
+				return err
+			}
+			if _, err := dec.readPlainLength(); err != nil {
+				return err
+			}
+		case opExpireTimeMs:
+			if err := dec.readFull(dec.buf[:8]); err != nil {
+				return err
+			}
+			expireAt = int64(binary.LittleEndian.Uint64(dec.buf))
+		case opExpireTime:
+			if err := dec.readFull(dec.buf[:4]); err != nil {
+				return err
+			}
+			expireAt = int64(binary.LittleEndian.Uint32(dec.buf)) * 1000
+		default:
+			key, err := dec.readString()
+			if err != nil {
+				return err
+			}
+			value, err := dec.readValue(op)
+			if err != nil {
+				return fmt.Errorf("read key %s: %v", key, err)
+			}
+			valueType := op
+			if valueType == typeZSet {
+				valueType = TypeZSet2
+			}
+			object := &Object{
+				Type:     valueType,
+				Key:      string(key),
+				ExpireAt: expireAt,
+				Value:    value,
+			}
+			expireAt = 0
+			if !cb(object) {
+				return nil
+			}
+		}
+	}
+}
+
+func (dec *Decoder) verifyChecksum(ver int) error {
+	// checksum was introduced in rdb version 5
+	if ver < 5 {
+		return nil
+	}
+	expected := dec.crc
+	n, err := io.ReadFull(dec.reader, dec.buf[:8])
+	dec.offset += int64(n)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	checksum := binary.LittleEndian.Uint64(dec.buf)
+	if checksum != 0 && checksum != expected {
+		return ErrChecksum
+	}
+	return nil
+}
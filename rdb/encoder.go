@@ -0,0 +1,259 @@
+package rdb
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+	"strconv"
+)
+
+/*
+ * RDB file layout, compatible with redis rdb version 9:
+ *   "REDIS0009"
+ *   [0xFA aux-key aux-value]...
+ *   0xFE db-index 0xFB db-size expires-size
+ *   [0xFC expire-ms(8 bytes little endian)] value-type key value
+ *   ...
+ *   0xFF crc64(8 bytes little endian)
+ * 只实现了 string, list, set, hash, zset 的基本编码, 不使用 ziplist/listpack 等紧凑编码和 LZF 压缩
+ */
+
+// Magic is the prefix of rdb file, it is also used to detect rdb preamble of aof
+const Magic = "REDIS"
+
+const version = "0009"
+
+// value types
+const (
+	TypeString = 0
+	TypeList   = 1
+	TypeSet    = 2
+	TypeHash   = 4
+	TypeZSet2  = 5
+)
+
+const (
+	opAux          = 0xFA
+	opResizeDB     = 0xFB
+	opExpireTimeMs = 0xFC
+	opExpireTime   = 0xFD
+	opSelectDB     = 0xFE
+	opEOF          = 0xFF
+)
+
+// length encoding
+const (
+	len6Bit   = 0x00
+	len14Bit  = 0x40
+	len32Bit  = 0x80
+	len64Bit  = 0x81
+	lenEncVal = 0xC0
+)
+
+// special string encodings
+const (
+	encInt8  = 0
+	encInt16 = 1
+	encInt32 = 2
+	encLZF   = 3
+)
+
+// ZSetEntry is a member of sorted set
+type ZSetEntry struct {
+	Member string
+	Score  float64
+}
+
+// Encoder writes rdb file
+type Encoder struct {
+	writer io.Writer
+	crc    uint64
+	buf    []byte
+}
+
+// NewEncoder creates Encoder
+func NewEncoder(writer io.Writer) *Encoder {
+	return &Encoder{
+		writer: writer,
+		buf:    make([]byte, 9),
+	}
+}
+
+func (enc *Encoder) write(p []byte) error {
+	enc.crc = CRC64(enc.crc, p)
+	_, err := enc.writer.Write(p)
+	return err
+}
+
+func (enc *Encoder) writeByte(b byte) error {
+	enc.buf[0] = b
+	return enc.write(enc.buf[:1])
+}
+
+func (enc *Encoder) writeLength(length uint64) error {
+	buf := enc.buf
+	switch {
+	case length < 1<<6:
+		buf[0] = byte(length) | len6Bit
+		return enc.write(buf[:1])
+	case length < 1<<14:
+		buf[0] = byte(length>>8) | len14Bit
+		buf[1] = byte(length)
+		return enc.write(buf[:2])
+	case length <= math.MaxUint32:
+		buf[0] = len32Bit
+		binary.BigEndian.PutUint32(buf[1:], uint32(length))
+		return enc.write(buf[:5])
+	default:
+		buf[0] = len64Bit
+		binary.BigEndian.PutUint64(buf[1:], length)
+		return enc.write(buf[:9])
+	}
+}
+
+func (enc *Encoder) writeString(s []byte) error {
+	if err := enc.writeLength(uint64(len(s))); err != nil {
+		return err
+	}
+	return enc.write(s)
+}
+
+// WriteHeader writes magic, version and aux fields
+func (enc *Encoder) WriteHeader(aux map[string]string) error {
+	if err := enc.write([]byte(Magic + version)); err != nil {
+		return err
+	}
+	for key, value := range aux {
+		if err := enc.writeByte(opAux); err != nil {
+			return err
+		}
+		if err := enc.writeString([]byte(key)); err != nil {
+			return err
+		}
+		if err := enc.writeString([]byte(value)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteDBHeader selects db and writes size hints
+func (enc *Encoder) WriteDBHeader(index int, keyCount int, ttlCount int) error {
+	if err := enc.writeByte(opSelectDB); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(index)); err != nil {
+		return err
+	}
+	if err := enc.writeByte(opResizeDB); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(keyCount)); err != nil {
+		return err
+	}
+	return enc.writeLength(uint64(ttlCount))
+}
+
+// writeObjectHeader writes expire time, type and key, expireAt is unix milliseconds and 0 means no expiration
+func (enc *Encoder) writeObjectHeader(valueType byte, key string, expireAt int64) error {
+	if expireAt > 0 {
+		if err := enc.writeByte(opExpireTimeMs); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(enc.buf, uint64(expireAt))
+		if err := enc.write(enc.buf[:8]); err != nil {
+			return err
+		}
+	}
+	if err := enc.writeByte(valueType); err != nil {
+		return err
+	}
+	return enc.writeString([]byte(key))
+}
+
+// WriteStringObject writes string key
+func (enc *Encoder) WriteStringObject(key string, value []byte, expireAt int64) error {
+	if err := enc.writeObjectHeader(TypeString, key, expireAt); err != nil {
+		return err
+	}
+	return enc.writeString(value)
+}
+
+// WriteListObject writes list key
+func (enc *Encoder) WriteListObject(key string, values [][]byte, expireAt int64) error {
+	return enc.writeStrings(TypeList, key, values, expireAt)
+}
+
+// WriteSetObject writes set key
+func (enc *Encoder) WriteSetObject(key string, members [][]byte, expireAt int64) error {
+	return enc.writeStrings(TypeSet, key, members, expireAt)
+}
+
+func (enc *Encoder) writeStrings(valueType byte, key string, values [][]byte, expireAt int64) error {
+	if err := enc.writeObjectHeader(valueType, key, expireAt); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(len(values))); err != nil {
+		return err
+	}
+	for _, value := range values {
+		if err := enc.writeString(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteHashObject writes hash key
+func (enc *Encoder) WriteHashObject(key string, hash map[string][]byte, expireAt int64) error {
+	if err := enc.writeObjectHeader(TypeHash, key, expireAt); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(len(hash))); err != nil {
+		return err
+	}
+	for field, value := range hash {
+		if err := enc.writeString([]byte(field)); err != nil {
+			return err
+		}
+		if err := enc.writeString(value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteZSetObject writes sorted set key, scores are stored as binary double
+func (enc *Encoder) WriteZSetObject(key string, entries []*ZSetEntry, expireAt int64) error {
+	if err := enc.writeObjectHeader(TypeZSet2, key, expireAt); err != nil {
+		return err
+	}
+	if err := enc.writeLength(uint64(len(entries))); err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := enc.writeString([]byte(entry.Member)); err != nil {
+			return err
+		}
+		binary.LittleEndian.PutUint64(enc.buf, math.Float64bits(entry.Score))
+		if err := enc.write(enc.buf[:8]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteEnd writes EOF and checksum
+func (enc *Encoder) WriteEnd() error {
+	if err := enc.writeByte(opEOF); err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint64(enc.buf, enc.crc)
+	_, err := enc.writer.Write(enc.buf[:8])
+	return err
+}
+
+// formatInt is used by decoder to restore integer encoded strings
+func formatInt(v int64) []byte {
+	return []byte(strconv.FormatInt(v, 10))
+}
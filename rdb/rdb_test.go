@@ -0,0 +1,136 @@
+package rdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestCRC64(t *testing.T) {
+	// check value of crc-64-jones, see crc64.c in redis
+	if crc := CRC64(0, []byte("123456789")); crc != 0xe9c6d914c4b8d9ca {
+		t.Errorf("expected 0xe9c6d914c4b8d9ca, actually 0x%x", crc)
+	}
+}
+
+func TestEncodeDecode(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 1<<14+1)
+	objects := []*Object{
+		{Type: TypeString, Key: "s", Value: []byte("hello")},
+		{Type: TypeString, Key: "long", Value: long, ExpireAt: 1700000000123},
+		{Type: TypeList, Key: "l", Value: [][]byte{[]byte("a"), []byte(""), []byte("c")}},
+		{Type: TypeSet, Key: "set", Value: [][]byte{[]byte("x")}},
+		{Type: TypeHash, Key: "h", Value: map[string][]byte{"f1": []byte("v1"), "f2": []byte("v2")}},
+		{Type: TypeZSet2, Key: "z", Value: []*ZSetEntry{{"a", 1.5}, {"b", math.Inf(-1)}}, ExpireAt: 1},
+	}
+	buf := &bytes.Buffer{}
+	enc := NewEncoder(buf)
+	if err := enc.WriteHeader(map[string]string{"redis-bits": "64"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := enc.WriteDBHeader(0, len(objects), 2); err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objects {
+		var err error
+		switch obj.Type {
+		case TypeString:
+			err = enc.WriteStringObject(obj.Key, obj.Value.([]byte), obj.ExpireAt)
+		case TypeList:
+			err = enc.WriteListObject(obj.Key, obj.Value.([][]byte), obj.ExpireAt)
+		case TypeSet:
+			err = enc.WriteSetObject(obj.Key, obj.Value.([][]byte), obj.ExpireAt)
+		case TypeHash:
+			err = enc.WriteHashObject(obj.Key, obj.Value.(map[string][]byte), obj.ExpireAt)
+		case TypeZSet2:
+			err = enc.WriteZSetObject(obj.Key, obj.Value.([]*ZSetEntry), obj.ExpireAt)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := enc.WriteEnd(); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+
+	var actual []*Object
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(object *Object) bool {
+		actual = append(actual, object)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(objects, actual) {
+		t.Errorf("decoded objects mismatch")
+	}
+
+	// flip a byte in the middle of file
+	data[len(data)/2] ^= 0xFF
+	err = NewDecoder(bytes.NewReader(data)).Parse(func(object *Object) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error for corrupted file")
+	}
+
+	// truncated file
+	err = NewDecoder(bytes.NewReader(data[:len(data)-4])).Parse(func(object *Object) bool {
+		return true
+	})
+	if err == nil {
+		t.Error("expect error for truncated file")
+	}
+}
+
+func TestDecodeIntString(t *testing.T) {
+	data := []byte("REDIS0009")
+	// int8, int16 and int32 encoded values
+	data = append(data, TypeString, 1, 'a', lenEncVal|encInt8, 0xFE)
+	data = append(data, TypeString, 1, 'b', lenEncVal|encInt16, 0x39, 0x30)
+	data = append(data, TypeString, 1, 'c', lenEncVal|encInt32, 0x00, 0x00, 0x00, 0x80)
+	data = append(data, opEOF)
+	checksum := make([]byte, 8)
+	binary.LittleEndian.PutUint64(checksum, CRC64(0, data))
+	data = append(data, checksum...)
+
+	expected := map[string]string{"a": "-2", "b": "12345", "c": "-2147483648"}
+	err := NewDecoder(bytes.NewReader(data)).Parse(func(object *Object) bool {
+		value := string(object.Value.([]byte))
+		if value != expected[object.Key] {
+			t.Errorf("key %s: expected %s, actually %s", object.Key, expected[object.Key], value)
+		}
+		delete(expected, object.Key)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expected) > 0 {
+		t.Errorf("keys not decoded: %v", expected)
+	}
+}
+
+func TestDecodeHugeLength(t *testing.T) {
+	header := []byte("REDIS0009")
+	corrupted := [][]byte{
+		// string, list, hash and zset claiming 2^63 bytes or elements
+		append(append([]byte{}, header...), TypeString, 1, 'a', len64Bit, 0x80, 0, 0, 0, 0, 0, 0, 0),
+		append(append([]byte{}, header...), TypeList, 1, 'a', len64Bit, 0x80, 0, 0, 0, 0, 0, 0, 0),
+		append(append([]byte{}, header...), TypeHash, 1, 'a', len64Bit, 0x80, 0, 0, 0, 0, 0, 0, 0),
+		append(append([]byte{}, header...), TypeZSet2, 1, 'a', len64Bit, 0x80, 0, 0, 0, 0, 0, 0, 0),
+		// string within limit but longer than the file
+		append(append([]byte{}, header...), TypeString, 1, 'a', len32Bit, 0x10, 0, 0, 0, 'x'),
+	}
+	for i, data := range corrupted {
+		err := NewDecoder(bytes.NewReader(data)).Parse(func(object *Object) bool {
+			return true
+		})
+		if err == nil {
+			t.Errorf("case %d: expect error for huge length", i)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/config"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitSave waits until no save is in progress
+func waitSave(t *testing.T, db *DB) {
+	for i := 0; i < 100; i++ {
+		if db.rdbSaving.currentDuration() < 0 {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatal("save timeout")
+}
+
+func TestRdbSaveAndLoad(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		DBFilename: path.Join(tmpDir, "dump.rdb"),
+	}
+	rdbDB := MakeDB()
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "str", "hello"))
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "int", "12345"))
+	rdbDB.Exec(nil, utils.ToCmdLine("rpush", "list", "a", "b", "c"))
+	rdbDB.Exec(nil, utils.ToCmdLine("sadd", "set", "a", "b"))
+	rdbDB.Exec(nil, utils.ToCmdLine("hset", "hash", "f1", "v1", "f2", "v2"))
+	rdbDB.Exec(nil, utils.ToCmdLine("zadd", "zset", "1.5", "a", "-inf", "b"))
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "ttl", "1"))
+	rdbDB.Exec(nil, utils.ToCmdLine("expire", "ttl", "1000"))
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "expired", "1"))
+	rdbDB.Exec(nil, utils.ToCmdLine("pexpire", "expired", "10"))
+	time.Sleep(20 * time.Millisecond)
+
+	before := time.Now().Unix()
+	asserts.AssertStatusReply(t, rdbDB.Exec(nil, utils.ToCmdLine("save")), "OK")
+	result, ok := rdbDB.Exec(nil, utils.ToCmdLine("lastsave")).(*reply.IntReply)
+	if !ok || result.Code < before {
+		t.Errorf("expect lastsave >= %d, actually %s", before, rdbDB.Exec(nil, utils.ToCmdLine("lastsave")).ToBytes())
+	}
+	info := string(rdbDB.Exec(nil, utils.ToCmdLine("info", "persistence")).ToBytes())
+	if !strings.Contains(info, "rdb_changes_since_last_save:0\r\n") {
+		t.Errorf("expect no changes after save, actually %s", info)
+	}
+	rdbDB.Close()
+
+	loadedDB := MakeDB()
+	defer loadedDB.Close()
+	asserts.AssertBulkReply(t, loadedDB.Exec(nil, utils.ToCmdLine("get", "str")), "hello")
+	asserts.AssertBulkReply(t, loadedDB.Exec(nil, utils.ToCmdLine("get", "int")), "12345")
+	asserts.AssertMultiBulkReply(t, loadedDB.Exec(nil, utils.ToCmdLine("lrange", "list", "0", "-1")), []string{"a", "b", "c"})
+	asserts.AssertIntReply(t, loadedDB.Exec(nil, utils.ToCmdLine("scard", "set")), 2)
+	asserts.AssertIntReply(t, loadedDB.Exec(nil, utils.ToCmdLine("sismember", "set", "b")), 1)
+	asserts.AssertBulkReply(t, loadedDB.Exec(nil, utils.ToCmdLine("hget", "hash", "f2")), "v2")
+	asserts.AssertMultiBulkReply(t, loadedDB.Exec(nil, utils.ToCmdLine("zrange", "zset", "0", "-1", "withscores")),
+		[]string{"b", "-inf", "a", "1.5"})
+	asserts.AssertNullBulk(t, loadedDB.Exec(nil, utils.ToCmdLine("get", "expired")))
+	ttl, ok := loadedDB.Exec(nil, utils.ToCmdLine("ttl", "ttl")).(*reply.IntReply)
+	if !ok || ttl.Code <= 990 || ttl.Code > 1000 {
+		t.Errorf("expect ttl about 1000, actually %s", loadedDB.Exec(nil, utils.ToCmdLine("ttl", "ttl")).ToBytes())
+	}
+	asserts.AssertIntReply(t, loadedDB.Exec(nil, utils.ToCmdLine("dbsize")), 7)
+
+	// no temp file left
+	files, err := ioutil.ReadDir(tmpDir)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(files) != 1 {
+		t.Errorf("expect only dump.rdb in dir, actually %d files", len(files))
+	}
+}
+
+func TestBgSave(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		DBFilename: path.Join(tmpDir, "dump.rdb"),
+	}
+	rdbDB := MakeDB()
+	defer rdbDB.Close()
+	for i := 0; i < 10; i++ {
+		rdbDB.Exec(nil, utils.ToCmdLine("incr", "a"))
+	}
+	info := string(rdbDB.Exec(nil, utils.ToCmdLine("info", "persistence")).ToBytes())
+	if !strings.Contains(info, "rdb_changes_since_last_save:10\r\n") {
+		t.Errorf("expect 10 changes, actually %s", info)
+	}
+
+	// another save is in progress
+	rdbDB.rdbSaving.begin()
+	asserts.AssertErrReply(t, rdbDB.Exec(nil, utils.ToCmdLine("bgsave")), "ERR Background save already in progress")
+	asserts.AssertErrReply(t, rdbDB.Exec(nil, utils.ToCmdLine("save")), "ERR Background save already in progress")
+	rdbDB.rdbSaving.end(nil)
+
+	asserts.AssertStatusReply(t, rdbDB.Exec(nil, utils.ToCmdLine("bgsave")), "Background saving started")
+	waitSave(t, rdbDB)
+	info = string(rdbDB.Exec(nil, utils.ToCmdLine("info", "persistence")).ToBytes())
+	for _, field := range []string{"rdb_changes_since_last_save:0", "rdb_bgsave_in_progress:0", "rdb_last_bgsave_status:ok"} {
+		if !strings.Contains(info, field+"\r\n") {
+			t.Errorf("expect %s, actually %s", field, info)
+		}
+	}
+	if _, err := os.Stat(config.Properties.DBFilename); err != nil {
+		t.Error(err)
+	}
+
+	// SAVE blocks writers, it is not allowed in transaction
+	conn := connection.NewFakeConn()
+	rdbDB.Exec(conn, utils.ToCmdLine("multi"))
+	asserts.AssertErrReply(t, rdbDB.Exec(conn, utils.ToCmdLine("save")), "ERR command 'save' cannot be used in MULTI")
+	rdbDB.Exec(conn, utils.ToCmdLine("discard"))
+}
+
+func TestParseSaveParams(t *testing.T) {
+	params, err := parseSaveParams("900 1 300 10")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expected := []saveParam{{seconds: 900, changes: 1}, {seconds: 300, changes: 10}}
+	if !reflect.DeepEqual(params, expected) {
+		t.Errorf("expected %v, actually %v", expected, params)
+	}
+	for _, s := range []string{"", `""`} {
+		if params, err := parseSaveParams(s); err != nil || len(params) != 0 {
+			t.Errorf("%q should disable save, actually %v %v", s, params, err)
+		}
+	}
+	for _, s := range []string{"900", "a 1", "900 -1", "0 1"} {
+		if _, err := parseSaveParams(s); err == nil {
+			t.Errorf("expect error for %q", s)
+		}
+	}
+}
+
+func TestSaveParams(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		DBFilename: path.Join(tmpDir, "dump.rdb"),
+		Save:       "1 2",
+	}
+	rdbDB := MakeDB()
+	defer rdbDB.Close()
+	// the rule needs 2 changes
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(config.Properties.DBFilename); !os.IsNotExist(err) {
+		t.Errorf("expect no dump, actually %v", err)
+	}
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "b", "2"))
+	for i := 0; i < 30; i++ {
+		if _, err := os.Stat(config.Properties.DBFilename); err == nil {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Error("expect automatic bgsave")
+}
+
+func TestLoadRdbWithAof(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "godis")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer func() {
+		_ = os.RemoveAll(tmpDir)
+	}()
+	config.Properties = &config.ServerProperties{
+		DBFilename: path.Join(tmpDir, "dump.rdb"),
+	}
+	rdbDB := MakeDB()
+	rdbDB.Exec(nil, utils.ToCmdLine("set", "a", "1"))
+	asserts.AssertStatusReply(t, rdbDB.Exec(nil, utils.ToCmdLine("save")), "OK")
+	rdbDB.Close()
+
+	// rdb is loaded if there is no aof, and the loaded data is written into aof
+	config.Properties.AppendOnly = true
+	config.Properties.AppendFilename = path.Join(tmpDir, "a.aof")
+	aofDB := MakeDB()
+	asserts.AssertBulkReply(t, aofDB.Exec(nil, utils.ToCmdLine("get", "a")), "1")
+	aofDB.Exec(nil, utils.ToCmdLine("set", "b", "2"))
+	aofDB.Close()
+
+	// loaded data has been persisted into aof
+	_ = os.Remove(config.Properties.DBFilename)
+	aofDB2 := MakeDB()
+	defer aofDB2.Close()
+	asserts.AssertBulkReply(t, aofDB2.Exec(nil, utils.ToCmdLine("get", "a")), "1")
+	asserts.AssertBulkReply(t, aofDB2.Exec(nil, utils.ToCmdLine("get", "b")), "2")
+}
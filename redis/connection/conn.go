@@ -1 +1,240 @@
 package connection
+
+import (
+	"JZ_Redis/lib/sync/wait"
+	"net"
+	"sync"
+	"time"
+)
+
+// Connection represents a connection with a redis-cli
+type Connection struct {
+	conn net.Conn
+
+	// waiting until reply finished
+	// 服务端正在发送回复时进入waiting, 阻止连接被关闭
+	waitingReply wait.Wait
+
+	// lock while handler sending response
+	mu sync.Mutex
+
+	// subscribing channels
+	subs map[string]bool
+	// subscribing patterns
+	psubs map[string]bool
+	// subscribing shard channels
+	ssubs map[string]bool
+
+	// password may be changed by CONFIG command during runtime, so store the password
+	password string
+
+	// queued commands for `multi`
+	multiState bool
+	queue      [][][]byte
+	// key -> version of the key when `watch` was called
+	watching map[string]uint32
+	// errors occurred while queueing commands, exec will be aborted if any
+	txErrors []error
+}
+
+// NewConn creates Connection instance
+func NewConn(conn net.Conn) *Connection {
+	return &Connection{
+		conn: conn,
+	}
+}
+
+// RemoteAddr returns the remote network address
+func (c *Connection) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// Close disconnect with the client
+func (c *Connection) Close() error {
+	c.waitingReply.WaitWithTimeout(10 * time.Second)
+	_ = c.conn.Close()
+	return nil
+}
+
+// Write sends response to client over tcp connection
+func (c *Connection) Write(b []byte) error {
+	if len(b) == 0 {
+		return nil
+	}
+	c.mu.Lock()
+	c.waitingReply.Add(1)
+	defer func() {
+		c.waitingReply.Done()
+		c.mu.Unlock()
+	}()
+
+	_, err := c.conn.Write(b)
+	return err
+}
+
+// Subscribe add current connection into subscribers of the given channel
+func (c *Connection) Subscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subs == nil {
+		c.subs = make(map[string]bool)
+	}
+	c.subs[channel] = true
+}
+
+// UnSubscribe removes current connection into subscribers of the given channel
+func (c *Connection) UnSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.subs) == 0 {
+		return
+	}
+	delete(c.subs, channel)
+}
+
+// SubsCount returns the number of subscribing channels, patterns and shard channels
+func (c *Connection) SubsCount() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.subs) + len(c.psubs) + len(c.ssubs)
+}
+
+// GetChannels returns all subscribing channels
+func (c *Connection) GetChannels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.subs == nil {
+		return make([]string, 0)
+	}
+	channels := make([]string, len(c.subs))
+	i := 0
+	for channel := range c.subs {
+		channels[i] = channel
+		i++
+	}
+	return channels
+}
+
+// PSubscribe add current connection into subscribers of the given pattern
+func (c *Connection) PSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.psubs == nil {
+		c.psubs = make(map[string]bool)
+	}
+	c.psubs[pattern] = true
+}
+
+// PUnSubscribe removes current connection from subscribers of the given pattern
+func (c *Connection) PUnSubscribe(pattern string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.psubs, pattern)
+}
+
+// GetPatterns returns all subscribing patterns
+func (c *Connection) GetPatterns() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	patterns := make([]string, 0, len(c.psubs))
+	for pattern := range c.psubs {
+		patterns = append(patterns, pattern)
+	}
+	return patterns
+}
+
+// SSubscribe add current connection into subscribers of the given shard channel
+func (c *Connection) SSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.ssubs == nil {
+		c.ssubs = make(map[string]bool)
+	}
+	c.ssubs[channel] = true
+}
+
+// SUnSubscribe removes current connection from subscribers of the given shard channel
+func (c *Connection) SUnSubscribe(channel string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.ssubs, channel)
+}
+
+// GetShardChannels returns all subscribing shard channels
+func (c *Connection) GetShardChannels() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	channels := make([]string, 0, len(c.ssubs))
+	for channel := range c.ssubs {
+		channels = append(channels, channel)
+	}
+	return channels
+}
+
+// SetPassword stores password for authentication
+func (c *Connection) SetPassword(password string) {
+	c.password = password
+}
+
+// GetPassword get password for authentication
+func (c *Connection) GetPassword() string {
+	return c.password
+}
+
+// InMultiState tells is connection in an uncommitted transaction
+func (c *Connection) InMultiState() bool {
+	return c.multiState
+}
+
+// SetMultiState sets transaction flag
+func (c *Connection) SetMultiState(state bool) {
+	if !state { // reset data when cancel multi
+		c.watching = nil
+		c.queue = nil
+		c.txErrors = nil
+	}
+	c.multiState = state
+}
+
+// GetQueuedCmdLine returns queued commands of current transaction
+func (c *Connection) GetQueuedCmdLine() [][][]byte {
+	return c.queue
+}
+
+// EnqueueCmd enqueues command of current transaction
+func (c *Connection) EnqueueCmd(cmdLine [][]byte) {
+	c.queue = append(c.queue, cmdLine)
+}
+
+// ClearQueueCmds clears queued commands of current transaction
+func (c *Connection) ClearQueueCmds() {
+	c.queue = nil
+}
+
+// GetWatching returns watching keys and their version code when started watching
+func (c *Connection) GetWatching() map[string]uint32 {
+	if c.watching == nil {
+		c.watching = make(map[string]uint32)
+	}
+	return c.watching
+}
+
+// AddTxError stores an error occurred while queueing command of current transaction
+func (c *Connection) AddTxError(err error) {
+	c.txErrors = append(c.txErrors, err)
+}
+
+// GetTxErrors returns errors occurred while queueing commands of current transaction
+func (c *Connection) GetTxErrors() []error {
+	return c.txErrors
+}
@@ -0,0 +1,44 @@
+package connection
+
+import (
+	"bytes"
+)
+
+// FakeConn implements redis.Connection for test
+// 不持有真实的 tcp 连接, 写入的数据保存在内存中供测试检查
+type FakeConn struct {
+	Connection
+	buf bytes.Buffer
+}
+
+// NewFakeConn creates FakeConn instance
+func NewFakeConn() *FakeConn {
+	return &FakeConn{}
+}
+
+// Write writes data to buffer
+func (c *FakeConn) Write(b []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Write(b)
+	return nil
+}
+
+// Clean resets the buffer
+func (c *FakeConn) Clean() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.buf.Reset()
+}
+
+// Bytes returns written data
+func (c *FakeConn) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.buf.Bytes()
+}
+
+// Close does nothing, there is no underlying connection
+func (c *FakeConn) Close() error {
+	return nil
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+)
+
+/*
+ * CommandReader reads multi bulk commands one by one and tracks byte offset, it is used to load append only file
+ * 与 ParseStream 不同, CommandReader 是同步的, 并且能区分文件尾部不完整的命令和文件中间的格式错误
+ */
+
+// ErrTruncated means the last command is incomplete, usually the writer crashed while writing it
+var ErrTruncated = errors.New("unexpected end of file")
+
+const (
+	// maxMultiBulkLen limits number of arguments of a command
+	maxMultiBulkLen = 1024 * 1024
+	// maxBulkLen limits size of an argument, same as default proto-max-bulk-len of redis
+	maxBulkLen = 512 * 1024 * 1024
+	// memory is allocated as data arrives rather than trusting the length, a corrupted length won't allocate too much
+	initialBufSize = 64 * 1024
+)
+
+// CommandReader reads commands from reader
+type CommandReader struct {
+	reader *bufio.Reader
+	// bytes consumed by complete commands
+	offset int64
+	// bytes consumed by the command being read
+	pending int64
+}
+
+// NewCommandReader creates CommandReader, the given bufio.Reader is used directly
+// so that commands can be read after other data consumed from it, eg. rdb preamble of aof.
+// Offset is counted from where the reader is
+func NewCommandReader(reader io.Reader) *CommandReader {
+	bufReader, ok := reader.(*bufio.Reader)
+	if !ok {
+		bufReader = bufio.NewReader(reader)
+	}
+	return &CommandReader{
+		reader: bufReader,
+	}
+}
+
+// Offset returns the offset right after the last complete command, which is also where a broken command begins
+func (r *CommandReader) Offset() int64 {
+	return r.offset
+}
+
+// ReadCommand returns next command.
+// It returns io.EOF if there are no more commands, ErrTruncated if the last command is incomplete,
+// or protocol error if data is corrupted
+func (r *CommandReader) ReadCommand() ([][]byte, error) {
+	r.pending = 0
+	header, err := r.readLine()
+	if err != nil {
+		// io.EOF only if nothing is read
+		return nil, err
+	}
+	if header[0] != '*' {
+		return nil, errors.New("protocol error: expect multi bulk, got " + strconv.Quote(string(header)))
+	}
+	count, err := strconv.ParseInt(string(header[1:]), 10, 64)
+	if err != nil || count <= 0 || count > maxMultiBulkLen {
+		return nil, errors.New("protocol error: bad multi bulk length " + strconv.Quote(string(header)))
+	}
+
+	args := make([][]byte, 0, minInt64(count, 16))
+	for i := int64(0); i < count; i++ {
+		line, err := r.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if line[0] != '$' {
+			return nil, errors.New("protocol error: expect bulk string, got " + strconv.Quote(string(line)))
+		}
+		size, err := strconv.ParseInt(string(line[1:]), 10, 64)
+		if err != nil || size < 0 || size > maxBulkLen {
+			return nil, errors.New("protocol error: bad bulk length " + strconv.Quote(string(line)))
+		}
+		buf := bytes.NewBuffer(make([]byte, 0, minInt64(size+2, initialBufSize)))
+		n, err := io.CopyN(buf, r.reader, size+2)
+		r.pending += n
+		if err != nil {
+			return nil, r.ioError(err)
+		}
+		body := buf.Bytes()
+		if body[size] != '\r' || body[size+1] != '\n' {
+			return nil, errors.New("protocol error: bulk string is not terminated by CRLF")
+		}
+		args = append(args, body[:size])
+	}
+	r.offset += r.pending
+	r.pending = 0
+	return args, nil
+}
+
+// readLine reads a line without CRLF
+func (r *CommandReader) readLine() ([]byte, error) {
+	line, err := r.reader.ReadBytes('\n')
+	r.pending += int64(len(line))
+	if err != nil {
+		if err == io.EOF && r.pending == 0 {
+			return nil, io.EOF
+		}
+		return nil, r.ioError(err)
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errors.New("protocol error: " + strconv.Quote(string(line)))
+	}
+	return line[:len(line)-2], nil
+}
+
+func (r *CommandReader) ioError(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrTruncated
+	}
+	return err
+}
+
+func minInt64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
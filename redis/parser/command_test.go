@@ -0,0 +1,113 @@
+package parser
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply"
+	"bufio"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestReadCommand(t *testing.T) {
+	cmds := [][][]byte{
+		utils.ToCmdLine("set", "a", "a\r\nb"),
+		utils.ToCmdLine("del", ""),
+	}
+	buf := bytes.Buffer{}
+	for _, cmd := range cmds {
+		buf.Write(reply.MakeMultiBulkReply(cmd).ToBytes())
+	}
+	size := int64(buf.Len())
+	reader := NewCommandReader(bytes.NewReader(buf.Bytes()))
+	for _, expected := range cmds {
+		actual, err := reader.ReadCommand()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if len(actual) != len(expected) {
+			t.Errorf("expected %q, actually %q", expected, actual)
+			continue
+		}
+		for i := range expected {
+			if !utils.BytesEquals(expected[i], actual[i]) {
+				t.Errorf("expected %q, actually %q", expected, actual)
+			}
+		}
+	}
+	if _, err := reader.ReadCommand(); err != io.EOF {
+		t.Errorf("expect EOF, actually %v", err)
+	}
+	if reader.Offset() != size {
+		t.Errorf("expect offset %d, actually %d", size, reader.Offset())
+	}
+}
+
+func TestReadTruncatedCommand(t *testing.T) {
+	first := reply.MakeMultiBulkReply(utils.ToCmdLine("set", "a", "1")).ToBytes()
+	second := reply.MakeMultiBulkReply(utils.ToCmdLine("set", "b", "2")).ToBytes()
+	for i := 1; i < len(second); i++ {
+		data := append(append([]byte{}, first...), second[:i]...)
+		reader := NewCommandReader(bytes.NewReader(data))
+		if _, err := reader.ReadCommand(); err != nil {
+			t.Error(err)
+			return
+		}
+		if _, err := reader.ReadCommand(); err != ErrTruncated {
+			t.Errorf("expect truncated at %d, actually %v", i, err)
+		}
+		if reader.Offset() != int64(len(first)) {
+			t.Errorf("expect offset %d, actually %d", len(first), reader.Offset())
+		}
+	}
+}
+
+func TestReadCorruptedCommand(t *testing.T) {
+	first := reply.MakeMultiBulkReply(utils.ToCmdLine("set", "a", "1")).ToBytes()
+	corrupted := []string{
+		"set a 1\r\n",
+		"*x\r\n",
+		"*1\r\n+OK\r\n",
+		"*1\r\n$1\r\nabc\r\n",
+		// lengths out of range are rejected before allocating
+		"*1\r\n$9223372036854775807\r\n",
+		"*1\r\n$536870913\r\n",
+		"*9223372036854775807\r\n",
+		"*2147483647\r\n",
+	}
+	for _, c := range corrupted {
+		data := append(append([]byte{}, first...), c...)
+		data = append(data, first...)
+		reader := NewCommandReader(bytes.NewReader(data))
+		if _, err := reader.ReadCommand(); err != nil {
+			t.Error(err)
+			return
+		}
+		_, err := reader.ReadCommand()
+		if err == nil || err == ErrTruncated || err == io.EOF {
+			t.Errorf("expect protocol error of %q, actually %v", c, err)
+		}
+		if reader.Offset() != int64(len(first)) {
+			t.Errorf("expect offset %d, actually %d", len(first), reader.Offset())
+		}
+	}
+}
+
+func TestReadCommandAfterPrefix(t *testing.T) {
+	cmd := reply.MakeMultiBulkReply(utils.ToCmdLine("set", "a", "1")).ToBytes()
+	reader := bufio.NewReader(bytes.NewReader(append([]byte("prefix"), cmd...)))
+	if _, err := reader.Discard(len("prefix")); err != nil {
+		t.Error(err)
+		return
+	}
+	// the buffered reader is shared, data after prefix is not lost
+	cmdReader := NewCommandReader(reader)
+	actual, err := cmdReader.ReadCommand()
+	if err != nil || len(actual) != 3 || string(actual[2]) != "1" {
+		t.Errorf("expected set a 1, actually %q %v", actual, err)
+	}
+	if cmdReader.Offset() != int64(len(cmd)) {
+		t.Errorf("expected offset %d, actually %d", len(cmd), cmdReader.Offset())
+	}
+}
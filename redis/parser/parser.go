@@ -114,7 +114,7 @@ func parse0(reader io.Reader, ch chan<- *Payload) {
 			// receive new response
 			if msg[0] == '*' {
 				// multi bulk reply
-				err = parseBulkHeader(msg, &state)
+				err = parseMultiBulkHeader(msg, &state)
 				if err != nil {
 					ch <- &Payload{
 						Err: errors.New("protocol error: " + string(msg)),
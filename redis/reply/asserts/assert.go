@@ -2,9 +2,9 @@ package asserts
 
 import (
 	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/utils"
 	"JZ_Redis/redis/reply"
 	"fmt"
-	"godis/lib/utils"
 	"runtime"
 	"testing"
 )
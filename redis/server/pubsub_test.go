@@ -1 +1,271 @@
 package server
+
+import (
+	"JZ_Redis/cluster"
+	"JZ_Redis/config"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/tcp"
+	"bufio"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// dialTestServer starts a server on random port and returns a function to connect it
+func dialTestServer(t *testing.T) (func() (net.Conn, *bufio.Reader), func()) {
+	closeChan := make(chan struct{})
+	listener, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := listener.Addr().String()
+	go tcp.ListenAndServe(listener, MakeHandler(), closeChan)
+	dial := func() (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn, bufio.NewReader(conn)
+	}
+	return dial, func() {
+		closeChan <- struct{}{}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+func sendCmd(t *testing.T, conn net.Conn, args ...string) {
+	cmdLine := make([][]byte, len(args))
+	for i, arg := range args {
+		cmdLine[i] = []byte(arg)
+	}
+	if _, err := conn.Write(reply.MakeMultiBulkReply(cmdLine).ToBytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// expectReply reads len(expected) bytes and compares them
+func expectReply(t *testing.T, reader *bufio.Reader, expected string) {
+	buf := make([]byte, len(expected))
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		t.Fatal(err)
+	}
+	if string(buf) != expected {
+		t.Errorf("expected %q, actually %q", expected, string(buf))
+	}
+}
+
+func TestPublish(t *testing.T) {
+	dial, shutdown := dialTestServer(t)
+	defer shutdown()
+	subConn, subReader := dial()
+	defer subConn.Close()
+	pubConn, pubReader := dial()
+	defer pubConn.Close()
+
+	sendCmd(t, subConn, "subscribe", "a", "b")
+	expectReply(t, subReader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	expectReply(t, subReader, "*3\r\n$9\r\nsubscribe\r\n$1\r\nb\r\n:2\r\n")
+
+	sendCmd(t, pubConn, "publish", "a", "hello")
+	expectReply(t, pubReader, ":1\r\n")
+	expectReply(t, subReader, "*3\r\n$7\r\nmessage\r\n$1\r\na\r\n$5\r\nhello\r\n")
+	sendCmd(t, pubConn, "publish", "c", "hello")
+	expectReply(t, pubReader, ":0\r\n")
+
+	// only pub/sub commands are allowed in subscribed state
+	sendCmd(t, subConn, "get", "a")
+	expectReply(t, subReader, "-ERR Can't execute 'get': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE / PING / QUIT / RESET are allowed in this context\r\n")
+	sendCmd(t, subConn, "ping")
+	expectReply(t, subReader, "*2\r\n$4\r\npong\r\n$0\r\n\r\n")
+
+	sendCmd(t, subConn, "unsubscribe", "a")
+	expectReply(t, subReader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\na\r\n:1\r\n")
+	sendCmd(t, pubConn, "publish", "a", "hello")
+	expectReply(t, pubReader, ":0\r\n")
+	sendCmd(t, subConn, "unsubscribe")
+	expectReply(t, subReader, "*3\r\n$11\r\nunsubscribe\r\n$1\r\nb\r\n:0\r\n")
+	sendCmd(t, subConn, "unsubscribe")
+	expectReply(t, subReader, "*3\r\n$11\r\nunsubscribe\r\n$-1\r\n:0\r\n")
+
+	// back to normal state
+	sendCmd(t, subConn, "ping")
+	expectReply(t, subReader, "+PONG\r\n")
+}
+
+func TestUnsubscribeAfterClose(t *testing.T) {
+	dial, shutdown := dialTestServer(t)
+	defer shutdown()
+	subConn, subReader := dial()
+	pubConn, pubReader := dial()
+	defer pubConn.Close()
+
+	sendCmd(t, subConn, "subscribe", "a")
+	expectReply(t, subReader, "*3\r\n$9\r\nsubscribe\r\n$1\r\na\r\n:1\r\n")
+	_ = subConn.Close()
+
+	for i := 0; i < 50; i++ {
+		sendCmd(t, pubConn, "publish", "a", "hello")
+		line, err := pubReader.ReadString('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line == ":0\r\n" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("closed client should be removed from subscribers")
+}
+
+func TestPSubscribe(t *testing.T) {
+	dial, shutdown := dialTestServer(t)
+	defer shutdown()
+	subConn, subReader := dial()
+	defer subConn.Close()
+	pubConn, pubReader := dial()
+	defer pubConn.Close()
+
+	sendCmd(t, subConn, "psubscribe", "orders.*")
+	expectReply(t, subReader, "*3\r\n$10\r\npsubscribe\r\n$8\r\norders.*\r\n:1\r\n")
+	sendCmd(t, subConn, "subscribe", "orders.created")
+	expectReply(t, subReader, "*3\r\n$9\r\nsubscribe\r\n$14\r\norders.created\r\n:2\r\n")
+
+	sendCmd(t, pubConn, "publish", "orders.created", "1")
+	expectReply(t, pubReader, ":2\r\n")
+	expectReply(t, subReader, "*3\r\n$7\r\nmessage\r\n$14\r\norders.created\r\n$1\r\n1\r\n")
+	expectReply(t, subReader, "*4\r\n$8\r\npmessage\r\n$8\r\norders.*\r\n$14\r\norders.created\r\n$1\r\n1\r\n")
+	sendCmd(t, pubConn, "publish", "users.created", "1")
+	expectReply(t, pubReader, ":0\r\n")
+
+	sendCmd(t, pubConn, "pubsub", "numpat")
+	expectReply(t, pubReader, ":1\r\n")
+	sendCmd(t, pubConn, "pubsub", "channels", "orders.*")
+	expectReply(t, pubReader, "*1\r\n$14\r\norders.created\r\n")
+	sendCmd(t, pubConn, "pubsub", "numsub", "orders.created", "x")
+	expectReply(t, pubReader, "*4\r\n$14\r\norders.created\r\n:1\r\n$1\r\nx\r\n:0\r\n")
+	sendCmd(t, pubConn, "pubsub", "foo")
+	expectReply(t, pubReader, "-ERR unknown subcommand 'foo'. Try PUBSUB HELP.\r\n")
+
+	sendCmd(t, subConn, "punsubscribe")
+	expectReply(t, subReader, "*3\r\n$12\r\npunsubscribe\r\n$8\r\norders.*\r\n:1\r\n")
+	sendCmd(t, pubConn, "pubsub", "numpat")
+	expectReply(t, pubReader, ":0\r\n")
+	sendCmd(t, subConn, "punsubscribe")
+	expectReply(t, subReader, "*3\r\n$12\r\npunsubscribe\r\n$-1\r\n:0\r\n")
+}
+
+// startCluster starts two nodes of cluster, the returned function shuts them down
+func startCluster(t *testing.T, password string) ([]string, func()) {
+	listeners := make([]net.Listener, 2)
+	addrs := make([]string, 2)
+	for i := range listeners {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		listeners[i] = listener
+		addrs[i] = listener.Addr().String()
+	}
+	// handler reads cluster config when created
+	backup := *config.Properties
+	closeChans := make([]chan struct{}, 2)
+	for i, listener := range listeners {
+		config.Properties.Self = addrs[i]
+		config.Properties.Peers = []string{addrs[1-i]}
+		config.Properties.RequirePass = password
+		closeChans[i] = make(chan struct{})
+		go tcp.ListenAndServe(listener, MakeHandler(), closeChans[i])
+	}
+	*config.Properties = backup
+	// requirepass is also read by every command
+	config.Properties.RequirePass = password
+	return addrs, func() {
+		config.Properties.RequirePass = backup.RequirePass
+		for _, ch := range closeChans {
+			ch <- struct{}{}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
+
+// localChannel returns a channel owned by the first node
+func localChannel(addrs []string) string {
+	router := cluster.MakeRouter(addrs[0], []string{addrs[1]}, "")
+	for i := 0; ; i++ {
+		channel := "ch" + strconv.Itoa(i)
+		if router.IsLocal(cluster.HashSlot(channel)) {
+			return channel
+		}
+	}
+}
+
+func TestShardedPubSub(t *testing.T) {
+	addrs, stop := startCluster(t, "")
+	defer stop()
+
+	channel := localChannel(addrs)
+	slot := strconv.Itoa(cluster.HashSlot(channel))
+
+	conns := make([]net.Conn, 2)
+	readers := make([]*bufio.Reader, 2)
+	for i, addr := range addrs {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		readers[i] = bufio.NewReader(conn)
+	}
+
+	// node 1 doesn't own the channel
+	sendCmd(t, conns[1], "ssubscribe", channel)
+	expectReply(t, readers[1], "-MOVED "+slot+" "+addrs[0]+"\r\n")
+	sendCmd(t, conns[0], "ssubscribe", channel)
+	expectReply(t, readers[0], "*3\r\n$10\r\nssubscribe\r\n$"+strconv.Itoa(len(channel))+"\r\n"+channel+"\r\n:1\r\n")
+
+	// spublish on node 1 is relayed to node 0
+	sendCmd(t, conns[1], "spublish", channel, "hello")
+	expectReply(t, readers[1], ":1\r\n")
+	expectReply(t, readers[0], "*3\r\n$8\r\nsmessage\r\n$"+strconv.Itoa(len(channel))+"\r\n"+channel+"\r\n$5\r\nhello\r\n")
+	// shard channels are independent of normal channels
+	sendCmd(t, conns[1], "publish", channel, "hello")
+	expectReply(t, readers[1], ":0\r\n")
+
+	sendCmd(t, conns[0], "sunsubscribe")
+	expectReply(t, readers[0], "*3\r\n$12\r\nsunsubscribe\r\n$"+strconv.Itoa(len(channel))+"\r\n"+channel+"\r\n:0\r\n")
+	sendCmd(t, conns[1], "spublish", channel, "hello")
+	expectReply(t, readers[1], ":0\r\n")
+
+	sendCmd(t, conns[0], "ssubscribe", "{a}1", "{b}1")
+	expectReply(t, readers[0], "-CROSSSLOT Keys in request don't hash to the same slot\r\n")
+}
+
+func TestShardedPubSubAuth(t *testing.T) {
+	addrs, stop := startCluster(t, "pass")
+	defer stop()
+	channel := localChannel(addrs)
+
+	conns := make([]net.Conn, 2)
+	readers := make([]*bufio.Reader, 2)
+	for i, addr := range addrs {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+		readers[i] = bufio.NewReader(conn)
+		sendCmd(t, conn, "auth", "pass")
+		expectReply(t, readers[i], "+OK\r\n")
+	}
+	sendCmd(t, conns[0], "ssubscribe", channel)
+	expectReply(t, readers[0], "*3\r\n$10\r\nssubscribe\r\n$"+strconv.Itoa(len(channel))+"\r\n"+channel+"\r\n:1\r\n")
+
+	// relayed connection authenticates itself
+	sendCmd(t, conns[1], "spublish", channel, "hello")
+	expectReply(t, readers[1], ":1\r\n")
+	expectReply(t, readers[0], "*3\r\n$8\r\nsmessage\r\n$"+strconv.Itoa(len(channel))+"\r\n"+channel+"\r\n$5\r\nhello\r\n")
+}
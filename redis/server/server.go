@@ -1 +1,142 @@
 package server
+
+/*
+ * A tcp.Handler implements redis protocol
+ * 解析客户端发来的 RESP 请求, 交给存储引擎执行后再将回复写回客户端
+ */
+
+import (
+	DBImpl "JZ_Redis"
+	"JZ_Redis/interface/db"
+	"JZ_Redis/lib/logger"
+	"JZ_Redis/lib/sync/atomic"
+	"JZ_Redis/redis/connection"
+	"JZ_Redis/redis/parser"
+	"JZ_Redis/redis/reply"
+	"context"
+	"io"
+	"net"
+	"strings"
+	"sync"
+)
+
+var (
+	unknownErrReplyBytes = []byte("-ERR unknown\r\n")
+)
+
+// max number of requests read ahead while the client is blocked
+const pendingPayloadSize = 64
+
+// Handler implements tcp.Handler and serves as a redis server
+type Handler struct {
+	// 保存所有工作状态client的集合(把map当set用)
+	activeConn sync.Map // *client -> placeholder
+	db         db.DB
+	// refusing new client and new request
+	closing atomic.Boolean
+	// ListenAndServe may call Close more than once, db should be closed only once
+	closeOnce sync.Once
+}
+
+// MakeHandler creates a Handler instance
+func MakeHandler() *Handler {
+	return &Handler{
+		db: DBImpl.MakeDB(),
+	}
+}
+
+func (h *Handler) closeClient(client *connection.Connection) {
+	_ = client.Close()
+	h.db.AfterClientClose(client)
+	h.activeConn.Delete(client)
+}
+
+// Handle receives and executes redis commands
+func (h *Handler) Handle(ctx context.Context, conn net.Conn) {
+	if h.closing.Get() {
+		// closing handler refuse new connection
+		_ = conn.Close()
+		return
+	}
+
+	client := connection.NewConn(conn)
+	h.activeConn.Store(client, struct{}{})
+
+	ch := parser.ParseStream(conn)
+	// blocking commands such as BLPOP hold the loop below,
+	// forward payloads in another goroutine so that the disconnection of blocked client could be found in time
+	payloads := make(chan *parser.Payload, pendingPayloadSize)
+	go func() {
+		for payload := range ch {
+			if payload.Err != nil && isClosedErr(payload.Err) {
+				h.db.AfterClientClose(client)
+			}
+			payloads <- payload
+		}
+		close(payloads)
+	}()
+	// drain payloads after returning, let the forwarding goroutine exit
+	defer func() {
+		go func() {
+			for range payloads {
+			}
+		}()
+	}()
+
+	for payload := range payloads {
+		if payload.Err != nil {
+			if isClosedErr(payload.Err) {
+				// connection closed
+				h.closeClient(client)
+				logger.Info("connection closed: " + client.RemoteAddr().String())
+				return
+			}
+			// protocol err
+			errReply := reply.MakeErrReply(payload.Err.Error())
+			err := client.Write(errReply.ToBytes())
+			if err != nil {
+				h.closeClient(client)
+				logger.Info("connection closed: " + client.RemoteAddr().String())
+				return
+			}
+			continue
+		}
+		if payload.Data == nil {
+			logger.Error("empty payload")
+			continue
+		}
+		r, ok := payload.Data.(*reply.MultiBulkReply)
+		if !ok {
+			logger.Error("require multi bulk reply")
+			continue
+		}
+		result := h.db.Exec(client, r.Args)
+		if result != nil {
+			_ = client.Write(result.ToBytes())
+		} else {
+			_ = client.Write(unknownErrReplyBytes)
+		}
+	}
+}
+
+func isClosedErr(err error) bool {
+	return err == io.EOF ||
+		err == io.ErrUnexpectedEOF ||
+		strings.Contains(err.Error(), "use of closed network connection")
+}
+
+// Close stops handler
+func (h *Handler) Close() error {
+	h.closeOnce.Do(func() {
+		logger.Info("handler shutting down...")
+		h.closing.Set(true)
+		// 逐个关闭连接
+		h.activeConn.Range(func(key interface{}, val interface{}) bool {
+			client := key.(*connection.Connection)
+			_ = client.Close()
+			return true
+		})
+		h.db.Close()
+	})
+	return nil
+}
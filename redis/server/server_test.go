@@ -1 +1,55 @@
 package server
+
+import (
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/tcp"
+	"bufio"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestHandler(t *testing.T) {
+	closeChan := make(chan struct{})
+	listener, err := net.Listen("tcp", ":0") // a port number is automatically chosen
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	addr := listener.Addr().String()
+	go tcp.ListenAndServe(listener, MakeHandler(), closeChan)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	reader := bufio.NewReader(conn)
+	requests := []struct {
+		cmdLine  [][]byte
+		expected string
+	}{
+		{[][]byte{[]byte("PING")}, "+PONG\r\n"},
+		{[][]byte{[]byte("ping"), []byte("hello")}, "+hello\r\n"},
+		{[][]byte{[]byte("NoSuchCmd")}, "-ERR unknown command 'nosuchcmd'\r\n"},
+		{[][]byte{[]byte("ping"), []byte("a"), []byte("b")}, "-ERR wrong number of arguments for 'ping' command\r\n"},
+	}
+	for _, req := range requests {
+		_, err = conn.Write(reply.MakeMultiBulkReply(req.cmdLine).ToBytes())
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if line != req.expected {
+			t.Errorf("expected %q, actually %q", req.expected, line)
+		}
+	}
+	_ = conn.Close()
+	closeChan <- struct{}{}
+	time.Sleep(time.Second)
+}
@@ -1,6 +1,9 @@
 package JZ_Redis
 
-import "strings"
+import (
+	"errors"
+	"strings"
+)
 
 var cmdTable = make(map[string]*command)
 
@@ -23,3 +26,17 @@ func RegisterCommand(name string, executor ExecFunc, prepare PreFunc, rollback U
 		arity:    arity,
 	}
 }
+
+// CheckCmdLine returns error if the command is unknown or its number of arguments is wrong,
+// it is used to verify persisted commands without executing them
+func CheckCmdLine(cmdLine CmdLine) error {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	cmd, ok := cmdTable[cmdName]
+	if !ok {
+		return errors.New("unknown command '" + cmdName + "'")
+	}
+	if !validateArity(cmd.arity, cmdLine) {
+		return errors.New("wrong number of arguments for '" + cmdName + "' command")
+	}
+	return nil
+}
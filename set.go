@@ -0,0 +1,550 @@
+package JZ_Redis
+
+import (
+	HashSet "JZ_Redis/datastruct/set"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+	"strconv"
+	"strings"
+)
+
+func (db *DB) getAsSet(key string) (*HashSet.Set, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	set, ok := entity.Data.(*HashSet.Set)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return set, nil
+}
+
+func (db *DB) getOrInitSet(key string) (set *HashSet.Set, inited bool, errReply reply.ErrorReply) {
+	set, errReply = db.getAsSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if set == nil {
+		set = HashSet.Make()
+		db.PutEntity(key, &DataEntity{
+			Data: set,
+		})
+		inited = true
+	}
+	return set, inited, nil
+}
+
+func setToReply(set *HashSet.Set) redis.Reply {
+	if set == nil || set.Len() == 0 {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	arr := make([][]byte, 0, set.Len())
+	set.ForEach(func(member string) bool {
+		arr = append(arr, []byte(member))
+		return true
+	})
+	return reply.MakeMultiBulkReply(arr)
+}
+
+// execSAdd adds members into set
+func execSAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	// get or init entity
+	set, _, errReply := db.getOrInitSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	counter := 0
+	for _, member := range members {
+		counter += set.Add(string(member))
+	}
+	if counter > 0 {
+		db.AddAof(makeAofCmd("sadd", args))
+		db.notifyKeyspaceEvent(notifySet, "sadd", key)
+	}
+	return reply.MakeIntReply(int64(counter))
+}
+
+// execSIsMember checks if the given value is member of set
+func execSIsMember(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	member := string(args[1])
+
+	// get set
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	has := set.Has(member)
+	if has {
+		return reply.MakeIntReply(1)
+	}
+	return reply.MakeIntReply(0)
+}
+
+// execSMIsMember checks whether each of the given values is member of set
+func execSMIsMember(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	result := make([]redis.Reply, len(members))
+	for i, member := range members {
+		if set != nil && set.Has(string(member)) {
+			result[i] = reply.MakeIntReply(1)
+		} else {
+			result[i] = reply.MakeIntReply(0)
+		}
+	}
+	return reply.MakeMultiRawReply(result)
+}
+
+// execSRem removes members from set
+func execSRem(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	members := args[1:]
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+	counter := 0
+	for _, member := range members {
+		counter += set.Remove(string(member))
+	}
+	if counter > 0 {
+		db.AddAof(makeAofCmd("srem", args))
+		db.notifyKeyspaceEvent(notifySet, "srem", key)
+	}
+	if set.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(int64(counter))
+}
+
+func undoSetChange(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	memberArgs := args[1:]
+	members := make([]string, len(memberArgs))
+	for i, mem := range memberArgs {
+		members[i] = string(mem)
+	}
+	return rollbackSetMembers(db, key, members...)
+}
+
+// execSCard gets the number of members in a set
+func execSCard(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get or init entity
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(int64(set.Len()))
+}
+
+// execSMembers gets all members in a set
+func execSMembers(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	// get or init entity
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(set)
+}
+
+// execSPop removes and returns random members of set
+func execSPop(db *DB, args [][]byte) redis.Reply {
+	if len(args) > 2 {
+		return &reply.SyntaxErrReply{}
+	}
+	key := string(args[0])
+	count := 1
+	if len(args) == 2 {
+		count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+		if err != nil || count64 < 0 {
+			return reply.MakeErrReply("ERR value is out of range, must be positive")
+		}
+		count = int(count64)
+	}
+
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if len(args) == 1 {
+			return &reply.NullBulkReply{}
+		}
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	members := set.RandomDistinctMembers(count)
+	result := make([][]byte, len(members))
+	for i, member := range members {
+		set.Remove(member)
+		result[i] = []byte(member)
+	}
+	if len(members) > 0 {
+		// propagate removed members instead of random spop
+		db.AddAof(makeAofCmd("srem", append([][]byte{args[0]}, result...)))
+		db.notifyKeyspaceEvent(notifySet, "spop", key)
+	}
+	if set.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+
+	if len(args) == 1 {
+		return reply.MakeBulkReply(result[0])
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// execSRandMember gets random members from set
+// positive count returns distinct members, negative count allows the same member to be returned multiple times
+func execSRandMember(db *DB, args [][]byte) redis.Reply {
+	if len(args) != 1 && len(args) != 2 {
+		return reply.MakeErrReply("ERR wrong number of arguments for 'srandmember' command")
+	}
+	key := string(args[0])
+
+	// get or init entity
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if set == nil {
+		if len(args) == 1 {
+			return &reply.NullBulkReply{}
+		}
+		return &reply.EmptyMultiBulkReply{}
+	}
+	if len(args) == 1 {
+		// get a random member
+		members := set.RandomMembers(1)
+		return reply.MakeBulkReply([]byte(members[0]))
+	}
+	count64, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	count := int(count64)
+	var members []string
+	if count >= 0 {
+		members = set.RandomDistinctMembers(count)
+	} else {
+		members = set.RandomMembers(-count)
+	}
+	result := make([][]byte, len(members))
+	for i, v := range members {
+		result[i] = []byte(v)
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+func prepareSMove(args [][]byte) ([]string, []string) {
+	return writeAllKeys(args[:2])
+}
+
+// execSMove moves member from source set to destination set
+func execSMove(db *DB, args [][]byte) redis.Reply {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+
+	srcSet, errReply := db.getAsSet(src)
+	if errReply != nil {
+		return errReply
+	}
+	destSet, errReply := db.getAsSet(dest)
+	if errReply != nil {
+		return errReply
+	}
+	if srcSet == nil || !srcSet.Has(member) {
+		return reply.MakeIntReply(0)
+	}
+	if src == dest {
+		return reply.MakeIntReply(1)
+	}
+
+	if destSet == nil {
+		destSet, _, _ = db.getOrInitSet(dest)
+	}
+	srcSet.Remove(member)
+	destSet.Add(member)
+	db.AddAof(makeAofCmd("smove", args))
+	db.notifyKeyspaceEvent(notifySet, "srem", src)
+	db.notifyKeyspaceEvent(notifySet, "sadd", dest)
+	if srcSet.Len() == 0 {
+		db.Remove(src)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", src)
+	}
+	return reply.MakeIntReply(1)
+}
+
+func undoSMove(db *DB, args [][]byte) []CmdLine {
+	src := string(args[0])
+	dest := string(args[1])
+	member := string(args[2])
+	undoCmdLines := rollbackSetMembers(db, src, member)
+	return append(undoCmdLines, rollbackSetMembers(db, dest, member)...)
+}
+
+// getSets returns sets bound to the given keys, absent key is returned as nil
+func (db *DB) getSets(keys [][]byte) ([]*HashSet.Set, reply.ErrorReply) {
+	sets := make([]*HashSet.Set, len(keys))
+	for i, key := range keys {
+		set, errReply := db.getAsSet(string(key))
+		if errReply != nil {
+			return nil, errReply
+		}
+		sets[i] = set
+	}
+	return sets, nil
+}
+
+// intersectSets returns nil if any of the sets is empty
+func intersectSets(sets []*HashSet.Set) *HashSet.Set {
+	var result *HashSet.Set
+	for _, set := range sets {
+		if set == nil {
+			return nil
+		}
+		if result == nil {
+			result = HashSet.Make(set.ToSlice()...)
+		} else {
+			result = result.Intersect(set)
+		}
+		if result.Len() == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+func unionSets(sets []*HashSet.Set) *HashSet.Set {
+	result := HashSet.Make()
+	for _, set := range sets {
+		if set == nil {
+			continue
+		}
+		result = result.Union(set)
+	}
+	return result
+}
+
+// diffSets subtracts others from the first set
+func diffSets(sets []*HashSet.Set) *HashSet.Set {
+	if sets[0] == nil {
+		return nil
+	}
+	result := HashSet.Make(sets[0].ToSlice()...)
+	for _, set := range sets[1:] {
+		if set == nil {
+			continue
+		}
+		result = result.Diff(set)
+		if result.Len() == 0 {
+			return nil
+		}
+	}
+	return result
+}
+
+// execSInter intersect multiple sets
+func execSInter(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(intersectSets(sets))
+}
+
+// execSUnion adds multiple sets
+func execSUnion(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(unionSets(sets))
+}
+
+// execSDiff subtracts multiple sets
+func execSDiff(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args)
+	if errReply != nil {
+		return errReply
+	}
+	return setToReply(diffSets(sets))
+}
+
+// storeSet replaces dest with the result set, empty result removes dest
+func (db *DB) storeSet(dest string, result *HashSet.Set) int {
+	db.Remove(dest)
+	if result == nil || result.Len() == 0 {
+		return 0
+	}
+	db.PutEntity(dest, &DataEntity{
+		Data: result,
+	})
+	return result.Len()
+}
+
+// execSInterStore intersects multiple sets and store the result in a key
+func execSInterStore(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	size := db.storeSet(string(args[0]), intersectSets(sets))
+	db.AddAof(makeAofCmd("sinterstore", args))
+	db.notifyStoreEvent(notifySet, "sinterstore", string(args[0]), size)
+	return reply.MakeIntReply(int64(size))
+}
+
+// execSUnionStore adds multiple sets and store the result in a key
+func execSUnionStore(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	size := db.storeSet(string(args[0]), unionSets(sets))
+	db.AddAof(makeAofCmd("sunionstore", args))
+	db.notifyStoreEvent(notifySet, "sunionstore", string(args[0]), size)
+	return reply.MakeIntReply(int64(size))
+}
+
+// execSDiffStore subtracts multiple sets and store the result in a key
+func execSDiffStore(db *DB, args [][]byte) redis.Reply {
+	sets, errReply := db.getSets(args[1:])
+	if errReply != nil {
+		return errReply
+	}
+	size := db.storeSet(string(args[0]), diffSets(sets))
+	db.AddAof(makeAofCmd("sdiffstore", args))
+	db.notifyStoreEvent(notifySet, "sdiffstore", string(args[0]), size)
+	return reply.MakeIntReply(int64(size))
+}
+
+func prepareSetCalculateStore(args [][]byte) ([]string, []string) {
+	dest := string(args[0])
+	keys := make([]string, len(args)-1)
+	keyArgs := args[1:]
+	for i, arg := range keyArgs {
+		keys[i] = string(arg)
+	}
+	return []string{dest}, keys
+}
+
+// parseSInterCardKeys returns keys and limit of SINTERCARD/ZINTERCARD numkeys key [key ...] [LIMIT limit]
+func parseSInterCardKeys(args [][]byte) ([][]byte, int, reply.ErrorReply) {
+	numKeys, err := strconv.ParseInt(string(args[0]), 10, 64)
+	if err != nil {
+		return nil, 0, reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys <= 0 {
+		return nil, 0, reply.MakeErrReply("ERR numkeys should be greater than 0")
+	}
+	if numKeys > int64(len(args)-1) {
+		return nil, 0, reply.MakeErrReply("ERR Number of keys can't be greater than number of args")
+	}
+	keys := args[1 : 1+numKeys]
+	rest := args[1+numKeys:]
+	limit := 0
+	if len(rest) > 0 {
+		if len(rest) != 2 || strings.ToUpper(string(rest[0])) != "LIMIT" {
+			return nil, 0, &reply.SyntaxErrReply{}
+		}
+		limit64, err := strconv.ParseInt(string(rest[1]), 10, 64)
+		if err != nil {
+			return nil, 0, reply.MakeErrReply("ERR value is not an integer or out of range")
+		}
+		if limit64 < 0 {
+			return nil, 0, reply.MakeErrReply("ERR LIMIT can't be negative")
+		}
+		limit = int(limit64)
+	}
+	return keys, limit, nil
+}
+
+func prepareSInterCard(args [][]byte) ([]string, []string) {
+	keys, _, errReply := parseSInterCardKeys(args)
+	if errReply != nil {
+		return nil, nil
+	}
+	return readAllKeys(keys)
+}
+
+// execSInterCard returns the cardinality of the intersection, stops counting when reaching limit
+func execSInterCard(db *DB, args [][]byte) redis.Reply {
+	keys, limit, errReply := parseSInterCardKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	sets, errReply := db.getSets(keys)
+	if errReply != nil {
+		return errReply
+	}
+	// iterate the smallest set and check others
+	var smallest *HashSet.Set
+	for _, set := range sets {
+		if set == nil {
+			return reply.MakeIntReply(0)
+		}
+		if smallest == nil || set.Len() < smallest.Len() {
+			smallest = set
+		}
+	}
+	count := 0
+	smallest.ForEach(func(member string) bool {
+		for _, set := range sets {
+			if !set.Has(member) {
+				return true
+			}
+		}
+		count++
+		return limit == 0 || count < limit
+	})
+	return reply.MakeIntReply(int64(count))
+}
+
+func init() {
+	RegisterCommand("SAdd", execSAdd, writeFirstKey, undoSetChange, -3)
+	RegisterCommand("SIsMember", execSIsMember, readFirstKey, nil, 3)
+	RegisterCommand("SMIsMember", execSMIsMember, readFirstKey, nil, -3)
+	RegisterCommand("SRem", execSRem, writeFirstKey, undoSetChange, -3)
+	RegisterCommand("SPop", execSPop, writeFirstKey, rollbackFirstKey, -2)
+	RegisterCommand("SCard", execSCard, readFirstKey, nil, 2)
+	RegisterCommand("SMembers", execSMembers, readFirstKey, nil, 2)
+	RegisterCommand("SMove", execSMove, prepareSMove, undoSMove, 4)
+	RegisterCommand("SInter", execSInter, readAllKeys, nil, -2)
+	RegisterCommand("SInterStore", execSInterStore, prepareSetCalculateStore, rollbackFirstKey, -3)
+	RegisterCommand("SInterCard", execSInterCard, prepareSInterCard, nil, -3)
+	RegisterCommand("SUnion", execSUnion, readAllKeys, nil, -2)
+	RegisterCommand("SUnionStore", execSUnionStore, prepareSetCalculateStore, rollbackFirstKey, -3)
+	RegisterCommand("SDiff", execSDiff, readAllKeys, nil, -2)
+	RegisterCommand("SDiffStore", execSDiffStore, prepareSetCalculateStore, rollbackFirstKey, -3)
+	RegisterCommand("SRandMember", execSRandMember, readFirstKey, nil, -2)
+}
@@ -0,0 +1,189 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply"
+	"JZ_Redis/redis/reply/asserts"
+	"strconv"
+	"testing"
+)
+
+func TestSAdd(t *testing.T) {
+	testDB.Flush()
+	size := 100
+
+	key := utils.RandString(10)
+	for i := 0; i < size; i++ {
+		member := strconv.Itoa(i)
+		result := testDB.Exec(nil, utils.ToCmdLine("sadd", key, member))
+		asserts.AssertIntReply(t, result, 1)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("sadd", key, "0", "new"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("scard", key))
+	asserts.AssertIntReply(t, result, size+1)
+	result = testDB.Exec(nil, utils.ToCmdLine("smembers", key))
+	asserts.AssertMultiBulkReplySize(t, result, size+1)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("sismember", key, "10"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("sismember", key, "-1"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("smismember", key, "10", "-1"))
+	expected := reply.MakeMultiRawReply([]redis.Reply{reply.MakeIntReply(1), reply.MakeIntReply(0)})
+	if !utils.BytesEquals(result.ToBytes(), expected.ToBytes()) {
+		t.Errorf("expected %s, actually %s", expected.ToBytes(), result.ToBytes())
+	}
+
+	testDB.Exec(nil, utils.ToCmdLine("set", "str", "a"))
+	result = testDB.Exec(nil, utils.ToCmdLine("sadd", "str", "a"))
+	asserts.AssertErrReply(t, result, "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func TestSRem(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a", "b"))
+	result := testDB.Exec(nil, utils.ToCmdLine("srem", key, "a", "c"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("srem", key, "b"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestSPop(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	result := testDB.Exec(nil, utils.ToCmdLine("spop", key))
+	asserts.AssertNullBulk(t, result)
+
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a", "b", "c"))
+	result = testDB.Exec(nil, utils.ToCmdLine("spop", key))
+	member := string(result.(*reply.BulkReply).Arg)
+	result = testDB.Exec(nil, utils.ToCmdLine("sismember", key, member))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("spop", key, "5"))
+	asserts.AssertMultiBulkReplySize(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("spop", key, "-1"))
+	asserts.AssertErrReply(t, result, "ERR value is out of range, must be positive")
+}
+
+func TestSRandMember(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	for i := 0; i < 100; i++ {
+		testDB.Exec(nil, utils.ToCmdLine("sadd", key, strconv.Itoa(i)))
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("srandmember", key))
+	if _, ok := result.(*reply.BulkReply); !ok {
+		t.Errorf("expected bulk reply, actually %s", result.ToBytes())
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("srandmember", key, "10"))
+	asserts.AssertMultiBulkReplySize(t, result, 10)
+	members := make(map[string]struct{})
+	for _, arg := range result.(*reply.MultiBulkReply).Args {
+		members[string(arg)] = struct{}{}
+	}
+	if len(members) != 10 {
+		t.Errorf("expected 10 distinct members, actually %d", len(members))
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine("srandmember", key, "200"))
+	asserts.AssertMultiBulkReplySize(t, result, 100)
+	// negative count may return the same member multiple times
+	result = testDB.Exec(nil, utils.ToCmdLine("srandmember", key, "-200"))
+	asserts.AssertMultiBulkReplySize(t, result, 200)
+	result = testDB.Exec(nil, utils.ToCmdLine("srandmember", utils.RandString(10), "-3"))
+	asserts.AssertMultiBulkReplySize(t, result, 0)
+}
+
+func TestSMove(t *testing.T) {
+	testDB.Flush()
+	src := utils.RandString(10)
+	dest := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("sadd", src, "a"))
+	result := testDB.Exec(nil, utils.ToCmdLine("smove", src, dest, "b"))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("smove", src, dest, "a"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", src))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("sismember", dest, "a"))
+	asserts.AssertIntReply(t, result, 1)
+}
+
+func TestSetAlgebraCommands(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	dest := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key1, "a", "b", "c"))
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key2, "b", "c", "d"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("sinter", key1, key2))
+	assertUnorderedMultiBulk(t, result, []string{"b", "c"})
+	result = testDB.Exec(nil, utils.ToCmdLine("sunion", key1, key2))
+	assertUnorderedMultiBulk(t, result, []string{"a", "b", "c", "d"})
+	result = testDB.Exec(nil, utils.ToCmdLine("sdiff", key1, key2))
+	assertUnorderedMultiBulk(t, result, []string{"a"})
+	result = testDB.Exec(nil, utils.ToCmdLine("sinter", key1, utils.RandString(10)))
+	asserts.AssertMultiBulkReplySize(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("sinterstore", dest, key1, key2))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("smembers", dest))
+	assertUnorderedMultiBulk(t, result, []string{"b", "c"})
+	result = testDB.Exec(nil, utils.ToCmdLine("sunionstore", dest, key1, key2))
+	asserts.AssertIntReply(t, result, 4)
+	result = testDB.Exec(nil, utils.ToCmdLine("sdiffstore", dest, key1, key2))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("smembers", dest))
+	assertUnorderedMultiBulk(t, result, []string{"a"})
+	// empty result removes dest
+	result = testDB.Exec(nil, utils.ToCmdLine("sdiffstore", dest, key1, key1))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", dest))
+	asserts.AssertIntReply(t, result, 0)
+	// operands are not modified
+	result = testDB.Exec(nil, utils.ToCmdLine("scard", key1))
+	asserts.AssertIntReply(t, result, 3)
+}
+
+func TestSInterCard(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key1, "a", "b", "c", "d"))
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key2, "b", "c", "d", "e"))
+	result := testDB.Exec(nil, utils.ToCmdLine("sintercard", "2", key1, key2))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "2", key1, key2, "LIMIT", "2"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "2", key1, key2, "LIMIT", "0"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "2", key1, utils.RandString(10)))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "0", key1))
+	asserts.AssertErrReply(t, result, "ERR numkeys should be greater than 0")
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "3", key1, key2))
+	asserts.AssertErrReply(t, result, "ERR Number of keys can't be greater than number of args")
+	result = testDB.Exec(nil, utils.ToCmdLine("sintercard", "1", key1, "LIMIT", "-1"))
+	asserts.AssertErrReply(t, result, "ERR LIMIT can't be negative")
+}
+
+func TestUndoSAdd(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a"))
+	undoCmdLines := undoSetChange(testDB, utils.ToCmdLine(key, "a", "b"))
+	testDB.Exec(nil, utils.ToCmdLine("sadd", key, "a", "b"))
+	for _, cmdLine := range undoCmdLines {
+		testDB.applyUndoLog(cmdLine)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("smembers", key))
+	asserts.AssertMultiBulkReply(t, result, []string{"a"})
+}
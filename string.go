@@ -3,6 +3,7 @@ package JZ_Redis
 import (
 	"JZ_Redis/interface/redis"
 	"JZ_Redis/redis/reply"
+	"github.com/shopspring/decimal"
 	"strconv"
 	"strings"
 	"time"
@@ -137,6 +138,10 @@ func execSet(db *DB, args [][]byte) redis.Reply {
 	}
 
 	if policy == upsertPolicy || result > 0 {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+		if ttl != unlimitedTTL {
+			db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
+		}
 		return &reply.OkReply{}
 	}
 	return &reply.NullBulkReply{}
@@ -151,6 +156,9 @@ func execSetNX(db *DB, args [][]byte) redis.Reply {
 	}
 	result := db.PutIfAbsent(key, entity)
 	db.AddAof(makeAofCmd("setnx", args))
+	if result > 0 {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return reply.MakeIntReply(int64(result))
 }
 
@@ -177,6 +185,8 @@ func execSetEX(db *DB, args [][]byte) redis.Reply {
 	db.Expire(key, expireTime)
 	db.AddAof(makeAofCmd("setex", args))
 	db.AddAof(makeExpireCmd(key, expireTime))
+	db.notifyKeyspaceEvent(notifyString, "set", key)
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
 	return &reply.OkReply{}
 }
 
@@ -202,6 +212,8 @@ func execPSetEX(db *DB, args [][]byte) redis.Reply {
 	db.Expire(key, expireTime)
 	db.AddAof(makeAofCmd("setex", args))
 	db.AddAof(makeExpireCmd(key, expireTime))
+	db.notifyKeyspaceEvent(notifyString, "set", key)
+	db.notifyKeyspaceEvent(notifyGeneric, "expire", key)
 
 	return &reply.OkReply{}
 }
@@ -239,6 +251,9 @@ func execMSet(db *DB, args [][]byte) redis.Reply {
 		db.PutEntity(key, &DataEntity{Data: value})
 	}
 	db.AddAof(makeAofCmd("mset", args))
+	for _, key := range keys {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return &reply.OkReply{}
 }
 
@@ -301,6 +316,9 @@ func execMSetNX(db *DB, args [][]byte) redis.Reply {
 		db.PutEntity(key, &DataEntity{Data: value})
 	}
 	db.AddAof(makeAofCmd("msetnx", args))
+	for _, key := range keys {
+		db.notifyKeyspaceEvent(notifyString, "set", key)
+	}
 	return reply.MakeIntReply(1)
 }
 
@@ -317,6 +335,7 @@ func execGetSet(db *DB, args [][]byte) redis.Reply {
 	db.PutEntity(key, &DataEntity{Data: value})
 	db.Persist(key) // override ttl
 	db.AddAof(makeAofCmd("getset", args))
+	db.notifyKeyspaceEvent(notifyString, "set", key)
 	if old == nil {
 		return new(reply.NullBulkReply)
 	}
@@ -340,12 +359,14 @@ func execIncr(db *DB, args [][]byte) redis.Reply {
 			Data: []byte(strconv.FormatInt(val+1, 10)),
 		})
 		db.AddAof(makeAofCmd("incr", args))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val + 1)
 	}
 	db.PutEntity(key, &DataEntity{
 		Data: []byte("1"),
 	})
 	db.AddAof(makeAofCmd("incr", args))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(1)
 }
 
@@ -372,12 +393,14 @@ func execIncrBy(db *DB, args [][]byte) redis.Reply {
 			Data: []byte(strconv.FormatInt(val+delta, 10)),
 		})
 		db.AddAof(makeAofCmd("incrby", args))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val + delta)
 	}
 	db.PutEntity(key, &DataEntity{
 		Data: args[1],
 	})
 	db.AddAof(makeAofCmd("incrby", args))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(delta)
 }
 
@@ -404,12 +427,14 @@ func execIncrByFloat(db *DB, args [][]byte) redis.Reply {
 			Data: resultBytes,
 		})
 		db.AddAof(makeAofCmd("incrbyfloat", args))
+		db.notifyKeyspaceEvent(notifyString, "incrbyfloat", key)
 		return reply.MakeBulkReply(resultBytes)
 	}
 	db.PutEntity(key, &DataEntity{
 		Data: args[1],
 	})
 	db.AddAof(makeAofCmd("incrbyfloat", args))
+	db.notifyKeyspaceEvent(notifyString, "incrbyfloat", key)
 	return reply.MakeBulkReply(args[1])
 }
 
@@ -430,6 +455,7 @@ func execDecr(db *DB, args [][]byte) redis.Reply {
 			Data: []byte(strconv.FormatInt(val-1, 10)),
 		})
 		db.AddAof(makeAofCmd("decr", args))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val - 1)
 	}
 	entity := &DataEntity{
@@ -437,6 +463,7 @@ func execDecr(db *DB, args [][]byte) redis.Reply {
 	}
 	db.PutEntity(key, entity)
 	db.AddAof(makeAofCmd("decr", args))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(-1)
 }
 
@@ -462,6 +489,7 @@ func execDecrBy(db *DB, args [][]byte) redis.Reply {
 			Data: []byte(strconv.FormatInt(val-delta, 10)),
 		})
 		db.AddAof(makeAofCmd("decrby", args))
+		db.notifyKeyspaceEvent(notifyString, "incrby", key)
 		return reply.MakeIntReply(val - delta)
 	}
 	valueStr := strconv.FormatInt(-delta, 10)
@@ -469,6 +497,7 @@ func execDecrBy(db *DB, args [][]byte) redis.Reply {
 		Data: []byte(valueStr),
 	})
 	db.AddAof(makeAofCmd("decrby", args))
+	db.notifyKeyspaceEvent(notifyString, "incrby", key)
 	return reply.MakeIntReply(-delta)
 }
 
@@ -497,6 +526,7 @@ func execAppend(db *DB, args [][]byte) redis.Reply {
 		Data: bytes,
 	})
 	db.AddAof(makeAofCmd("append", args))
+	db.notifyKeyspaceEvent(notifyString, "append", key)
 	return reply.MakeIntReply(int64(len(bytes)))
 }
 
@@ -532,6 +562,7 @@ func execSetRange(db *DB, args [][]byte) redis.Reply {
 		Data: bytes,
 	})
 	db.AddAof(makeAofCmd("setRange", args))
+	db.notifyKeyspaceEvent(notifyString, "setrange", key)
 	return reply.MakeIntReply(int64(len(bytes)))
 }
 
@@ -0,0 +1,279 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/logger"
+	"JZ_Redis/lib/utils"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/* ---- prepare functions, return related write keys and read keys ---- */
+
+func readFirstKey(args [][]byte) ([]string, []string) {
+	// assert len(args) > 0
+	key := string(args[0])
+	return nil, []string{key}
+}
+
+func writeFirstKey(args [][]byte) ([]string, []string) {
+	key := string(args[0])
+	return []string{key}, nil
+}
+
+func writeAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return keys, nil
+}
+
+func readAllKeys(args [][]byte) ([]string, []string) {
+	keys := make([]string, len(args))
+	for i, v := range args {
+		keys[i] = string(v)
+	}
+	return nil, keys
+}
+
+// noPrepare is the PreFunc of commands which don't touch any key
+func noPrepare(args [][]byte) ([]string, []string) {
+	return nil, nil
+}
+
+/* ---- undo functions, return command lines which restore the related keys ---- */
+
+func rollbackFirstKey(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	return rollbackGivenKeys(db, key)
+}
+
+// rollbackGivenKeys records the whole entity of the given keys
+// 回滚时先删除 key, 再通过 EntityToCmd 和 toTTLCmd 重建原有的数据和过期时间
+func rollbackGivenKeys(db *DB, keys ...string) []CmdLine {
+	var undoCmdLines [][][]byte
+	for _, key := range keys {
+		entity, ok := db.GetEntity(key)
+		if !ok {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("DEL", key),
+			)
+		} else {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("DEL", key), // clean existed first
+				EntityToCmd(key, entity).Args,
+				toTTLCmd(db, key).Args,
+			)
+		}
+	}
+	return undoCmdLines
+}
+
+// rollbackHashFields records the given fields of hash, rather than the whole hash
+func rollbackHashFields(db *DB, key string, fields ...string) []CmdLine {
+	var undoCmdLines [][][]byte
+	dict, errReply := db.getAsDict(key)
+	if errReply != nil {
+		return nil
+	}
+	if dict == nil {
+		undoCmdLines = append(undoCmdLines,
+			utils.ToCmdLine("DEL", key),
+		)
+		return undoCmdLines
+	}
+	for _, field := range fields {
+		entity, ok := dict.Get(field)
+		if !ok {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("HDEL", key, field),
+			)
+		} else {
+			value, _ := entity.([]byte)
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine3("HSET", []byte(key), []byte(field), value),
+			)
+		}
+	}
+	return undoCmdLines
+}
+
+// rollbackSetMembers records the given members of set, rather than the whole set
+func rollbackSetMembers(db *DB, key string, members ...string) []CmdLine {
+	var undoCmdLines [][][]byte
+	set, errReply := db.getAsSet(key)
+	if errReply != nil {
+		return nil
+	}
+	if set == nil {
+		undoCmdLines = append(undoCmdLines,
+			utils.ToCmdLine("DEL", key),
+		)
+		return undoCmdLines
+	}
+	for _, member := range members {
+		ok := set.Has(member)
+		if !ok {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("SREM", key, member),
+			)
+		} else {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("SADD", key, member),
+			)
+		}
+	}
+	return undoCmdLines
+}
+
+// rollbackZSetMembers records the given members of sorted set, rather than the whole sorted set
+func rollbackZSetMembers(db *DB, key string, members ...string) []CmdLine {
+	var undoCmdLines [][][]byte
+	zset, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil
+	}
+	if zset == nil {
+		undoCmdLines = append(undoCmdLines,
+			utils.ToCmdLine("DEL", key),
+		)
+		return undoCmdLines
+	}
+	for _, member := range members {
+		elem, ok := zset.Get(member)
+		if !ok {
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("ZREM", key, member),
+			)
+		} else {
+			score := formatScore(elem.Score)
+			undoCmdLines = append(undoCmdLines,
+				utils.ToCmdLine("ZADD", key, score, member),
+			)
+		}
+	}
+	return undoCmdLines
+}
+
+/* ---- apply undo logs ---- */
+
+// applyUndoLog restores data by undo log directly rather than executing it as a command,
+// so that rolling back writes no aof, publishes no keyspace event and serves no blocked client.
+// The invoker should hold locks of the related keys
+func (db *DB) applyUndoLog(cmdLine CmdLine) {
+	cmdName := strings.ToLower(string(cmdLine[0]))
+	key := string(cmdLine[1])
+	args := cmdLine[2:]
+	switch cmdName {
+	case "del":
+		db.Remove(key)
+	case "set":
+		db.PutEntity(key, &DataEntity{Data: args[0]})
+	case "pexpireat":
+		ms, err := strconv.ParseInt(string(args[0]), 10, 64)
+		if err == nil {
+			db.Expire(key, time.Unix(0, ms*int64(time.Millisecond)))
+		}
+	case "persist":
+		db.Persist(key)
+	case "lpush", "rpush":
+		list, _, errReply := db.getOrInitList(key)
+		if errReply != nil {
+			return
+		}
+		for _, value := range args {
+			pushElement(list, value, cmdName == "lpush")
+		}
+	case "lpop", "rpop":
+		list, errReply := db.getAsList(key)
+		if errReply != nil || list == nil || list.Len() == 0 {
+			return
+		}
+		if cmdName == "lpop" {
+			list.Remove(0)
+		} else {
+			list.RemoveLast()
+		}
+		if list.Len() == 0 {
+			db.Remove(key)
+		}
+	case "lset":
+		list, errReply := db.getAsList(key)
+		if errReply != nil || list == nil {
+			return
+		}
+		index, err := strconv.Atoi(string(args[0]))
+		if err != nil {
+			return
+		}
+		if index < 0 {
+			index += list.Len()
+		}
+		if index >= 0 && index < list.Len() {
+			list.Set(index, args[1])
+		}
+	case "sadd":
+		set, _, errReply := db.getOrInitSet(key)
+		if errReply != nil {
+			return
+		}
+		for _, member := range args {
+			set.Add(string(member))
+		}
+	case "srem":
+		set, errReply := db.getAsSet(key)
+		if errReply != nil || set == nil {
+			return
+		}
+		for _, member := range args {
+			set.Remove(string(member))
+		}
+		if set.Len() == 0 {
+			db.Remove(key)
+		}
+	case "hset", "hmset":
+		hash, _, errReply := db.getOrInitDict(key)
+		if errReply != nil {
+			return
+		}
+		for i := 0; i+1 < len(args); i += 2 {
+			hash.Put(string(args[i]), args[i+1])
+		}
+	case "hdel":
+		hash, errReply := db.getAsDict(key)
+		if errReply != nil || hash == nil {
+			return
+		}
+		for _, field := range args {
+			hash.Remove(string(field))
+		}
+		if hash.Len() == 0 {
+			db.Remove(key)
+		}
+	case "zadd":
+		zset, _, errReply := db.getOrInitSortedSet(key)
+		if errReply != nil {
+			return
+		}
+		for i := 0; i+1 < len(args); i += 2 {
+			score, errReply := parseScore(args[i])
+			if errReply == nil {
+				zset.Add(string(args[i+1]), score)
+			}
+		}
+	case "zrem":
+		zset, errReply := db.getAsSortedSet(key)
+		if errReply != nil || zset == nil {
+			return
+		}
+		for _, member := range args {
+			zset.Remove(string(member))
+		}
+		if zset.Len() == 0 {
+			db.Remove(key)
+		}
+	default:
+		logger.Warn("unknown undo log " + cmdName)
+	}
+}
@@ -0,0 +1,1118 @@
+package JZ_Redis
+
+import (
+	HashSet "JZ_Redis/datastruct/set"
+	SortedSet "JZ_Redis/datastruct/sortedset"
+	"JZ_Redis/interface/redis"
+	"JZ_Redis/redis/reply"
+	"math"
+	"strconv"
+	"strings"
+)
+
+func (db *DB) getAsSortedSet(key string) (*SortedSet.SortedSet, reply.ErrorReply) {
+	entity, exists := db.GetEntity(key)
+	if !exists {
+		return nil, nil
+	}
+	sortedSet, ok := entity.Data.(*SortedSet.SortedSet)
+	if !ok {
+		return nil, &reply.WrongTypeErrReply{}
+	}
+	return sortedSet, nil
+}
+
+func (db *DB) getOrInitSortedSet(key string) (sortedSet *SortedSet.SortedSet, inited bool, errReply reply.ErrorReply) {
+	sortedSet, errReply = db.getAsSortedSet(key)
+	if errReply != nil {
+		return nil, false, errReply
+	}
+	inited = false
+	if sortedSet == nil {
+		sortedSet = SortedSet.Make()
+		db.PutEntity(key, &DataEntity{
+			Data: sortedSet,
+		})
+		inited = true
+	}
+	return sortedSet, inited, nil
+}
+
+// formatScore formats score like redis, infinity is formatted as inf and -inf
+func formatScore(score float64) string {
+	if math.IsInf(score, 1) {
+		return "inf"
+	}
+	if math.IsInf(score, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(score, 'f', -1, 64)
+}
+
+// parseScore parses score argument, NaN is not allowed
+func parseScore(arg []byte) (float64, reply.ErrorReply) {
+	score, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil || math.IsNaN(score) {
+		return 0, reply.MakeErrReply("ERR value is not a valid float")
+	}
+	return score, nil
+}
+
+const (
+	zAddNX = 1 << iota
+	zAddXX
+	zAddGT
+	zAddLT
+	zAddCH
+	zAddIncr
+)
+
+var zAddFlags = map[string]int{
+	"NX":   zAddNX,
+	"XX":   zAddXX,
+	"GT":   zAddGT,
+	"LT":   zAddLT,
+	"CH":   zAddCH,
+	"INCR": zAddIncr,
+}
+
+// parseZAddFlags returns flags and the index of first score
+func parseZAddFlags(args [][]byte) (int, int) {
+	flags := 0
+	i := 1
+	for ; i < len(args); i++ {
+		flag, ok := zAddFlags[strings.ToUpper(string(args[i]))]
+		if !ok {
+			break
+		}
+		flags |= flag
+	}
+	return flags, i
+}
+
+// execZAdd adds members into sorted set
+// ZADD key [NX|XX] [GT|LT] [CH] [INCR] score member [score member ...]
+func execZAdd(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	flags, i := parseZAddFlags(args)
+	if flags&zAddNX > 0 && flags&zAddXX > 0 {
+		return reply.MakeErrReply("ERR XX and NX options at the same time are not compatible")
+	}
+	if (flags&zAddGT > 0 && flags&zAddLT > 0) || (flags&zAddNX > 0 && flags&(zAddGT|zAddLT) > 0) {
+		return reply.MakeErrReply("ERR GT, LT, and/or NX options at the same time are not compatible")
+	}
+	pairs := args[i:]
+	if len(pairs) == 0 || len(pairs)%2 != 0 {
+		return &reply.SyntaxErrReply{}
+	}
+	incr := flags&zAddIncr > 0
+	if incr && len(pairs) != 2 {
+		return reply.MakeErrReply("ERR INCR option supports a single increment-element pair")
+	}
+
+	// parse all scores before changing anything
+	elements := make([]*SortedSet.Element, len(pairs)/2)
+	for j := 0; j < len(pairs); j += 2 {
+		score, errReply := parseScore(pairs[j])
+		if errReply != nil {
+			return errReply
+		}
+		elements[j/2] = &SortedSet.Element{
+			Member: string(pairs[j+1]),
+			Score:  score,
+		}
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	added := 0
+	changed := 0
+	var incrResult *float64
+	for _, e := range elements {
+		score := e.Score
+		var exists bool
+		var current *SortedSet.Element
+		if sortedSet != nil {
+			current, exists = sortedSet.Get(e.Member)
+		}
+		if exists {
+			if flags&zAddNX > 0 {
+				continue
+			}
+			if incr {
+				score += current.Score
+				if math.IsNaN(score) {
+					return reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+				}
+			}
+			if (flags&zAddGT > 0 && score <= current.Score) || (flags&zAddLT > 0 && score >= current.Score) {
+				continue
+			}
+			if score != current.Score {
+				sortedSet.Add(e.Member, score)
+				changed++
+			}
+		} else {
+			if flags&zAddXX > 0 {
+				continue
+			}
+			if sortedSet == nil {
+				sortedSet, _, _ = db.getOrInitSortedSet(key)
+			}
+			sortedSet.Add(e.Member, score)
+			added++
+		}
+		incrResult = &score
+	}
+
+	if added+changed > 0 {
+		db.AddAof(makeAofCmd("zadd", args))
+		if incr {
+			db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+		} else {
+			db.notifyKeyspaceEvent(notifyZSet, "zadd", key)
+		}
+	}
+	if incr {
+		if incrResult == nil {
+			// aborted by NX, XX, GT or LT
+			return &reply.NullBulkReply{}
+		}
+		return reply.MakeBulkReply([]byte(formatScore(*incrResult)))
+	}
+	if flags&zAddCH > 0 {
+		return reply.MakeIntReply(int64(added + changed))
+	}
+	return reply.MakeIntReply(int64(added))
+}
+
+func undoZAdd(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	_, i := parseZAddFlags(args)
+	members := make([]string, 0, (len(args)-i)/2)
+	for j := i + 1; j < len(args); j += 2 {
+		members = append(members, string(args[j]))
+	}
+	return rollbackZSetMembers(db, key, members...)
+}
+
+// execZScore gets score of a member in sortedset
+func execZScore(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	member := string(args[1])
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return &reply.NullBulkReply{}
+	}
+
+	element, exists := sortedSet.Get(member)
+	if !exists {
+		return &reply.NullBulkReply{}
+	}
+	value := formatScore(element.Score)
+	return reply.MakeBulkReply([]byte(value))
+}
+
+// execZRank gets index of a member in sortedset, ascending order, start from 0
+func execZRank(db *DB, args [][]byte) redis.Reply {
+	return zRank(db, args, false)
+}
+
+// execZRevRank gets index of a member in sortedset, descending order, start from 0
+func execZRevRank(db *DB, args [][]byte) redis.Reply {
+	return zRank(db, args, true)
+}
+
+func zRank(db *DB, args [][]byte, desc bool) redis.Reply {
+	// parse args
+	key := string(args[0])
+	member := string(args[1])
+
+	// get entity
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return &reply.NullBulkReply{}
+	}
+
+	rank := sortedSet.GetRank(member, desc)
+	if rank < 0 {
+		return &reply.NullBulkReply{}
+	}
+	return reply.MakeIntReply(rank)
+}
+
+// execZCard gets number of members in sortedset
+func execZCard(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+
+	// get entity
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	return reply.MakeIntReply(sortedSet.Len())
+}
+
+// execZCount gets number of members which score within given range
+func execZCount(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	min, err := SortedSet.ParseScoreBorder(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	max, err := SortedSet.ParseScoreBorder(string(args[2]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	// get data
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	return reply.MakeIntReply(sortedSet.Count(min, max))
+}
+
+// execZIncrBy increments the score of a member
+func execZIncrBy(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	field := string(args[2])
+	delta, errReply := parseScore(args[1])
+	if errReply != nil {
+		return errReply
+	}
+
+	// get or init entity
+	sortedSet, _, errReply := db.getOrInitSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+
+	element, exists := sortedSet.Get(field)
+	score := delta
+	if exists {
+		score += element.Score
+		if math.IsNaN(score) {
+			return reply.MakeErrReply("ERR resulting score is not a number (NaN)")
+		}
+	}
+	sortedSet.Add(field, score)
+	db.AddAof(makeAofCmd("zincrby", args))
+	db.notifyKeyspaceEvent(notifyZSet, "zincr", key)
+	return reply.MakeBulkReply([]byte(formatScore(score)))
+}
+
+func undoZIncr(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	field := string(args[2])
+	return rollbackZSetMembers(db, key, field)
+}
+
+// elementsToReply converts elements to multi bulk reply, scores follow members if withScores is true
+func elementsToReply(elements []*SortedSet.Element, withScores bool) redis.Reply {
+	if len(elements) == 0 {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	size := len(elements)
+	if withScores {
+		size *= 2
+	}
+	result := make([][]byte, 0, size)
+	for _, element := range elements {
+		result = append(result, []byte(element.Member))
+		if withScores {
+			result = append(result, []byte(formatScore(element.Score)))
+		}
+	}
+	return reply.MakeMultiBulkReply(result)
+}
+
+// rangeByRank returns members which rank within [start, stop], negative index means counting from tail
+func rangeByRank(db *DB, key string, start int64, stop int64, withScores bool, desc bool) redis.Reply {
+	// get data
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	// compute index
+	from, to := toListRange(int(start), int(stop), int(sortedSet.Len()))
+	if from == to {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	// assert: start in [0, size - 1], stop in [start + 1, size]
+	slice := sortedSet.Range(int64(from), int64(to), desc)
+	return elementsToReply(slice, withScores)
+}
+
+// rangeByScore returns members which score within the given border
+// param limit: < 0 means no limit
+func rangeByScore(db *DB, key string, min *SortedSet.ScoreBorder, max *SortedSet.ScoreBorder, offset int64, limit int64, withScores bool, desc bool) redis.Reply {
+	// get data
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+
+	slice := sortedSet.RangeByScore(min, max, offset, limit, desc)
+	return elementsToReply(slice, withScores)
+}
+
+// zRangeOption stores options shared by ZRANGE family commands
+type zRangeOption struct {
+	byScore    bool
+	byLex      bool
+	desc       bool
+	withScores bool
+	hasLimit   bool
+	offset     int64
+	limit      int64
+}
+
+// parseZRangeOptions parses WITHSCORES, LIMIT offset count and, if allowed, BYSCORE, BYLEX and REV
+func parseZRangeOptions(args [][]byte, allowBy bool) (*zRangeOption, reply.ErrorReply) {
+	option := &zRangeOption{
+		limit: -1,
+	}
+	for i := 0; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "WITHSCORES":
+			option.withScores = true
+		case "LIMIT":
+			if len(args) < i+3 {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			offset, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+			if err != nil {
+				return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			limit, err := strconv.ParseInt(string(args[i+2]), 10, 64)
+			if err != nil {
+				return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+			}
+			option.hasLimit = true
+			option.offset = offset
+			option.limit = limit
+			i += 2
+		case "BYSCORE":
+			if !allowBy {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			option.byScore = true
+		case "BYLEX":
+			if !allowBy {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			option.byLex = true
+		case "REV":
+			if !allowBy {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			option.desc = true
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return option, nil
+}
+
+// execZRange gets members in range
+// ZRANGE key start stop [BYSCORE | BYLEX] [REV] [LIMIT offset count] [WITHSCORES]
+func execZRange(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	option, errReply := parseZRangeOptions(args[3:], true)
+	if errReply != nil {
+		return errReply
+	}
+	if option.byScore && option.byLex {
+		return &reply.SyntaxErrReply{}
+	}
+	if option.byLex {
+		if option.withScores {
+			return reply.MakeErrReply("ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+		}
+		minArg, maxArg := args[1], args[2]
+		if option.desc {
+			minArg, maxArg = maxArg, minArg
+		}
+		return zRangeByLex(db, key, minArg, maxArg, option)
+	}
+	if option.byScore {
+		minArg, maxArg := args[1], args[2]
+		if option.desc {
+			// with REV, start is the max border
+			minArg, maxArg = maxArg, minArg
+		}
+		return zRangeByScore(db, key, minArg, maxArg, option)
+	}
+	if option.hasLimit {
+		return reply.MakeErrReply("ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	}
+	return zRangeByRank(db, key, args[1], args[2], option)
+}
+
+// execZRevRange gets members in range, sort by descending order
+func execZRevRange(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseZRangeOptions(args[3:], false)
+	if errReply != nil {
+		return errReply
+	}
+	if option.hasLimit {
+		return &reply.SyntaxErrReply{}
+	}
+	option.desc = true
+	return zRangeByRank(db, string(args[0]), args[1], args[2], option)
+}
+
+func zRangeByRank(db *DB, key string, startArg []byte, stopArg []byte, option *zRangeOption) redis.Reply {
+	start, err := strconv.ParseInt(string(startArg), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(stopArg), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	return rangeByRank(db, key, start, stop, option.withScores, option.desc)
+}
+
+func zRangeByScore(db *DB, key string, minArg []byte, maxArg []byte, option *zRangeOption) redis.Reply {
+	min, err := SortedSet.ParseScoreBorder(string(minArg))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseScoreBorder(string(maxArg))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	return rangeByScore(db, key, min, max, option.offset, option.limit, option.withScores, option.desc)
+}
+
+// execZRangeByScore gets members which score within given range, in ascending order
+// ZRANGEBYSCORE key min max [WITHSCORES] [LIMIT offset count]
+func execZRangeByScore(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseZRangeOptions(args[3:], false)
+	if errReply != nil {
+		return errReply
+	}
+	return zRangeByScore(db, string(args[0]), args[1], args[2], option)
+}
+
+// execZRevRangeByScore gets members which score within given range, in descending order
+// ZREVRANGEBYSCORE key max min [WITHSCORES] [LIMIT offset count]
+func execZRevRangeByScore(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseZRangeOptions(args[3:], false)
+	if errReply != nil {
+		return errReply
+	}
+	option.desc = true
+	return zRangeByScore(db, string(args[0]), args[2], args[1], option)
+}
+
+func zRangeByLex(db *DB, key string, minArg []byte, maxArg []byte, option *zRangeOption) redis.Reply {
+	min, err := SortedSet.ParseLexBorder(string(minArg))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseLexBorder(string(maxArg))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return &reply.EmptyMultiBulkReply{}
+	}
+	slice := sortedSet.RangeByLex(min, max, option.offset, option.limit, option.desc)
+	return elementsToReply(slice, false)
+}
+
+// parseLexRangeOptions parses LIMIT offset count of ZRANGEBYLEX and ZREVRANGEBYLEX
+func parseLexRangeOptions(args [][]byte) (*zRangeOption, reply.ErrorReply) {
+	option, errReply := parseZRangeOptions(args, false)
+	if errReply != nil {
+		return nil, errReply
+	}
+	if option.withScores {
+		return nil, &reply.SyntaxErrReply{}
+	}
+	return option, nil
+}
+
+// execZRangeByLex gets members within lex range, in ascending order
+// ZRANGEBYLEX key min max [LIMIT offset count]
+func execZRangeByLex(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseLexRangeOptions(args[3:])
+	if errReply != nil {
+		return errReply
+	}
+	return zRangeByLex(db, string(args[0]), args[1], args[2], option)
+}
+
+// execZRevRangeByLex gets members within lex range, in descending order
+// ZREVRANGEBYLEX key max min [LIMIT offset count]
+func execZRevRangeByLex(db *DB, args [][]byte) redis.Reply {
+	option, errReply := parseLexRangeOptions(args[3:])
+	if errReply != nil {
+		return errReply
+	}
+	option.desc = true
+	return zRangeByLex(db, string(args[0]), args[2], args[1], option)
+}
+
+// execZLexCount gets number of members within lex range
+func execZLexCount(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	min, err := SortedSet.ParseLexBorder(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseLexBorder(string(args[2]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+	return reply.MakeIntReply(sortedSet.CountByLex(min, max))
+}
+
+// execZRemRangeByLex removes members within lex range
+func execZRemRangeByLex(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	min, err := SortedSet.ParseLexBorder(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+	max, err := SortedSet.ParseLexBorder(string(args[2]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	removed := sortedSet.RemoveByLex(min, max)
+	if removed > 0 {
+		db.AddAof(makeAofCmd("zremrangebylex", args))
+		db.notifyKeyspaceEvent(notifyZSet, "zremrangebylex", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(removed)
+}
+
+// execZRem removes given members
+func execZRem(db *DB, args [][]byte) redis.Reply {
+	// parse args
+	key := string(args[0])
+	fields := make([]string, len(args)-1)
+	fieldArgs := args[1:]
+	for i, v := range fieldArgs {
+		fields[i] = string(v)
+	}
+
+	// get entity
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	var deleted int64 = 0
+	for _, field := range fields {
+		if sortedSet.Remove(field) {
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		db.AddAof(makeAofCmd("zrem", args))
+		db.notifyKeyspaceEvent(notifyZSet, "zrem", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(deleted)
+}
+
+func undoZRem(db *DB, args [][]byte) []CmdLine {
+	key := string(args[0])
+	fields := make([]string, len(args)-1)
+	fieldArgs := args[1:]
+	for i, v := range fieldArgs {
+		fields[i] = string(v)
+	}
+	return rollbackZSetMembers(db, key, fields...)
+}
+
+// execZRemRangeByScore removes members which score within given range
+func execZRemRangeByScore(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+
+	min, err := SortedSet.ParseScoreBorder(string(args[1]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	max, err := SortedSet.ParseScoreBorder(string(args[2]))
+	if err != nil {
+		return reply.MakeErrReply(err.Error())
+	}
+
+	// get data
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	removed := sortedSet.RemoveByScore(min, max)
+	if removed > 0 {
+		db.AddAof(makeAofCmd("zremrangebyscore", args))
+		db.notifyKeyspaceEvent(notifyZSet, "zremrangebyscore", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(removed)
+}
+
+// execZRemRangeByRank removes members within given indexes
+func execZRemRangeByRank(db *DB, args [][]byte) redis.Reply {
+	key := string(args[0])
+	start, err := strconv.ParseInt(string(args[1]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	stop, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		return reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+
+	// get data
+	sortedSet, errReply := db.getAsSortedSet(key)
+	if errReply != nil {
+		return errReply
+	}
+	if sortedSet == nil {
+		return reply.MakeIntReply(0)
+	}
+
+	// compute index
+	from, to := toListRange(int(start), int(stop), int(sortedSet.Len()))
+	if from == to {
+		return reply.MakeIntReply(0)
+	}
+
+	// assert: start in [0, size - 1], stop in [start + 1, size]
+	removed := sortedSet.RemoveByRank(int64(from), int64(to))
+	if removed > 0 {
+		db.AddAof(makeAofCmd("zremrangebyrank", args))
+		db.notifyKeyspaceEvent(notifyZSet, "zremrangebyrank", key)
+	}
+	if sortedSet.Len() == 0 {
+		db.Remove(key)
+		db.notifyKeyspaceEvent(notifyGeneric, "del", key)
+	}
+	return reply.MakeIntReply(removed)
+}
+
+const (
+	aggregateSum = iota
+	aggregateMin
+	aggregateMax
+)
+
+// zSetOperation holds parsed arguments of ZUNION, ZINTER and ZDIFF like commands
+type zSetOperation struct {
+	keys       [][]byte
+	weights    []float64
+	aggregate  int
+	withScores bool
+}
+
+// zSetOperationKeys returns input keys of `numkeys key [key ...] ...`, returns nil if args are invalid
+func zSetOperationKeys(args [][]byte) [][]byte {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-1 {
+		return nil
+	}
+	return args[1 : 1+numKeys]
+}
+
+// parseZSetOperation parses numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+// WEIGHTS and AGGREGATE are not allowed by ZDIFF, WITHSCORES is not allowed by store commands
+func parseZSetOperation(cmdName string, args [][]byte, allowAggregate bool, allowWithScores bool) (*zSetOperation, reply.ErrorReply) {
+	numKeys, err := strconv.Atoi(string(args[0]))
+	if err != nil {
+		return nil, reply.MakeErrReply("ERR value is not an integer or out of range")
+	}
+	if numKeys <= 0 {
+		return nil, reply.MakeErrReply("ERR at least 1 input key is needed for '" + cmdName + "' command")
+	}
+	if numKeys > len(args)-1 {
+		return nil, &reply.SyntaxErrReply{}
+	}
+	op := &zSetOperation{
+		keys:      args[1 : 1+numKeys],
+		weights:   make([]float64, numKeys),
+		aggregate: aggregateSum,
+	}
+	for i := range op.weights {
+		op.weights[i] = 1
+	}
+	rest := args[1+numKeys:]
+	for i := 0; i < len(rest); i++ {
+		switch strings.ToUpper(string(rest[i])) {
+		case "WEIGHTS":
+			if !allowAggregate || len(rest) < i+1+numKeys {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			for j := 0; j < numKeys; j++ {
+				weight, err := strconv.ParseFloat(string(rest[i+1+j]), 64)
+				if err != nil || math.IsNaN(weight) {
+					return nil, reply.MakeErrReply("ERR weight value is not a float")
+				}
+				op.weights[j] = weight
+			}
+			i += numKeys
+		case "AGGREGATE":
+			if !allowAggregate || len(rest) < i+2 {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			switch strings.ToUpper(string(rest[i+1])) {
+			case "SUM":
+				op.aggregate = aggregateSum
+			case "MIN":
+				op.aggregate = aggregateMin
+			case "MAX":
+				op.aggregate = aggregateMax
+			default:
+				return nil, &reply.SyntaxErrReply{}
+			}
+			i++
+		case "WITHSCORES":
+			if !allowWithScores {
+				return nil, &reply.SyntaxErrReply{}
+			}
+			op.withScores = true
+		default:
+			return nil, &reply.SyntaxErrReply{}
+		}
+	}
+	return op, nil
+}
+
+// getZSetSources returns member-score maps of the given keys, members of a plain set have score 1
+// absent key is returned as nil
+func (db *DB) getZSetSources(keys [][]byte) ([]map[string]float64, reply.ErrorReply) {
+	sources := make([]map[string]float64, len(keys))
+	for i, key := range keys {
+		entity, exists := db.GetEntity(string(key))
+		if !exists {
+			continue
+		}
+		switch data := entity.Data.(type) {
+		case *SortedSet.SortedSet:
+			source := make(map[string]float64, data.Len())
+			data.ForEach(0, data.Len(), false, func(element *SortedSet.Element) bool {
+				source[element.Member] = element.Score
+				return true
+			})
+			sources[i] = source
+		case *HashSet.Set:
+			source := make(map[string]float64, data.Len())
+			data.ForEach(func(member string) bool {
+				source[member] = 1
+				return true
+			})
+			sources[i] = source
+		default:
+			return nil, &reply.WrongTypeErrReply{}
+		}
+	}
+	return sources, nil
+}
+
+// weightScore multiplies score by weight, inf * 0 is treated as 0 like redis
+func weightScore(score float64, weight float64) float64 {
+	result := score * weight
+	if math.IsNaN(result) {
+		return 0
+	}
+	return result
+}
+
+func aggregateScore(aggregate int, a float64, b float64) float64 {
+	switch aggregate {
+	case aggregateMin:
+		return math.Min(a, b)
+	case aggregateMax:
+		return math.Max(a, b)
+	}
+	sum := a + b
+	// inf + -inf
+	if math.IsNaN(sum) {
+		return 0
+	}
+	return sum
+}
+
+func unionZSets(sources []map[string]float64, op *zSetOperation) map[string]float64 {
+	result := make(map[string]float64)
+	for i, source := range sources {
+		for member, score := range source {
+			score = weightScore(score, op.weights[i])
+			if current, ok := result[member]; ok {
+				result[member] = aggregateScore(op.aggregate, current, score)
+			} else {
+				result[member] = score
+			}
+		}
+	}
+	return result
+}
+
+// interZSets returns an empty map if any of the sources is empty
+func interZSets(sources []map[string]float64, op *zSetOperation) map[string]float64 {
+	result := make(map[string]float64)
+	for _, source := range sources {
+		if source == nil {
+			return result
+		}
+	}
+	for member, score := range sources[0] {
+		result[member] = weightScore(score, op.weights[0])
+	}
+	for i, source := range sources[1:] {
+		for member, current := range result {
+			score, ok := source[member]
+			if !ok {
+				delete(result, member)
+				continue
+			}
+			result[member] = aggregateScore(op.aggregate, current, weightScore(score, op.weights[i+1]))
+		}
+	}
+	return result
+}
+
+// diffZSets subtracts others from the first source, scores are kept as they are in the first source
+func diffZSets(sources []map[string]float64) map[string]float64 {
+	result := make(map[string]float64)
+	for member, score := range sources[0] {
+		result[member] = score
+	}
+	for _, source := range sources[1:] {
+		for member := range source {
+			delete(result, member)
+		}
+	}
+	return result
+}
+
+func makeSortedSet(m map[string]float64) *SortedSet.SortedSet {
+	sortedSet := SortedSet.Make()
+	for member, score := range m {
+		sortedSet.Add(member, score)
+	}
+	return sortedSet
+}
+
+// zSetOperationFunc combines sources into a member-score map
+type zSetOperationFunc func(sources []map[string]float64, op *zSetOperation) map[string]float64
+
+func execZSetOperation(db *DB, cmdName string, args [][]byte, allowAggregate bool, combine zSetOperationFunc) redis.Reply {
+	op, errReply := parseZSetOperation(cmdName, args, allowAggregate, true)
+	if errReply != nil {
+		return errReply
+	}
+	sources, errReply := db.getZSetSources(op.keys)
+	if errReply != nil {
+		return errReply
+	}
+	result := makeSortedSet(combine(sources, op))
+	return elementsToReply(result.Range(0, result.Len(), false), op.withScores)
+}
+
+func execZSetOperationStore(db *DB, cmdName string, args [][]byte, allowAggregate bool, combine zSetOperationFunc) redis.Reply {
+	dest := string(args[0])
+	op, errReply := parseZSetOperation(cmdName, args[1:], allowAggregate, false)
+	if errReply != nil {
+		return errReply
+	}
+	sources, errReply := db.getZSetSources(op.keys)
+	if errReply != nil {
+		return errReply
+	}
+	result := makeSortedSet(combine(sources, op))
+	db.Remove(dest)
+	if result.Len() > 0 {
+		db.PutEntity(dest, &DataEntity{
+			Data: result,
+		})
+	}
+	db.AddAof(makeAofCmd(cmdName, args))
+	db.notifyStoreEvent(notifyZSet, cmdName, dest, int(result.Len()))
+	return reply.MakeIntReply(result.Len())
+}
+
+func diffZSetsOperation(sources []map[string]float64, _ *zSetOperation) map[string]float64 {
+	return diffZSets(sources)
+}
+
+// execZUnion ZUNION numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func execZUnion(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperation(db, "zunion", args, true, unionZSets)
+}
+
+// execZInter ZINTER numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX] [WITHSCORES]
+func execZInter(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperation(db, "zinter", args, true, interZSets)
+}
+
+// execZDiff ZDIFF numkeys key [key ...] [WITHSCORES]
+func execZDiff(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperation(db, "zdiff", args, false, diffZSetsOperation)
+}
+
+// execZUnionStore ZUNIONSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZUnionStore(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperationStore(db, "zunionstore", args, true, unionZSets)
+}
+
+// execZInterStore ZINTERSTORE destination numkeys key [key ...] [WEIGHTS weight [weight ...]] [AGGREGATE SUM|MIN|MAX]
+func execZInterStore(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperationStore(db, "zinterstore", args, true, interZSets)
+}
+
+// execZDiffStore ZDIFFSTORE destination numkeys key [key ...]
+func execZDiffStore(db *DB, args [][]byte) redis.Reply {
+	return execZSetOperationStore(db, "zdiffstore", args, false, diffZSetsOperation)
+}
+
+func prepareZSetOperation(args [][]byte) ([]string, []string) {
+	return readAllKeys(zSetOperationKeys(args))
+}
+
+func prepareZSetOperationStore(args [][]byte) ([]string, []string) {
+	dest := string(args[0])
+	_, keys := readAllKeys(zSetOperationKeys(args[1:]))
+	return []string{dest}, keys
+}
+
+// execZInterCard ZINTERCARD numkeys key [key ...] [LIMIT limit], stops counting when reaching limit
+func execZInterCard(db *DB, args [][]byte) redis.Reply {
+	keys, limit, errReply := parseSInterCardKeys(args)
+	if errReply != nil {
+		return errReply
+	}
+	sources, errReply := db.getZSetSources(keys)
+	if errReply != nil {
+		return errReply
+	}
+	// iterate the smallest source and check others
+	var smallest map[string]float64
+	for _, source := range sources {
+		if source == nil {
+			return reply.MakeIntReply(0)
+		}
+		if smallest == nil || len(source) < len(smallest) {
+			smallest = source
+		}
+	}
+	count := 0
+	for member := range smallest {
+		inAll := true
+		for _, source := range sources {
+			if _, ok := source[member]; !ok {
+				inAll = false
+				break
+			}
+		}
+		if inAll {
+			count++
+			if limit > 0 && count >= limit {
+				break
+			}
+		}
+	}
+	return reply.MakeIntReply(int64(count))
+}
+
+func init() {
+	RegisterCommand("ZAdd", execZAdd, writeFirstKey, undoZAdd, -4)
+	RegisterCommand("ZScore", execZScore, readFirstKey, nil, 3)
+	RegisterCommand("ZIncrBy", execZIncrBy, writeFirstKey, undoZIncr, 4)
+	RegisterCommand("ZRank", execZRank, readFirstKey, nil, 3)
+	RegisterCommand("ZRevRank", execZRevRank, readFirstKey, nil, 3)
+	RegisterCommand("ZCount", execZCount, readFirstKey, nil, 4)
+	RegisterCommand("ZCard", execZCard, readFirstKey, nil, 2)
+	RegisterCommand("ZRange", execZRange, readFirstKey, nil, -4)
+	RegisterCommand("ZRevRange", execZRevRange, readFirstKey, nil, -4)
+	RegisterCommand("ZRangeByScore", execZRangeByScore, readFirstKey, nil, -4)
+	RegisterCommand("ZRevRangeByScore", execZRevRangeByScore, readFirstKey, nil, -4)
+	RegisterCommand("ZRangeByLex", execZRangeByLex, readFirstKey, nil, -4)
+	RegisterCommand("ZRevRangeByLex", execZRevRangeByLex, readFirstKey, nil, -4)
+	RegisterCommand("ZLexCount", execZLexCount, readFirstKey, nil, 4)
+	RegisterCommand("ZRem", execZRem, writeFirstKey, undoZRem, -3)
+	RegisterCommand("ZRemRangeByScore", execZRemRangeByScore, writeFirstKey, rollbackFirstKey, 4)
+	RegisterCommand("ZRemRangeByRank", execZRemRangeByRank, writeFirstKey, rollbackFirstKey, 4)
+	RegisterCommand("ZRemRangeByLex", execZRemRangeByLex, writeFirstKey, rollbackFirstKey, 4)
+	RegisterCommand("ZUnion", execZUnion, prepareZSetOperation, nil, -3)
+	RegisterCommand("ZUnionStore", execZUnionStore, prepareZSetOperationStore, rollbackFirstKey, -4)
+	RegisterCommand("ZInter", execZInter, prepareZSetOperation, nil, -3)
+	RegisterCommand("ZInterStore", execZInterStore, prepareZSetOperationStore, rollbackFirstKey, -4)
+	RegisterCommand("ZInterCard", execZInterCard, prepareSInterCard, nil, -3)
+	RegisterCommand("ZDiff", execZDiff, prepareZSetOperation, nil, -3)
+	RegisterCommand("ZDiffStore", execZDiffStore, prepareZSetOperationStore, rollbackFirstKey, -4)
+}
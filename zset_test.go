@@ -0,0 +1,297 @@
+package JZ_Redis
+
+import (
+	"JZ_Redis/lib/utils"
+	"JZ_Redis/redis/reply/asserts"
+	"strconv"
+	"testing"
+)
+
+func TestZAdd(t *testing.T) {
+	testDB.Flush()
+	size := 100
+
+	// add new members
+	key := utils.RandString(10)
+	members := make([]string, size)
+	scores := make([]float64, size)
+	setArgs := []string{key}
+	for i := 0; i < size; i++ {
+		members[i] = utils.RandString(10)
+		scores[i] = float64(i)
+		setArgs = append(setArgs, strconv.FormatFloat(scores[i], 'f', -1, 64), members[i])
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine2("zadd", setArgs...))
+	asserts.AssertIntReply(t, result, size)
+
+	// test zscore and zrank
+	for i, member := range members {
+		result = testDB.Exec(nil, utils.ToCmdLine("ZScore", key, member))
+		score := strconv.FormatFloat(scores[i], 'f', -1, 64)
+		asserts.AssertBulkReply(t, result, score)
+
+		result = testDB.Exec(nil, utils.ToCmdLine("ZRank", key, member))
+		asserts.AssertIntReply(t, result, i)
+		result = testDB.Exec(nil, utils.ToCmdLine("ZRevRank", key, member))
+		asserts.AssertIntReply(t, result, size-i-1)
+	}
+
+	// test zcard
+	result = testDB.Exec(nil, utils.ToCmdLine("zcard", key))
+	asserts.AssertIntReply(t, result, size)
+
+	// update members
+	setArgs = []string{key}
+	for i := 0; i < size; i++ {
+		scores[i] = float64(i + size)
+		setArgs = append(setArgs, strconv.FormatFloat(scores[i], 'f', -1, 64), members[i])
+	}
+	result = testDB.Exec(nil, utils.ToCmdLine2("zadd", setArgs...))
+	asserts.AssertIntReply(t, result, 0)
+	for i, member := range members {
+		result = testDB.Exec(nil, utils.ToCmdLine("ZScore", key, member))
+		score := strconv.FormatFloat(scores[i], 'f', -1, 64)
+		asserts.AssertBulkReply(t, result, score)
+	}
+}
+
+func TestZAddOptions(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "10", "a"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("zadd", key, "NX", "20", "a", "1", "b"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zscore", key, "a"))
+	asserts.AssertBulkReply(t, result, "10")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "XX", "CH", "20", "a", "1", "c"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zscore", key, "c"))
+	asserts.AssertNullBulk(t, result)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "GT", "CH", "15", "a", "30", "b"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zscore", key, "a"))
+	asserts.AssertBulkReply(t, result, "20")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "LT", "CH", "15", "a", "40", "b"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zscore", key, "a"))
+	asserts.AssertBulkReply(t, result, "15")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "INCR", "2.5", "a"))
+	asserts.AssertBulkReply(t, result, "17.5")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "NX", "INCR", "1", "a"))
+	asserts.AssertNullBulk(t, result)
+	result = testDB.Exec(nil, utils.ToCmdLine("zincrby", key, "-7.5", "a"))
+	asserts.AssertBulkReply(t, result, "10")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "+inf", "d"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zscore", key, "d"))
+	asserts.AssertBulkReply(t, result, "inf")
+	result = testDB.Exec(nil, utils.ToCmdLine("zincrby", key, "-inf", "d"))
+	asserts.AssertErrReply(t, result, "ERR resulting score is not a number (NaN)")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "NX", "XX", "1", "a"))
+	asserts.AssertErrReply(t, result, "ERR XX and NX options at the same time are not compatible")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "GT", "LT", "1", "a"))
+	asserts.AssertErrReply(t, result, "ERR GT, LT, and/or NX options at the same time are not compatible")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "INCR", "1", "a", "2", "b"))
+	asserts.AssertErrReply(t, result, "ERR INCR option supports a single increment-element pair")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "x", "a"))
+	asserts.AssertErrReply(t, result, "ERR value is not a valid float")
+	result = testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a", "2"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+
+	// XX never creates key
+	newKey := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", newKey, "XX", "1", "a"))
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", newKey))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestZRange(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "b", "c", "d", "e"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "1", "2", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "2", "c", "3"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "1", "REV"))
+	asserts.AssertMultiBulkReply(t, result, []string{"e", "d"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrevrange", key, "-2", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "a"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "10", "20"))
+	asserts.AssertMultiBulkReplySize(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "(1", "4", "BYSCORE"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "c", "d"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "+inf", "-inf", "BYSCORE", "REV", "LIMIT", "1", "2"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d", "c"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrangebyscore", key, "2", "(4", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "2", "c", "3"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrangebyscore", key, "-inf", "+inf", "LIMIT", "3", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d", "e"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrevrangebyscore", key, "4", "2"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d", "c", "b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zcount", key, "(1", "+inf"))
+	asserts.AssertIntReply(t, result, 4)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "1", "LIMIT", "0", "1"))
+	asserts.AssertErrReply(t, result, "ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+	result = testDB.Exec(nil, utils.ToCmdLine("zrangebyscore", key, "a", "1"))
+	asserts.AssertErrReply(t, result, "ERR min or max is not a float")
+}
+
+func TestZRem(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a", "2", "b", "3", "c", "4", "d", "5", "e"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("zrem", key, "a", "x"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zremrangebyscore", key, "(2", "3"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "d", "e"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zremrangebyrank", key, "0", "-2"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"e"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zremrangebyrank", key, "0", "-1"))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestZRemHeadReverse(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "0", "a", "0", "b", "0", "c"))
+	testDB.Exec(nil, utils.ToCmdLine("zrem", key, "a"))
+	// reverse walk stops at the new head
+	result := testDB.Exec(nil, utils.ToCmdLine("zrevrangebyscore", key, "+inf", "-inf"))
+	asserts.AssertMultiBulkReply(t, result, []string{"c", "b"})
+
+	key = utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a", "2", "b", "3", "c"))
+	testDB.Exec(nil, utils.ToCmdLine("zremrangebyrank", key, "0", "0"))
+	result = testDB.Exec(nil, utils.ToCmdLine("zrevrangebyscore", key, "+inf", "-inf", "withscores"))
+	asserts.AssertMultiBulkReply(t, result, []string{"c", "3", "b", "2"})
+}
+
+func TestZRangeByLex(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "0", "a", "0", "b", "0", "c", "0", "d", "0", "e"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("zrangebylex", key, "-", "[c"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "b", "c"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrangebylex", key, "(a", "+", "LIMIT", "1", "2"))
+	asserts.AssertMultiBulkReply(t, result, []string{"c", "d"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrevrangebylex", key, "[d", "(a"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d", "c", "b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "[d", "(b", "BYLEX", "REV"))
+	asserts.AssertMultiBulkReply(t, result, []string{"d", "c"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zlexcount", key, "[b", "(e"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("zlexcount", utils.RandString(10), "-", "+"))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zrangebylex", key, "a", "+"))
+	asserts.AssertErrReply(t, result, "ERR min or max not valid string range item")
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "-", "+", "BYLEX", "WITHSCORES"))
+	asserts.AssertErrReply(t, result, "ERR syntax error, WITHSCORES not supported in combination with BYLEX")
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zremrangebylex", key, "[b", "[d"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "-1"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "e"})
+
+	// reverse walks after removing the first element
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "0", "b", "0", "c"))
+	testDB.Exec(nil, utils.ToCmdLine("zremrangebylex", key, "-", "[a"))
+	result = testDB.Exec(nil, utils.ToCmdLine("zrevrangebylex", key, "+", "-"))
+	asserts.AssertMultiBulkReply(t, result, []string{"e", "c", "b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "+", "-", "BYLEX", "REV"))
+	asserts.AssertMultiBulkReply(t, result, []string{"e", "c", "b"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", key, "+inf", "-inf", "BYSCORE", "REV"))
+	asserts.AssertMultiBulkReply(t, result, []string{"e", "c", "b"})
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zremrangebylex", key, "-", "+"))
+	asserts.AssertIntReply(t, result, 3)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", key))
+	asserts.AssertIntReply(t, result, 0)
+}
+
+func TestZSetAlgebra(t *testing.T) {
+	testDB.Flush()
+	key1 := utils.RandString(10)
+	key2 := utils.RandString(10)
+	setKey := utils.RandString(10)
+	dest := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key1, "1", "a", "2", "b", "3", "c"))
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key2, "10", "b", "20", "c", "30", "d"))
+	testDB.Exec(nil, utils.ToCmdLine("sadd", setKey, "a", "c"))
+
+	result := testDB.Exec(nil, utils.ToCmdLine("zunion", "2", key1, key2, "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "1", "b", "12", "c", "23", "d", "30"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zunion", "2", key1, key2, "WEIGHTS", "2", "0.5", "AGGREGATE", "MAX", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "2", "b", "5", "c", "10", "d", "15"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zinter", "2", key1, key2, "AGGREGATE", "MIN", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"b", "2", "c", "3"})
+	// members of plain set have score 1
+	result = testDB.Exec(nil, utils.ToCmdLine("zinter", "2", key1, setKey, "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "2", "c", "4"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zinter", "2", key1, utils.RandString(10)))
+	asserts.AssertMultiBulkReplySize(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("zdiff", "2", key1, key2, "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "1"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zintercard", "3", key1, key2, setKey))
+	asserts.AssertIntReply(t, result, 1)
+	result = testDB.Exec(nil, utils.ToCmdLine("zintercard", "2", key1, key2, "LIMIT", "1"))
+	asserts.AssertIntReply(t, result, 1)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zunionstore", dest, "3", key1, key2, setKey))
+	asserts.AssertIntReply(t, result, 4)
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", dest, "0", "-1", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "2", "b", "12", "c", "24", "d", "30"})
+	result = testDB.Exec(nil, utils.ToCmdLine("zinterstore", dest, "2", key1, key2, "WEIGHTS", "1", "-1"))
+	asserts.AssertIntReply(t, result, 2)
+	result = testDB.Exec(nil, utils.ToCmdLine("zrange", dest, "0", "-1", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"c", "-17", "b", "-8"})
+	// dest could also be a source
+	result = testDB.Exec(nil, utils.ToCmdLine("zdiffstore", dest, "2", dest, key1))
+	asserts.AssertIntReply(t, result, 0)
+	result = testDB.Exec(nil, utils.ToCmdLine("exists", dest))
+	asserts.AssertIntReply(t, result, 0)
+
+	result = testDB.Exec(nil, utils.ToCmdLine("zunion", "0", key1))
+	asserts.AssertErrReply(t, result, "ERR at least 1 input key is needed for 'zunion' command")
+	result = testDB.Exec(nil, utils.ToCmdLine("zunion", "3", key1, key2))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+	result = testDB.Exec(nil, utils.ToCmdLine("zunion", "2", key1, key2, "WEIGHTS", "1", "x"))
+	asserts.AssertErrReply(t, result, "ERR weight value is not a float")
+	result = testDB.Exec(nil, utils.ToCmdLine("zdiff", "2", key1, key2, "AGGREGATE", "MIN"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+	result = testDB.Exec(nil, utils.ToCmdLine("zunionstore", dest, "1", key1, "WITHSCORES"))
+	asserts.AssertErrReply(t, result, "Err syntax error")
+	testDB.Exec(nil, utils.ToCmdLine("set", "str", "a"))
+	result = testDB.Exec(nil, utils.ToCmdLine("zunion", "2", key1, "str"))
+	asserts.AssertErrReply(t, result, "WRONGTYPE Operation against a key holding the wrong kind of value")
+}
+
+func TestUndoZAdd(t *testing.T) {
+	testDB.Flush()
+	key := utils.RandString(10)
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "1", "a"))
+	undoCmdLines := undoZAdd(testDB, utils.ToCmdLine(key, "CH", "2", "a", "3", "b"))
+	testDB.Exec(nil, utils.ToCmdLine("zadd", key, "CH", "2", "a", "3", "b"))
+	for _, cmdLine := range undoCmdLines {
+		testDB.applyUndoLog(cmdLine)
+	}
+	result := testDB.Exec(nil, utils.ToCmdLine("zrange", key, "0", "-1", "WITHSCORES"))
+	asserts.AssertMultiBulkReply(t, result, []string{"a", "1"})
+}